@@ -1,28 +1,81 @@
 #!/bin/bash
 
+# 编译动态插件（.so）及其元数据
+# 也可以将插件静态编译到服务器中，无需.so文件：
+#   go build -tags static_all -o server ./cmd/server
+#   go build -tags "static_manager static_shell" -o server ./cmd/server
+# 设置SIGNING_KEY为私钥文件路径时为编译出的插件签名：
+#   SIGNING_KEY=signing.key ./build_plugins.sh
+
+set -e
+
+# 插件接口版本，写入元数据供服务器加载插件前检查兼容性
+API_VERSION=$(sed -n 's/^const APIVersion = "\(.*\)"$/\1/p' pkg/plugin/api.go)
+
 # 创建插件目录
 mkdir -p plugins
 
+# build_plugin <id> <name> <type> <description> [capabilities]
+build_plugin() {
+	go build -buildmode=plugin -o "plugins/$1.so" "./plugins/$1/cmd"
+	cat > "plugins/$1.so.yml" <<EOF
+id: $1
+name: $2
+version: 1.0.0
+type: $3
+description: $4
+api_version: $API_VERSION
+EOF
+	if [ -n "$5" ]; then
+		echo "capabilities: [$5]" >> "plugins/$1.so.yml"
+	fi
+	# 设置了SIGNING_KEY时为插件签名
+	if [ -n "$SIGNING_KEY" ]; then
+		go run ./cmd/pluginpack sign -key "$SIGNING_KEY" "plugins/$1.so"
+	fi
+}
+
 # 编译插件管理插件
 echo "Building manager plugin..."
-go build -buildmode=plugin -o plugins/manager.so ./plugins/manager
+build_plugin manager "Plugin Manager" 1 "插件安装、卸载、启用、禁用、升级和服务管理" manage_plugins
 
 # 编译文件传输插件
 echo "Building file plugin..."
-go build -buildmode=plugin -o plugins/file.so ./plugins/file
+build_plugin file "File Transfer" 1 "文件上传下载、断点续传和压缩传输"
 
 # 编译Shell执行插件
 echo "Building shell plugin..."
-go build -buildmode=plugin -o plugins/shell.so ./plugins/shell
+build_plugin shell "Shell Executor" 1 "执行服务器Shell命令"
 
 # 编译终端管理插件
 echo "Building terminal plugin..."
-go build -buildmode=plugin -o plugins/terminal.so ./plugins/terminal
+build_plugin terminal "Terminal Manager" 1 "管理远程终端"
 
 # 编译代理服务插件
 echo "Building proxy plugin..."
-go build -buildmode=plugin -o plugins/proxy.so ./plugins/proxy
+build_plugin proxy "Proxy Service" 0 "HTTP、SOCKS4和SOCKS5代理服务"
 
 # 代理命令插件已被移除，因为它的功能已经被 manager 插件的服务管理命令完全覆盖
 
+# build_wasm_plugin <id> <name> <description>，需要Go 1.21及以上
+build_wasm_plugin() {
+	GOOS=wasip1 GOARCH=wasm go build -o "plugins/$1.wasm" "./plugins/$1/cmd"
+	cat > "plugins/$1.wasm.yml" <<EOF
+id: $1
+name: $2
+version: 1.0.0
+type: 1
+description: $3
+api_version: $API_VERSION
+runtime: wasm
+EOF
+	if [ -n "$SIGNING_KEY" ]; then
+		go run ./cmd/pluginpack sign -key "$SIGNING_KEY" "plugins/$1.wasm"
+	fi
+}
+
+# 编译wasm示例插件
+echo "Building checksum wasm plugin..."
+build_wasm_plugin checksum "Checksum" "计算文件和输入数据的校验和（wasm插件示例）"
+
 echo "All plugins built successfully!"
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/sorc/tcpserver/pkg/client"
+)
+
+// runFleet 在清单中的所有主机上执行命令并输出汇总，返回进程退出码
+func runFleet(inventoryPath, execLine string, concurrency int, timeout time.Duration) int {
+	inv, err := client.LoadInventory(inventoryPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	// 解析命令：<plugin> <command> [args]
+	parts := strings.Fields(execLine)
+	if len(parts) < 2 {
+		fmt.Fprintln(os.Stderr, "Invalid -exec format. Use: <plugin> <command> [args]")
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fmt.Printf("Running '%s' on %d hosts (concurrency=%d, timeout=%s)\n", execLine, len(inv.Hosts), concurrency, timeout)
+
+	results := client.RunFleet(ctx, inv, parts[0], parts[1], parts[2:], client.FleetOptions{
+		Concurrency: concurrency,
+		Timeout:     timeout,
+		Output:      os.Stdout,
+	})
+
+	fmt.Println()
+	client.WriteSummary(os.Stdout, results)
+
+	for _, r := range results {
+		if !r.Success {
+			return 1
+		}
+	}
+	return 0
+}
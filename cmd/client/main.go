@@ -2,9 +2,7 @@ package main
 
 import (
 	"bufio"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -16,29 +14,38 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sorc/tcpserver/internal/crypto"
+	"github.com/sorc/tcpserver/pkg/client"
 	"github.com/sorc/tcpserver/pkg/protocol"
+	"github.com/sorc/tcpserver/pkg/schema"
 )
 
-// ClientConfig 客户端配置
-type ClientConfig struct {
-	ServerAddr string `json:"server_addr"`
-	ClientID   string `json:"client_id"`
-	Secret     string `json:"secret"`
-}
-
 // Client 客户端
 type Client struct {
-	config    ClientConfig
+	config    client.Config
 	conn      net.Conn
 	sessionID string
 	cipher    *crypto.XXTEACipher
+	// plugins 服务器返回的插件命令描述，按插件ID索引
+	plugins map[string]protocol.PluginDescription
 }
 
 func main() {
 	// 解析命令行参数
 	configPath := flag.String("config", "client.json", "Path to config file")
+	inventoryPath := flag.String("inventory", "", "Path to host inventory file, runs -exec on all hosts")
+	execLine := flag.String("exec", "", "Command to run in inventory mode: <plugin> <command> [args]")
+	concurrency := flag.Int("concurrency", 10, "Maximum number of hosts to run on in parallel")
+	timeout := flag.Duration("timeout", 30*time.Second, "Per-host timeout in inventory mode, 0 to disable")
 	flag.Parse()
 
+	// 批量执行模式
+	if *inventoryPath != "" {
+		if *execLine == "" {
+			log.Fatal("-exec is required with -inventory")
+		}
+		os.Exit(runFleet(*inventoryPath, *execLine, *concurrency, *timeout))
+	}
+
 	// 读取配置文件
 	configData, err := os.ReadFile(*configPath)
 	if err != nil {
@@ -46,7 +53,7 @@ func main() {
 	}
 
 	// 解析配置
-	var config ClientConfig
+	var config client.Config
 	if err := json.Unmarshal(configData, &config); err != nil {
 		log.Fatalf("Failed to parse config: %v", err)
 	}
@@ -68,6 +75,11 @@ func main() {
 		log.Fatalf("Authentication failed: %v", err)
 	}
 
+	// 获取插件命令描述，用于生成帮助和识别交互式命令，旧版本服务器不支持时忽略
+	if err := client.LoadDescriptions(); err != nil {
+		log.Printf("Command descriptions not available: %v", err)
+	}
+
 	fmt.Println("Connected to server and authenticated successfully.")
 	fmt.Println("Type 'help' for available commands.")
 
@@ -93,6 +105,29 @@ func main() {
 			continue
 		}
 
+		if strings.HasPrefix(line, "help ") {
+			client.PrintPluginHelp(strings.TrimSpace(strings.TrimPrefix(line, "help ")))
+			continue
+		}
+
+		if line == "agents" {
+			if err := client.ListAgents(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
+
+		// 解析目标代理端（@<agent_id> <plugin> <command> [args]）
+		agent := ""
+		if strings.HasPrefix(line, "@") {
+			agentParts := strings.SplitN(line, " ", 2)
+			agent = strings.TrimPrefix(agentParts[0], "@")
+			line = ""
+			if len(agentParts) > 1 {
+				line = agentParts[1]
+			}
+		}
+
 		// 解析命令
 		parts := strings.SplitN(line, " ", 3)
 		if len(parts) < 2 {
@@ -115,7 +150,7 @@ func main() {
 
 			// 在新的goroutine中执行命令
 			go func() {
-				err := client.ExecuteCommand(plugin, command, args)
+				err := client.ExecuteCommand(agent, plugin, command, args)
 				resultCh <- err
 			}()
 
@@ -139,7 +174,7 @@ func main() {
 }
 
 // NewClient 创建新的客户端
-func NewClient(config ClientConfig) (*Client, error) {
+func NewClient(config client.Config) (*Client, error) {
 	// 创建加密器
 	cipher, err := crypto.NewXXTEACipher([]byte(config.Secret))
 	if err != nil {
@@ -154,10 +189,9 @@ func NewClient(config ClientConfig) (*Client, error) {
 
 // Connect 连接服务器
 func (c *Client) Connect() error {
-	// 连接服务器
-	conn, err := net.Dial("tcp", c.config.ServerAddr)
+	conn, err := client.Dial(context.Background(), c.config)
 	if err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+		return err
 	}
 	c.conn = conn
 
@@ -173,87 +207,212 @@ func (c *Client) Close() {
 
 // Authenticate 认证
 func (c *Client) Authenticate() error {
-	// 生成随机数
-	nonce := uuid.New().String()
-	timestamp := time.Now().Unix()
-
-	// 生成签名
-	h := hmac.New(sha256.New, []byte(c.config.Secret))
-	h.Write([]byte(fmt.Sprintf("%s:%s:%d", c.config.ClientID, nonce, timestamp)))
-	signature := hex.EncodeToString(h.Sum(nil))
-
-	// 创建认证请求
-	authMsg, err := protocol.NewAuthRequestMessage(
-		uuid.New().String(),
-		c.config.ClientID,
-		nonce,
-		timestamp,
-		signature,
-		false,
-	)
+	sessionID, err := client.Authenticate(c.conn, c.config.ClientID, c.config.Secret)
 	if err != nil {
-		return fmt.Errorf("failed to create auth request: %w", err)
+		return err
 	}
 
-	// 发送认证请求
-	if err := protocol.WriteMessage(c.conn, authMsg); err != nil {
-		return fmt.Errorf("failed to send auth request: %w", err)
+	// 保存会话ID
+	c.sessionID = sessionID
+
+	return nil
+}
+
+// ListAgents 列出中心节点上已连接的代理端
+func (c *Client) ListAgents() error {
+	requestID := uuid.New().String()
+	if err := protocol.WriteMessage(c.conn, protocol.NewAgentListRequestMessage(requestID, false)); err != nil {
+		return fmt.Errorf("failed to send agent list request: %w", err)
 	}
 
-	// 读取认证响应
-	respMsg, err := protocol.ReadMessage(c.conn)
-	if err != nil {
-		return fmt.Errorf("failed to read auth response: %w", err)
+	for {
+		respMsg, err := protocol.ReadMessage(c.conn)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if respMsg.Header.RequestID != requestID {
+			continue
+		}
+
+		switch respMsg.Header.Type {
+		case protocol.AgentListResponse:
+			var resp protocol.AgentListResponseBody
+			if err := json.Unmarshal(respMsg.Body, &resp); err != nil {
+				return fmt.Errorf("failed to parse agent list: %w", err)
+			}
+
+			fmt.Println("Connected Agents:")
+			fmt.Println("ID\tName\tAddress\tConnected\tLast Seen")
+			fmt.Println("----------------------------------------------------")
+			for _, agent := range resp.Agents {
+				fmt.Printf("%s\t%s\t%s\t%s\t%s\n", agent.ID, agent.Name, agent.RemoteAddr,
+					time.Unix(agent.ConnectedAt, 0).Format(time.RFC3339),
+					time.Unix(agent.LastSeen, 0).Format(time.RFC3339))
+			}
+			return nil
+		case protocol.ErrorResponse:
+			var errResp protocol.ErrorResponseBody
+			if err := json.Unmarshal(respMsg.Body, &errResp); err != nil {
+				return fmt.Errorf("failed to parse error response: %w", err)
+			}
+			return fmt.Errorf("error: %s", errResp.Message)
+		}
 	}
+}
 
-	// 检查响应类型
-	if respMsg.Header.Type != protocol.AuthResponse {
-		return fmt.Errorf("unexpected response type: %d", respMsg.Header.Type)
+// LoadDescriptions 获取服务器上有权使用的插件及其命令描述
+func (c *Client) LoadDescriptions() error {
+	requestID := uuid.New().String()
+	reqMsg, err := protocol.NewDescribeRequestMessage(requestID, "", false)
+	if err != nil {
+		return fmt.Errorf("failed to create describe request: %w", err)
 	}
+	if err := protocol.WriteMessage(c.conn, reqMsg); err != nil {
+		return fmt.Errorf("failed to send describe request: %w", err)
+	}
+
+	for {
+		respMsg, err := protocol.ReadMessage(c.conn)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if respMsg.Header.RequestID != requestID {
+			continue
+		}
 
-	// 解析认证响应
-	var authResp protocol.AuthResponseBody
-	if err := json.Unmarshal(respMsg.Body, &authResp); err != nil {
-		return fmt.Errorf("failed to parse auth response: %w", err)
+		switch respMsg.Header.Type {
+		case protocol.DescribeResponse:
+			var resp protocol.DescribeResponseBody
+			if err := json.Unmarshal(respMsg.Body, &resp); err != nil {
+				return fmt.Errorf("failed to parse describe response: %w", err)
+			}
+			c.plugins = make(map[string]protocol.PluginDescription, len(resp.Plugins))
+			for _, p := range resp.Plugins {
+				c.plugins[p.ID] = p
+			}
+			return nil
+		case protocol.ErrorResponse:
+			var errResp protocol.ErrorResponseBody
+			if err := json.Unmarshal(respMsg.Body, &errResp); err != nil {
+				return fmt.Errorf("failed to parse error response: %w", err)
+			}
+			return fmt.Errorf("error: %s", errResp.Message)
+		}
 	}
+}
 
-	// 检查认证结果
-	if !authResp.Success {
-		return fmt.Errorf("authentication failed: %s", authResp.Message)
+// describeCommand 查找命令的描述
+func (c *Client) describeCommand(pluginID, command string) (schema.CommandDescriptor, bool) {
+	for _, d := range c.plugins[pluginID].Commands {
+		if d.Name == command {
+			return d, true
+		}
 	}
+	return schema.CommandDescriptor{}, false
+}
 
-	// 保存会话ID
-	c.sessionID = authResp.SessionID
+// PrintPluginHelp 根据服务器提供的命令描述打印插件的帮助信息
+func (c *Client) PrintPluginHelp(pluginID string) {
+	p, ok := c.plugins[pluginID]
+	if !ok {
+		fmt.Printf("No command descriptions for plugin %s\n", pluginID)
+		return
+	}
 
-	return nil
+	fmt.Printf("%s (%s %s):\n", p.ID, p.Name, p.Version)
+	for _, d := range p.Commands {
+		fmt.Printf("  %s %s", p.ID, d.Usage())
+		if d.Description != "" {
+			fmt.Printf(" - %s", d.Description)
+		}
+		fmt.Println()
+		for _, arg := range d.Args {
+			fmt.Printf("      <%s> %s", arg.Name, arg.Type)
+			if arg.Default != "" {
+				fmt.Printf(" (default %s)", arg.Default)
+			}
+			if len(arg.Enum) > 0 {
+				fmt.Printf(" (one of: %s)", strings.Join(arg.Enum, ", "))
+			}
+			if arg.Description != "" {
+				fmt.Printf(" %s", arg.Description)
+			}
+			fmt.Println()
+		}
+		for _, flag := range d.Flags {
+			name := "--" + flag.Name
+			if flag.Short != "" {
+				name = "-" + flag.Short + ", " + name
+			}
+			fmt.Printf("      %s", name)
+			if flag.Default != "" {
+				fmt.Printf(" (default %s)", flag.Default)
+			}
+			if flag.Description != "" {
+				fmt.Printf(" %s", flag.Description)
+			}
+			fmt.Println()
+		}
+		if d.Interactive {
+			fmt.Println("      interactive command")
+		}
+		if len(d.Permissions) > 0 {
+			fmt.Printf("      requires: %s\n", strings.Join(d.Permissions, ", "))
+		}
+	}
 }
 
-// ExecuteCommand 执行命令
-func (c *Client) ExecuteCommand(plugin, command string, args string) error {
+// ExecuteCommand 执行命令，agent不为空时由中心节点转发到指定代理端执行
+func (c *Client) ExecuteCommand(agent, plugin, command string, args string) error {
 	// 创建命令请求
 	cmdArgs := []string{}
 	if args != "" {
 		cmdArgs = strings.Split(args, " ")
 	}
 
-	fmt.Printf("Executing command: plugin=%s, command=%s, args=%v\n", plugin, command, cmdArgs)
+	// --upload <本地文件>：文件内容作为命令输入发送
+	cmdArgs, upload, err := prepareUpload(cmdArgs)
+	if err != nil {
+		return err
+	}
+	streaming := false
+	defer func() {
+		if upload != nil && !streaming {
+			upload.Close()
+		}
+	}()
+
+	if agent != "" {
+		fmt.Printf("Executing command on agent %s: plugin=%s, command=%s, args=%v\n", agent, plugin, command, cmdArgs)
+	} else {
+		fmt.Printf("Executing command: plugin=%s, command=%s, args=%v\n", plugin, command, cmdArgs)
+	}
 
-	// 判断是否是交互式命令
-	interactive := false
-	if command == "interactive" {
-		interactive = true
+	// 判断是否是交互式命令，服务器未提供命令描述时按命令名称判断
+	interactive := command == "interactive"
+	if d, ok := c.describeCommand(plugin, command); ok && agent == "" {
+		interactive = d.Interactive
+	}
+	if upload != nil {
+		interactive = false
 	}
 
 	// 创建命令请求
 	requestID := uuid.New().String()
-	cmdMsg, err := protocol.NewCommandRequestMessage(
-		requestID,
-		plugin,
-		command,
-		cmdArgs,
-		interactive,
-		false,
-	)
+	var cmdMsg *protocol.Message
+	if upload != nil {
+		cmdMsg, err = protocol.NewInputCommandRequestMessage(requestID, agent, plugin, command, cmdArgs, false)
+	} else {
+		cmdMsg, err = protocol.NewAgentCommandRequestMessage(
+			requestID,
+			agent,
+			plugin,
+			command,
+			cmdArgs,
+			interactive,
+			false,
+		)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create command request: %w", err)
 	}
@@ -263,6 +422,17 @@ func (c *Client) ExecuteCommand(plugin, command string, args string) error {
 		return fmt.Errorf("failed to send command request: %w", err)
 	}
 
+	// 在读取响应的同时发送上传的文件，服务器提前结束命令时剩余的数据被丢弃
+	if upload != nil {
+		streaming = true
+		go func() {
+			defer upload.Close()
+			if err := c.streamInput(requestID, upload); err != nil {
+				fmt.Printf("Upload failed: %v\n", err)
+			}
+		}()
+	}
+
 	// 处理交互式命令
 	if interactive {
 		return c.handleInteractiveCommand(requestID)
@@ -296,6 +466,9 @@ func (c *Client) ExecuteCommand(plugin, command string, args string) error {
 			fmt.Printf("Command response: success=%v, message=%s\n", cmdResp.Success, cmdResp.Message)
 
 			if !cmdResp.Success {
+				if cmdResp.ExitCode != 0 {
+					return fmt.Errorf("command failed (exit status %d): %s", cmdResp.ExitCode, cmdResp.Message)
+				}
 				return fmt.Errorf("command failed: %s", cmdResp.Message)
 			}
 
@@ -404,18 +577,30 @@ func printHelp() {
 	fmt.Println("Plugin Management:")
 	fmt.Println("  manager list - List installed plugins")
 	fmt.Println("  manager install <plugin_path> - Install a plugin")
+	fmt.Println("  manager install --upload <local_package> - Upload a plugin package and install it")
+	fmt.Println("  manager install <plugin_id>[@<version>] - Install a plugin from the repository")
 	fmt.Println("  manager uninstall <plugin_id> - Uninstall a plugin")
 	fmt.Println("  manager enable <plugin_id> - Enable a plugin")
 	fmt.Println("  manager disable <plugin_id> - Disable a plugin")
 	fmt.Println("  manager upgrade <plugin_id> <plugin_path> - Upgrade a plugin")
+	fmt.Println("  manager upgrade <plugin_id> --upload <local_package> - Upload a plugin package and upgrade to it")
+	fmt.Println("  manager upgrade <plugin_id>[@<version>] - Upgrade a plugin from the repository")
+	fmt.Println("  manager search [query] - Search the plugin repository")
+	fmt.Println("  manager outdated - List plugins with newer versions in the repository")
 	fmt.Println("  manager info <plugin_id> - Show plugin information")
+	fmt.Println("  manager audit [plugin_id] [--limit <n>] - Show audited command executions")
+	fmt.Println("  manager interceptors - List command interceptors")
 	fmt.Println("")
 	fmt.Println("Service Management:")
 	fmt.Println("  manager start <plugin_id> - Start a service plugin")
 	fmt.Println("  manager stop <plugin_id> - Stop a service plugin")
 	fmt.Println("  manager restart <plugin_id> - Restart a service plugin")
+	fmt.Println("  manager pause <plugin_id> - Pause a service plugin, refusing new connections")
+	fmt.Println("  manager resume <plugin_id> - Resume a paused service plugin")
 	fmt.Println("  manager status [plugin_id] - Show service plugin status")
-	fmt.Println("  manager config <plugin_id> [config_file] - Configure a service plugin")
+	fmt.Println("  manager config <plugin_id> [config_file | --schema] - Show or update plugin configuration")
+	fmt.Println("  manager metrics [plugin_id] - Show plugin metrics")
+	fmt.Println("  manager health [plugin_id] [--json] - Show plugin health")
 	fmt.Println("")
 	fmt.Println("File Operations:")
 	fmt.Println("  file upload <local_path> <remote_path> [--compress] [--overwrite] - Upload a file or directory")
@@ -436,9 +621,18 @@ func printHelp() {
 	fmt.Println("  terminal kill <terminal_id> - Kill a terminal")
 	fmt.Println("  terminal write <request_json> - Write to a terminal")
 	fmt.Println("  terminal read <terminal_id> - Read from a terminal")
-	// 代理服务命令已被移除，因为它的功能已经被 manager 插件的服务管理命令完全覆盖
+	fmt.Println("")
+	fmt.Println("Proxy Service:")
+	fmt.Println("  proxy status - Show proxy status")
+	fmt.Println("  proxy start <http|socks> - Start a proxy listener")
+	fmt.Println("  proxy stop <http|socks> - Stop a proxy listener")
+	fmt.Println("")
+	fmt.Println("Agent Management (hub only):")
+	fmt.Println("  agents - List connected agents")
+	fmt.Println("  @<agent_id> <plugin> <command> [args] - Run a command on an agent")
 	fmt.Println("")
 	fmt.Println("Other Commands:")
 	fmt.Println("  help - Show this help")
+	fmt.Println("  help <plugin> - Show commands, arguments and flags reported by the server for a plugin")
 	fmt.Println("  exit/quit - Exit the client")
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+// uploadChunkSize 上传时每条数据流消息的大小
+const uploadChunkSize = 64 * 1024
+
+// prepareUpload 处理参数中的--upload <本地文件>：计算文件大小和SHA-256，
+// 将本地路径替换为文件名并追加--size和--sha256，返回打开的文件，没有--upload时返回nil
+func prepareUpload(args []string) ([]string, *os.File, error) {
+	index := -1
+	for i, arg := range args {
+		if arg == "--upload" {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return args, nil, nil
+	}
+	if index+1 >= len(args) {
+		return nil, nil, fmt.Errorf("--upload requires a local file")
+	}
+
+	localPath := args[index+1]
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", localPath, err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+
+	rewritten := append([]string{}, args...)
+	rewritten[index+1] = filepath.Base(localPath)
+	rewritten = append(rewritten, "--size", strconv.FormatInt(size, 10), "--sha256", hex.EncodeToString(hash.Sum(nil)))
+	return rewritten, file, nil
+}
+
+// streamInput 以数据流消息发送命令输入，最后发送空消息表示输入结束
+func (c *Client) streamInput(requestID string, input io.Reader) error {
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, err := input.Read(buf)
+		if n > 0 {
+			if werr := protocol.WriteMessage(c.conn, protocol.NewDataStreamMessage(requestID, buf[:n], false)); werr != nil {
+				return fmt.Errorf("failed to send input: %w", werr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+	}
+	return protocol.WriteMessage(c.conn, protocol.NewDataStreamMessage(requestID, nil, false))
+}
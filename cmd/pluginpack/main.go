@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+)
+
+// pluginpack 生成签名密钥、为插件签名并打包
+//
+//	pluginpack keygen -o signing                     生成signing.key（私钥）和signing.pub（公钥）
+//	pluginpack sign -key signing.key plugins/shell.so 在插件旁生成shell.so.manifest和shell.so.sig
+//	pluginpack pack -key signing.key -o shell.tar.gz plugins/shell.so
+//	pluginpack index repo                            根据repo中的插件包生成插件仓库索引repo/index.json
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	case "pack":
+		err = pack(os.Args[2:])
+	case "index":
+		err = index(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// usage 输出用法并退出
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  pluginpack keygen -o <name>")
+	fmt.Fprintln(os.Stderr, "  pluginpack sign -key <private_key_file> <plugin_file>")
+	fmt.Fprintln(os.Stderr, "  pluginpack pack -key <private_key_file> -o <package.tar.gz> <plugin_file>")
+	fmt.Fprintln(os.Stderr, "  pluginpack index [-o <index.json>] <repository_dir>")
+	os.Exit(2)
+}
+
+// keygen 生成Ed25519签名密钥
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("o", "signing", "Output file name without extension")
+	fs.Parse(args)
+
+	pub, priv, err := plugin.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+".key", []byte(priv+"\n"), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+".pub", []byte(pub+"\n"), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Private key: %s.key\n", *out)
+	fmt.Printf("Public key:  %s.pub\n", *out)
+	fmt.Printf("Add the public key to server.plugin_signing.trusted_keys: %s\n", pub)
+	return nil
+}
+
+// sign 在插件文件旁生成清单和签名
+func sign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := fs.String("key", "", "Path to private key file")
+	fs.Parse(args)
+	if *keyFile == "" || fs.NArg() != 1 {
+		usage()
+	}
+
+	key, err := readPrivateKey(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	manifest, sig, err := plugin.SignPlugin(path, key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".manifest", manifest, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".sig", sig, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Signed %s\n", path)
+	return nil
+}
+
+// pack 将插件打包为签名的插件包
+func pack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	keyFile := fs.String("key", "", "Path to private key file")
+	out := fs.String("o", "", "Output package path, default <plugin_file>.tar.gz")
+	fs.Parse(args)
+	if *keyFile == "" || fs.NArg() != 1 {
+		usage()
+	}
+
+	key, err := readPrivateKey(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	if *out == "" {
+		*out = filepath.Base(path) + ".tar.gz"
+	}
+	if err := plugin.BuildPackage(path, *out, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("Package written to %s\n", *out)
+	return nil
+}
+
+// index 根据目录中的插件包生成插件仓库索引
+func index(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	out := fs.String("o", "", "Output index path, default <repository_dir>/"+plugin.RepositoryIndexFile)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	dir := fs.Arg(0)
+	idx, err := plugin.BuildRepositoryIndex(dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = filepath.Join(dir, plugin.RepositoryIndexFile)
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	versions := 0
+	for _, p := range idx.Plugins {
+		versions += len(p.Versions)
+	}
+	fmt.Printf("Index written to %s (%d plugins, %d versions)\n", *out, len(idx.Plugins), versions)
+	return nil
+}
+
+// readPrivateKey 读取私钥文件
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.ParsePrivateKey(string(data))
+}
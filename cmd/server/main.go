@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/sorc/tcpserver/internal/auth"
@@ -38,8 +39,19 @@ func main() {
 		log.Fatalf("Failed to parse config: %v", err)
 	}
 
+	log.Printf("Server version %s, plugin API %s", plugin.ServerVersion, plugin.APIVersion)
+
 	// 创建插件管理器
 	pluginManager := plugin.NewPluginManager(config.Server.PluginsDir, config.Server.ConfigDir)
+	if err := pluginManager.SetPolicies(config.Server.Plugins); err != nil {
+		log.Fatalf("Invalid plugin policy: %v", err)
+	}
+	if err := pluginManager.SetTrustConfig(config.Server.PluginSigning); err != nil {
+		log.Fatalf("Invalid plugin signing config: %v", err)
+	}
+	if config.Server.DataDir != "" {
+		pluginManager.SetDataDir(config.Server.DataDir)
+	}
 
 	// 创建服务器
 	srv, err := server.NewServer(config.Server, pluginManager)
@@ -48,8 +60,9 @@ func main() {
 	}
 
 	// 注册客户端
-	for _, client := range config.Clients {
-		if err := srv.RegisterClient(&client); err != nil {
+	for i := range config.Clients {
+		client := &config.Clients[i]
+		if err := srv.RegisterClient(client); err != nil {
 			log.Printf("Failed to register client %s: %v", client.ID, err)
 		}
 	}
@@ -59,6 +72,16 @@ func main() {
 		log.Printf("Warning: Failed to load some plugins: %v", err)
 	}
 
+	// 恢复插件上次记录的启用、禁用或运行状态
+	if err := pluginManager.RestoreStates(); err != nil {
+		log.Printf("Warning: Failed to restore some plugin states: %v", err)
+	}
+
+	// 自动启动服务
+	if err := pluginManager.AutostartServices(); err != nil {
+		log.Printf("Warning: Failed to start some services: %v", err)
+	}
+
 	// 启动服务器
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -77,6 +100,11 @@ func main() {
 		log.Fatalf("Failed to stop server: %v", err)
 	}
 
+	// 停止插件
+	if err := pluginManager.Shutdown(); err != nil {
+		log.Printf("Failed to shutdown plugins: %v", err)
+	}
+
 	log.Println("Server stopped")
 }
 
@@ -90,27 +118,57 @@ func loadPlugins(pm plugin.PluginManager, pluginsDir string) error {
 		}
 	}
 
-	// 查找所有.so文件
-	soFiles, err := filepath.Glob(filepath.Join(pluginsDir, "*.so"))
+	// 收集静态编译的插件，同ID的.so插件将被忽略
+	var candidates []plugin.PluginMetadata
+	static := make(map[string]bool)
+	for _, reg := range plugin.Registered() {
+		metadata := reg.Metadata
+		metadata.Static = true
+		candidates = append(candidates, metadata)
+		static[metadata.ID] = true
+	}
+
+	// 查找所有插件元数据文件（<插件文件>.yml），.so插件和进程插件都通过元数据加载
+	metadataFiles, err := filepath.Glob(filepath.Join(pluginsDir, "*.yml"))
 	if err != nil {
 		return fmt.Errorf("failed to list plugin files: %w", err)
 	}
 
-	// 加载每个插件
-	for _, soFile := range soFiles {
-		log.Printf("Loading plugin: %s", soFile)
-		p, err := pm.LoadPlugin(soFile)
+	for _, metadataFile := range metadataFiles {
+		pluginFile := strings.TrimSuffix(metadataFile, ".yml")
+		metadata, err := plugin.ReadPluginMetadata(pluginFile)
 		if err != nil {
-			log.Printf("Failed to load plugin %s: %v", soFile, err)
+			log.Printf("Failed to load plugin %s: %v", pluginFile, err)
+			continue
+		}
+		if static[metadata.ID] {
+			log.Printf("Plugin %s is statically compiled, ignoring %s", metadata.ID, pluginFile)
 			continue
 		}
+		candidates = append(candidates, metadata)
+	}
+
+	// 按依赖关系排序，被依赖的插件先加载
+	ordered, err := plugin.SortByDependencies(candidates)
+	if err != nil {
+		log.Printf("Some plugins will not be loaded due to unresolved dependencies:\n%v", err)
+	}
 
-		// 启用插件
-		if err := pm.EnablePlugin(p.ID()); err != nil {
-			log.Printf("Failed to enable plugin %s: %v", p.ID(), err)
+	// 加载每个插件，启用和启动由RestoreStates按记录的状态处理
+	for _, metadata := range ordered {
+		var p plugin.Plugin
+		if metadata.Static {
+			log.Printf("Loading static plugin: %s", metadata.ID)
+			p, err = pm.LoadStaticPlugin(metadata.ID)
 		} else {
-			log.Printf("Plugin %s (%s) loaded and enabled", p.Name(), p.ID())
+			log.Printf("Loading plugin: %s", metadata.Path)
+			p, err = pm.LoadPlugin(metadata.Path)
+		}
+		if err != nil {
+			log.Printf("Failed to load plugin %s: %v", metadata.ID, err)
+			continue
 		}
+		log.Printf("Plugin %s (%s) loaded", p.Name(), p.ID())
 	}
 
 	return nil
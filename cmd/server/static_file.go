@@ -0,0 +1,6 @@
+//go:build static_file || static_all
+
+package main
+
+// 静态编译file插件
+import _ "github.com/sorc/tcpserver/plugins/file"
@@ -0,0 +1,6 @@
+//go:build static_manager || static_all
+
+package main
+
+// 静态编译manager插件
+import _ "github.com/sorc/tcpserver/plugins/manager"
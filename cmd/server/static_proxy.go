@@ -0,0 +1,6 @@
+//go:build static_proxy || static_all
+
+package main
+
+// 静态编译proxy插件
+import _ "github.com/sorc/tcpserver/plugins/proxy"
@@ -0,0 +1,6 @@
+//go:build static_shell || static_all
+
+package main
+
+// 静态编译shell插件
+import _ "github.com/sorc/tcpserver/plugins/shell"
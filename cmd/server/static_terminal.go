@@ -0,0 +1,6 @@
+//go:build static_terminal || static_all
+
+package main
+
+// 静态编译terminal插件
+import _ "github.com/sorc/tcpserver/plugins/terminal"
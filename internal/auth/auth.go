@@ -31,6 +31,10 @@ const (
 	PermServiceManage Permission = "service:manage"
 	// PermPluginUse 插件使用权限
 	PermPluginUse Permission = "plugin:use"
+	// PermAgentRegister 以代理端身份连接中心节点的权限
+	PermAgentRegister Permission = "agent:register"
+	// PermAgentUse 向代理端下发命令的权限
+	PermAgentUse Permission = "agent:use"
 )
 
 // Client 客户端信息
@@ -41,6 +45,29 @@ type Client struct {
 	Permissions []Permission `json:"permissions"`
 }
 
+// HasPermission 检查客户端是否有指定权限
+func (c *Client) HasPermission(perm Permission) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPluginPermission 检查客户端是否有使用特定插件的权限
+func (c *Client) HasPluginPermission(pluginID string) bool {
+	// 检查是否有全局插件使用权限或特定插件使用权限
+	return c.HasPermission(PermPluginUse) ||
+		c.HasPermission(Permission(fmt.Sprintf("plugin:%s:use", pluginID)))
+}
+
+// HasAgentPermission 检查客户端是否有向特定代理端下发命令的权限
+func (c *Client) HasAgentPermission(agentID string) bool {
+	return c.HasPermission(PermAgentUse) ||
+		c.HasPermission(Permission(fmt.Sprintf("agent:%s:use", agentID)))
+}
+
 // Session 会话信息
 type Session struct {
 	ID        string    `json:"id"`
@@ -195,13 +222,7 @@ func (am *AuthManager) HasPermission(clientID string, perm Permission) (bool, er
 		return false, ErrClientNotFound
 	}
 
-	for _, p := range client.Permissions {
-		if p == perm {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return client.HasPermission(perm), nil
 }
 
 // HasPluginPermission 检查客户端是否有使用特定插件的权限
@@ -214,22 +235,7 @@ func (am *AuthManager) HasPluginPermission(clientID, pluginID string) (bool, err
 		return false, ErrClientNotFound
 	}
 
-	// 检查是否有全局插件使用权限
-	for _, p := range client.Permissions {
-		if p == PermPluginUse {
-			return true, nil
-		}
-	}
-
-	// 检查是否有特定插件使用权限
-	pluginPerm := Permission(fmt.Sprintf("plugin:%s:use", pluginID))
-	for _, p := range client.Permissions {
-		if p == pluginPerm {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return client.HasPluginPermission(pluginID), nil
 }
 
 // generateSignature 生成签名
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sorc/tcpserver/internal/auth"
+	"github.com/sorc/tcpserver/internal/crypto"
+	"github.com/sorc/tcpserver/pkg/client"
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+// AgentConfig 代理模式配置，配置后服务器主动连接中心节点并执行其下发的命令
+type AgentConfig struct {
+	// HubAddr 中心节点地址
+	HubAddr string `json:"hub_addr"`
+	// Network 网络类型：tcp（默认）或unix
+	Network string            `json:"network,omitempty"`
+	TLS     *client.TLSConfig `json:"tls,omitempty"`
+	// ClientID 在中心节点注册的代理端ID，对应中心节点clients中的条目
+	ClientID string `json:"client_id"`
+	Secret   string `json:"secret"`
+	// Permissions 中心节点在本机可使用的权限
+	Permissions []auth.Permission `json:"permissions"`
+	// HeartbeatInterval 心跳间隔（秒），默认30秒
+	HeartbeatInterval int `json:"heartbeat_interval,omitempty"`
+	// MaxReconnectInterval 最大重连间隔（秒），默认60秒
+	MaxReconnectInterval int `json:"max_reconnect_interval,omitempty"`
+}
+
+// runAgent 保持与中心节点的连接，断开后按指数退避重连
+func (s *Server) runAgent(config AgentConfig) {
+	maxBackoff := 60 * time.Second
+	if config.MaxReconnectInterval > 0 {
+		maxBackoff = time.Duration(config.MaxReconnectInterval) * time.Second
+	}
+
+	backoff := time.Second
+	for {
+		connectedAt := time.Now()
+		err := s.connectToHub(config)
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Agent connection to hub %s lost: %v", config.HubAddr, err)
+
+		// 连接稳定运行一段时间后重置退避时间
+		if time.Since(connectedAt) > maxBackoff {
+			backoff = time.Second
+		}
+
+		log.Printf("Reconnecting to hub in %s", backoff)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// connectToHub 连接中心节点、认证并处理其下发的命令，直到连接断开
+func (s *Server) connectToHub(config AgentConfig) error {
+	conn, err := dialHub(s.ctx, config)
+	if err != nil {
+		return err
+	}
+
+	clientCtx, clientCancel := context.WithCancel(s.ctx)
+	defer clientCancel()
+	defer conn.Close()
+
+	// 服务器停止时中断认证等阻塞操作
+	go func() {
+		<-clientCtx.Done()
+		conn.Close()
+	}()
+
+	sessionID, err := authenticateToHub(conn, config)
+	if err != nil {
+		return err
+	}
+
+	cipher, err := crypto.NewXXTEACipher([]byte(config.Secret))
+	if err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// 中心节点在本机的身份
+	client := &Client{
+		conn:      conn,
+		sessionID: sessionID,
+		clientInfo: &auth.Client{
+			ID:          "hub:" + config.HubAddr,
+			Name:        "Hub",
+			Permissions: config.Permissions,
+		},
+		cipher: cipher,
+		ctx:    clientCtx,
+		cancel: clientCancel,
+	}
+
+	log.Printf("Agent %s connected to hub %s", config.ClientID, config.HubAddr)
+
+	// 定时发送心跳
+	interval := 30 * time.Second
+	if config.HeartbeatInterval > 0 {
+		interval = time.Duration(config.HeartbeatInterval) * time.Second
+	}
+	go s.sendHeartbeats(client, interval)
+
+	s.serveClient(client)
+	return errors.New("connection closed")
+}
+
+// sendHeartbeats 定时向中心节点发送心跳
+func (s *Server) sendHeartbeats(client *Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			return
+		case <-ticker.C:
+			msg, err := protocol.NewHeartbeatRequestMessage(uuid.New().String(), time.Now().Unix(), false)
+			if err != nil {
+				log.Printf("Failed to create heartbeat: %v", err)
+				continue
+			}
+			if err := client.writeMessage(msg); err != nil {
+				log.Printf("Failed to send heartbeat to hub: %v", err)
+				client.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// dialHub 建立到中心节点的连接
+func dialHub(ctx context.Context, config AgentConfig) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return client.Dial(ctx, hubClientConfig(config))
+}
+
+// authenticateToHub 以代理端身份向中心节点认证
+func authenticateToHub(conn net.Conn, config AgentConfig) (string, error) {
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	return client.AuthenticateAgent(conn, config.ClientID, config.Secret)
+}
+
+// hubClientConfig 返回连接中心节点使用的客户端配置
+func hubClientConfig(config AgentConfig) client.Config {
+	return client.Config{
+		ServerAddr: config.HubAddr,
+		Network:    config.Network,
+		TLS:        config.TLS,
+		ClientID:   config.ClientID,
+		Secret:     config.Secret,
+	}
+}
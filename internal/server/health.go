@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+)
+
+// startHealthServer 启动健康检查HTTP服务：/healthz 在服务器运行时返回200，
+// /readyz 返回插件健康报告和连接统计，有插件unhealthy时返回503
+func (s *Server) startHealthServer(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on health address %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", s.handleReadiness)
+
+	s.healthServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("Health endpoint listening on %s", listener.Addr())
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.healthServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Health endpoint stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// readinessReport /readyz返回的插件健康报告和连接统计
+type readinessReport struct {
+	plugin.HealthReport
+	Connections ConnStats `json:"connections"`
+}
+
+// handleReadiness 返回插件健康报告和连接统计
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := readinessReport{
+		HealthReport: s.pluginManager.HealthReport(),
+		Connections:  s.ConnStats(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == plugin.HealthUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to write health report: %v", err)
+	}
+}
+
+// stopHealthServer 关闭健康检查HTTP服务
+func (s *Server) stopHealthServer() {
+	if s.healthServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.healthServer.Shutdown(ctx)
+	s.healthServer = nil
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+var (
+	ErrAgentNotFound     = errors.New("agent not found")
+	ErrAgentDisconnected = errors.New("agent disconnected")
+)
+
+const (
+	// agentIdleTimeout 代理端连接的空闲超时时间，代理端需要在此期间内发送心跳
+	agentIdleTimeout = 90 * time.Second
+)
+
+// agentConn 已连接到中心节点的代理端
+type agentConn struct {
+	client      *Client
+	connectedAt time.Time
+	lastSeen    time.Time
+	pending     map[string]*pendingRequest
+	mu          sync.Mutex
+	closed      bool
+}
+
+// pendingRequest 等待代理端响应的请求
+type pendingRequest struct {
+	ch   chan *protocol.Message
+	done chan struct{}
+}
+
+// agentHub 中心节点的代理端注册表
+type agentHub struct {
+	agents map[string]*agentConn
+	mu     sync.RWMutex
+}
+
+// newAgentHub 创建代理端注册表
+func newAgentHub() *agentHub {
+	return &agentHub{
+		agents: make(map[string]*agentConn),
+	}
+}
+
+// register 注册代理端，同ID的旧连接会被关闭
+func (h *agentHub) register(agent *agentConn) {
+	h.mu.Lock()
+	old, exists := h.agents[agent.client.clientInfo.ID]
+	h.agents[agent.client.clientInfo.ID] = agent
+	h.mu.Unlock()
+
+	if exists {
+		log.Printf("Agent %s reconnected, closing previous connection", agent.client.clientInfo.ID)
+		old.client.cancel()
+		old.client.conn.Close()
+	}
+}
+
+// unregister 注销代理端
+func (h *agentHub) unregister(agent *agentConn) {
+	h.mu.Lock()
+	if h.agents[agent.client.clientInfo.ID] == agent {
+		delete(h.agents, agent.client.clientInfo.ID)
+	}
+	h.mu.Unlock()
+}
+
+// get 获取代理端
+func (h *agentHub) get(id string) (*agentConn, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	agent, exists := h.agents[id]
+	if !exists {
+		return nil, ErrAgentNotFound
+	}
+	return agent, nil
+}
+
+// list 列出所有代理端
+func (h *agentHub) list() []*agentConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	agents := make([]*agentConn, 0, len(h.agents))
+	for _, agent := range h.agents {
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].client.clientInfo.ID < agents[j].client.clientInfo.ID
+	})
+	return agents
+}
+
+// info 返回代理端信息
+func (a *agentConn) info() protocol.AgentInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return protocol.AgentInfo{
+		ID:          a.client.clientInfo.ID,
+		Name:        a.client.clientInfo.Name,
+		RemoteAddr:  a.client.conn.RemoteAddr().String(),
+		ConnectedAt: a.connectedAt.Unix(),
+		LastSeen:    a.lastSeen.Unix(),
+	}
+}
+
+// addPending 注册等待响应的请求
+func (a *agentConn) addPending(requestID string) (chan *protocol.Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, ErrAgentDisconnected
+	}
+
+	req := &pendingRequest{
+		ch:   make(chan *protocol.Message, 16),
+		done: make(chan struct{}),
+	}
+	a.pending[requestID] = req
+	return req.ch, nil
+}
+
+// removePending 移除等待响应的请求
+func (a *agentConn) removePending(requestID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if req, exists := a.pending[requestID]; exists {
+		close(req.done)
+		delete(a.pending, requestID)
+	}
+}
+
+// dispatch 将代理端的响应分发给等待的请求
+func (a *agentConn) dispatch(msg *protocol.Message) bool {
+	a.mu.Lock()
+	req, exists := a.pending[msg.Header.RequestID]
+	a.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	// 请求方已退出时丢弃消息，避免阻塞代理端的读取
+	select {
+	case req.ch <- msg:
+	case <-req.done:
+	}
+	return true
+}
+
+// close 关闭代理端并通知所有等待的请求
+func (a *agentConn) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closed = true
+	for requestID, req := range a.pending {
+		close(req.ch)
+		delete(a.pending, requestID)
+	}
+}
+
+// serveAgent 处理代理端连接
+func (s *Server) serveAgent(client *Client) {
+	now := time.Now()
+	agent := &agentConn{
+		client:      client,
+		connectedAt: now,
+		lastSeen:    now,
+		pending:     make(map[string]*pendingRequest),
+	}
+
+	// 添加到客户端列表，服务器停止时统一关闭
+	s.clientsMu.Lock()
+	s.clients[client.sessionID] = client
+	s.clientsMu.Unlock()
+
+	s.hub.register(agent)
+	log.Printf("Agent %s connected from %s", client.clientInfo.ID, client.conn.RemoteAddr())
+
+	defer func() {
+		s.hub.unregister(agent)
+		agent.close()
+
+		s.clientsMu.Lock()
+		delete(s.clients, client.sessionID)
+		s.clientsMu.Unlock()
+		log.Printf("Agent %s disconnected", client.clientInfo.ID)
+	}()
+
+	for {
+		client.conn.SetReadDeadline(time.Now().Add(agentIdleTimeout))
+		msg, err := protocol.ReadMessage(client.conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading message from agent %s: %v", client.clientInfo.ID, err)
+			}
+			return
+		}
+
+		agent.mu.Lock()
+		agent.lastSeen = time.Now()
+		agent.mu.Unlock()
+
+		switch msg.Header.Type {
+		case protocol.HeartbeatRequest:
+			if err := s.handleHeartbeatRequest(client, msg.Header.RequestID, msg.Body, false); err != nil {
+				log.Printf("Error handling heartbeat from agent %s: %v", client.clientInfo.ID, err)
+				return
+			}
+		case protocol.CommandResponse, protocol.DataStream, protocol.ErrorResponse:
+			if !agent.dispatch(msg) {
+				log.Printf("Dropping message for unknown request %s from agent %s", msg.Header.RequestID, client.clientInfo.ID)
+			}
+		default:
+			log.Printf("Unexpected message type %d from agent %s", msg.Header.Type, client.clientInfo.ID)
+		}
+	}
+}
+
+// routeCommandToAgent 将命令请求转发到代理端，并将代理端的输出转发给客户端
+func (s *Server) routeCommandToAgent(client *Client, requestID string, cmdReq *protocol.CommandRequestBody, encrypted bool) error {
+	// 检查权限
+	if !client.clientInfo.HasAgentPermission(cmdReq.Agent) {
+		return fmt.Errorf("no permission to use agent: %s", cmdReq.Agent)
+	}
+
+	agent, err := s.hub.get(cmdReq.Agent)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, cmdReq.Agent)
+	}
+
+	// 使用独立的请求ID，避免与代理端的其他请求冲突
+	agentRequestID := uuid.New().String()
+	respCh, err := agent.addPending(agentRequestID)
+	if err != nil {
+		return err
+	}
+	defer agent.removePending(agentRequestID)
+
+	cmdMsg, err := protocol.NewCommandRequestMessage(agentRequestID, cmdReq.Plugin, cmdReq.Command, cmdReq.Args, cmdReq.Interactive, false)
+	if err != nil {
+		return fmt.Errorf("failed to create command request: %w", err)
+	}
+	if err := agent.client.writeMessage(cmdMsg); err != nil {
+		return fmt.Errorf("failed to forward command to agent %s: %w", cmdReq.Agent, err)
+	}
+
+	log.Printf("Forwarded command to agent %s: plugin=%s, command=%s", cmdReq.Agent, cmdReq.Plugin, cmdReq.Command)
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			return client.ctx.Err()
+		case msg, ok := <-respCh:
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrAgentDisconnected, cmdReq.Agent)
+			}
+
+			// 以客户端的请求ID转发代理端的响应
+			msg.Header.RequestID = requestID
+			if err := client.writeMessage(msg); err != nil {
+				return fmt.Errorf("failed to relay agent response: %w", err)
+			}
+
+			if msg.Header.Type == protocol.CommandResponse || msg.Header.Type == protocol.ErrorResponse {
+				return nil
+			}
+		}
+	}
+}
+
+// handleAgentListRequest 处理代理端列表请求
+func (s *Server) handleAgentListRequest(client *Client, requestID string, encrypted bool) error {
+	agents := make([]protocol.AgentInfo, 0)
+	for _, agent := range s.hub.list() {
+		info := agent.info()
+		if client.clientInfo.HasAgentPermission(info.ID) {
+			agents = append(agents, info)
+		}
+	}
+
+	respMsg, err := protocol.NewAgentListResponseMessage(requestID, agents, encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to create agent list response: %w", err)
+	}
+
+	return client.writeMessage(respMsg)
+}
+
+// ListAgents 列出已连接的代理端
+func (s *Server) ListAgents() []protocol.AgentInfo {
+	agents := s.hub.list()
+	infos := make([]protocol.AgentInfo, 0, len(agents))
+	for _, agent := range agents {
+		infos = append(infos, agent.info())
+	}
+	return infos
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sorc/tcpserver/internal/auth"
+	"github.com/sorc/tcpserver/pkg/client"
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+// testSecret 测试客户端共用的密钥
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// startHubServer 启动带认证和代理端注册表的服务器，返回服务器和监听地址
+func startHubServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	authManager := auth.NewAuthManager()
+	clients := map[string][]auth.Permission{
+		"edge":         {auth.PermAgentRegister},
+		"rogue":        {auth.PermPluginUse},
+		"ops":          {auth.PermAgentUse},
+		"edge-only":    {"agent:edge:use"},
+		"other-only":   {"agent:other:use"},
+		"plugin-users": {auth.PermPluginUse},
+	}
+	for id, perms := range clients {
+		if err := authManager.AddClient(&auth.Client{ID: id, Secret: testSecret, Name: id, Permissions: perms}); err != nil {
+			t.Fatalf("AddClient(%s): %v", id, err)
+		}
+	}
+
+	return startTestServer(t, &Server{
+		authManager: authManager,
+		clients:     make(map[string]*Client),
+		hub:         newAgentHub(),
+	}, nil)
+}
+
+// testAgent 测试用的代理端，回显收到的命令参数，收到drop命令时断开连接
+type testAgent struct {
+	received chan protocol.CommandRequestBody
+}
+
+// connectAgent 以代理端身份连接服务器，认证成功后在后台处理转发的命令
+func connectAgent(t *testing.T, addr, id string) (*testAgent, error) {
+	t.Helper()
+	conn := dial(t, addr, "")
+	if _, err := client.AuthenticateAgent(conn, id, testSecret); err != nil {
+		return nil, err
+	}
+
+	agent := &testAgent{received: make(chan protocol.CommandRequestBody, 10)}
+	go func() {
+		defer conn.Close()
+		for {
+			msg, err := protocol.ReadMessage(conn)
+			if err != nil {
+				return
+			}
+			if msg.Header.Type != protocol.CommandRequest {
+				continue
+			}
+			var req protocol.CommandRequestBody
+			if err := json.Unmarshal(msg.Body, &req); err != nil {
+				return
+			}
+			agent.received <- req
+			if req.Command == "drop" {
+				return
+			}
+
+			out := protocol.NewDataStreamMessage(msg.Header.RequestID, []byte(strings.Join(req.Args, " ")), false)
+			resp, _ := protocol.NewCommandResponseMessage(msg.Header.RequestID, true, "done", nil, false)
+			if protocol.WriteMessage(conn, out) != nil || protocol.WriteMessage(conn, resp) != nil {
+				return
+			}
+		}
+	}()
+	return agent, nil
+}
+
+// waitAgent 等待代理端在注册表中的状态变为registered
+func waitAgent(t *testing.T, s *Server, id string, registered bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := s.hub.get(id)
+		if (err == nil) == registered {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("agent %s registered = %v, want %v", id, err == nil, registered)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// connectClient 以普通客户端身份连接服务器，测试结束时关闭连接
+func connectClient(t *testing.T, addr, id string) *client.Client {
+	t.Helper()
+	c := client.New(client.Config{ServerAddr: addr, ClientID: id, Secret: testSecret})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect(%s): %v", id, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestAgentRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{name: "agent register permission", id: "edge"},
+		{name: "no agent register permission", id: "rogue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, addr := startHubServer(t)
+			_, err := connectAgent(t, addr, tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("agent without agent:register was authenticated")
+				}
+				if _, err := s.hub.get(tt.id); !errors.Is(err, ErrAgentNotFound) {
+					t.Errorf("hub.get(%s) error = %v, want %v", tt.id, err, ErrAgentNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("connectAgent: %v", err)
+			}
+			waitAgent(t, s, tt.id, true)
+		})
+	}
+}
+
+func TestAgentReconnectClosesPreviousConnection(t *testing.T) {
+	s, addr := startHubServer(t)
+
+	first := dial(t, addr, "")
+	if _, err := client.AuthenticateAgent(first, "edge", testSecret); err != nil {
+		t.Fatal(err)
+	}
+	waitAgent(t, s, "edge", true)
+	old, _ := s.hub.get("edge")
+
+	if _, err := connectAgent(t, addr, "edge"); err != nil {
+		t.Fatal(err)
+	}
+	if !closedByServer(first) {
+		t.Fatal("previous agent connection was not closed")
+	}
+	if current, err := s.hub.get("edge"); err != nil || current == old {
+		t.Errorf("hub.get(edge) = %p, %v; want the new connection", current, err)
+	}
+}
+
+func TestRouteCommandToAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  string
+		agent   string
+		wantErr string
+	}{
+		{name: "agent use", client: "ops", agent: "edge"},
+		{name: "scoped agent use", client: "edge-only", agent: "edge"},
+		{name: "other scoped agent use", client: "other-only", agent: "edge", wantErr: "no permission to use agent"},
+		{name: "plugin use only", client: "plugin-users", agent: "edge", wantErr: "no permission to use agent"},
+		{name: "unknown agent", client: "ops", agent: "missing", wantErr: ErrAgentNotFound.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, addr := startHubServer(t)
+			agent, err := connectAgent(t, addr, "edge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitAgent(t, s, "edge", true)
+
+			c := connectClient(t, addr, tt.client)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var output bytes.Buffer
+			result, err := c.Execute(ctx, tt.agent, "system", "echo", []string{"hello", "agent"}, &output)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				select {
+				case req := <-agent.received:
+					t.Errorf("refused command reached the agent: %+v", req)
+				default:
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !result.Success || result.Message != "done" {
+				t.Errorf("result = %+v, want success with message done", result)
+			}
+			if got := output.String(); got != "hello agent" {
+				t.Errorf("output = %q, want %q", got, "hello agent")
+			}
+			req := <-agent.received
+			if req.Plugin != "system" || req.Command != "echo" || req.Agent != "" {
+				t.Errorf("forwarded request = %+v", req)
+			}
+		})
+	}
+}
+
+func TestRouteCommandToDisconnectedAgent(t *testing.T) {
+	s, addr := startHubServer(t)
+	if _, err := connectAgent(t, addr, "edge"); err != nil {
+		t.Fatal(err)
+	}
+	waitAgent(t, s, "edge", true)
+
+	// 代理端收到命令后断开，等待中的请求以代理端断开结束
+	c := connectClient(t, addr, "ops")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Execute(ctx, "edge", "system", "drop", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), ErrAgentDisconnected.Error()) {
+		t.Fatalf("error = %v, want %v", err, ErrAgentDisconnected)
+	}
+	waitAgent(t, s, "edge", false)
+
+	// 断开后的代理端不能再接收命令
+	if _, err := c.Execute(ctx, "edge", "system", "echo", nil, nil); err == nil || !strings.Contains(err.Error(), ErrAgentNotFound.Error()) {
+		t.Errorf("error = %v, want %v", err, ErrAgentNotFound)
+	}
+}
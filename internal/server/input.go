@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// maxPendingInput 单个命令尚未读取的输入上限，超过时输入以错误结束，避免不读取输入的命令占用过多内存
+const maxPendingInput = 64 << 20
+
+var (
+	// errInputClosed 命令结束或客户端断开后继续写入输入时返回的错误
+	errInputClosed = errors.New("command input closed")
+	// errInputOverflow 命令读取输入过慢，未读取的输入超过上限
+	errInputOverflow = errors.New("command input buffer full, the command is not reading its input")
+)
+
+// commandInput 命令的输入缓冲。客户端的消息循环只向缓冲追加数据，不会因命令读取缓慢而阻塞
+type commandInput struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+	err    error
+}
+
+// newCommandInput 创建命令的输入缓冲
+func newCommandInput() *commandInput {
+	in := &commandInput{}
+	in.cond = sync.NewCond(&in.mu)
+	return in
+}
+
+// Read 读取输入，无数据时阻塞直到写入或关闭
+func (in *commandInput) Read(p []byte) (int, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for in.buf.Len() == 0 {
+		if in.err != nil {
+			return 0, in.err
+		}
+		if in.closed {
+			return 0, io.EOF
+		}
+		in.cond.Wait()
+	}
+	return in.buf.Read(p)
+}
+
+// write 追加输入，关闭后写入的数据被丢弃
+func (in *commandInput) write(data []byte) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return
+	}
+	if in.buf.Len()+len(data) > maxPendingInput {
+		in.abort(errInputOverflow)
+		return
+	}
+	in.buf.Write(data)
+	in.cond.Broadcast()
+}
+
+// close 输入结束，已写入的数据仍可读取
+func (in *commandInput) close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.closed = true
+	in.cond.Broadcast()
+}
+
+// closeWithError 丢弃未读取的数据，之后的读取返回err
+func (in *commandInput) closeWithError(err error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.abort(err)
+}
+
+// abort 丢弃未读取的数据并设置读取错误，调用方需持有锁
+func (in *commandInput) abort(err error) {
+	in.closed = true
+	if in.err == nil {
+		in.err = err
+	}
+	in.buf.Reset()
+	in.cond.Broadcast()
+}
+
+// openInput 登记命令的输入缓冲，客户端随后发送的数据流消息写入缓冲
+func (c *Client) openInput(requestID string) *commandInput {
+	in := newCommandInput()
+
+	c.inputsMu.Lock()
+	defer c.inputsMu.Unlock()
+	if c.inputs == nil {
+		c.inputs = make(map[string]*commandInput)
+	}
+	c.inputs[requestID] = in
+	return in
+}
+
+// closeInput 注销命令的输入缓冲，命令结束时调用
+func (c *Client) closeInput(requestID string, in *commandInput) {
+	c.inputsMu.Lock()
+	delete(c.inputs, requestID)
+	c.inputsMu.Unlock()
+	in.closeWithError(errInputClosed)
+}
+
+// closeInputs 关闭所有输入缓冲，客户端断开时调用，正在读取输入的命令得到io.ErrUnexpectedEOF
+func (c *Client) closeInputs() {
+	c.inputsMu.Lock()
+	defer c.inputsMu.Unlock()
+	for id, in := range c.inputs {
+		in.closeWithError(io.ErrUnexpectedEOF)
+		delete(c.inputs, id)
+	}
+}
+
+// writeInput 将客户端发送的数据追加到命令的输入缓冲，空数据表示输入结束。
+// 命令不再读取输入时写入的数据被丢弃
+func (c *Client) writeInput(requestID string, data []byte) {
+	c.inputsMu.Lock()
+	in, exists := c.inputs[requestID]
+	if exists && len(data) == 0 {
+		delete(c.inputs, requestID)
+	}
+	c.inputsMu.Unlock()
+
+	if !exists {
+		return
+	}
+	if len(data) == 0 {
+		in.close()
+		return
+	}
+	in.write(data)
+}
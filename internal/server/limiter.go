@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrTooManyConnections       = errors.New("too many connections")
+	ErrTooManyUnauthConnections = errors.New("too many unauthenticated connections")
+	ErrTooManyConnectionsPerIP  = errors.New("too many connections from this address")
+)
+
+// ConnStats 连接统计信息
+type ConnStats struct {
+	Active          int    `json:"active"`
+	Unauthenticated int    `json:"unauthenticated"`
+	RejectedTotal   uint64 `json:"rejected_total"`
+	RejectedUnauth  uint64 `json:"rejected_unauth"`
+	RejectedPerIP   uint64 `json:"rejected_per_ip"`
+}
+
+// connLimiter 连接准入控制，限制值为0表示不限制
+type connLimiter struct {
+	maxTotal  int
+	maxUnauth int
+	maxPerIP  int
+
+	mu     sync.Mutex
+	total  int
+	unauth int
+	perIP  map[string]int
+
+	rejectedTotal  uint64
+	rejectedUnauth uint64
+	rejectedPerIP  uint64
+}
+
+// newConnLimiter 创建连接准入控制器
+func newConnLimiter(maxTotal, maxUnauth, maxPerIP int) *connLimiter {
+	return &connLimiter{
+		maxTotal:  maxTotal,
+		maxUnauth: maxUnauth,
+		maxPerIP:  maxPerIP,
+		perIP:     make(map[string]int),
+	}
+}
+
+// acquire 为新连接申请全局和未认证连接名额
+func (l *connLimiter) acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		atomic.AddUint64(&l.rejectedTotal, 1)
+		return ErrTooManyConnections
+	}
+	if l.maxUnauth > 0 && l.unauth >= l.maxUnauth {
+		atomic.AddUint64(&l.rejectedUnauth, 1)
+		return ErrTooManyUnauthConnections
+	}
+
+	l.total++
+	l.unauth++
+	return nil
+}
+
+// acquireIP 为连接申请单IP连接名额，ip为空时不限制
+func (l *connLimiter) acquireIP(ip string) error {
+	if ip == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		atomic.AddUint64(&l.rejectedPerIP, 1)
+		return ErrTooManyConnectionsPerIP
+	}
+
+	l.perIP[ip]++
+	return nil
+}
+
+// authenticated 连接认证成功，释放未认证连接名额
+func (l *connLimiter) authenticated() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unauth--
+}
+
+// release 释放连接占用的名额
+func (l *connLimiter) release(authed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if !authed {
+		l.unauth--
+	}
+}
+
+// releaseIP 释放单IP连接名额
+func (l *connLimiter) releaseIP(ip string) {
+	if ip == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perIP[ip] <= 1 {
+		delete(l.perIP, ip)
+	} else {
+		l.perIP[ip]--
+	}
+}
+
+// stats 返回连接统计信息
+func (l *connLimiter) stats() ConnStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ConnStats{
+		Active:          l.total,
+		Unauthenticated: l.unauth,
+		RejectedTotal:   atomic.LoadUint64(&l.rejectedTotal),
+		RejectedUnauth:  atomic.LoadUint64(&l.rejectedUnauth),
+		RejectedPerIP:   atomic.LoadUint64(&l.rejectedPerIP),
+	}
+}
+
+// remoteIP 返回用于单IP限制的地址，非IP连接返回空字符串
+func remoteIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// limiterOp 对连接准入控制器执行的操作
+type limiterOp struct {
+	// action 为acquire、acquire_ip、auth、release、release_authed或release_ip
+	action  string
+	ip      string
+	wantErr error
+}
+
+func TestConnLimiter(t *testing.T) {
+	tests := []struct {
+		name                       string
+		maxTotal, maxUnauth, maxIP int
+		ops                        []limiterOp
+		wantActive, wantUnauth     int
+		wantRejected               ConnStats
+	}{
+		{
+			name:     "global limit",
+			maxTotal: 2,
+			ops: []limiterOp{
+				{action: "acquire"},
+				{action: "acquire"},
+				{action: "acquire", wantErr: ErrTooManyConnections},
+			},
+			wantActive:   2,
+			wantUnauth:   2,
+			wantRejected: ConnStats{RejectedTotal: 1},
+		},
+		{
+			name:     "release frees global slot",
+			maxTotal: 1,
+			ops: []limiterOp{
+				{action: "acquire"},
+				{action: "acquire", wantErr: ErrTooManyConnections},
+				{action: "release"},
+				{action: "acquire"},
+			},
+			wantActive:   1,
+			wantUnauth:   1,
+			wantRejected: ConnStats{RejectedTotal: 1},
+		},
+		{
+			name:      "authentication frees unauthenticated slot",
+			maxUnauth: 1,
+			ops: []limiterOp{
+				{action: "acquire"},
+				{action: "acquire", wantErr: ErrTooManyUnauthConnections},
+				{action: "auth"},
+				{action: "acquire"},
+				{action: "release_authed"},
+			},
+			wantActive:   1,
+			wantUnauth:   1,
+			wantRejected: ConnStats{RejectedUnauth: 1},
+		},
+		{
+			name:  "per ip limit",
+			maxIP: 1,
+			ops: []limiterOp{
+				{action: "acquire_ip", ip: "192.0.2.1"},
+				{action: "acquire_ip", ip: "192.0.2.1", wantErr: ErrTooManyConnectionsPerIP},
+				{action: "acquire_ip", ip: "192.0.2.2"},
+				{action: "release_ip", ip: "192.0.2.1"},
+				{action: "acquire_ip", ip: "192.0.2.1"},
+			},
+			wantRejected: ConnStats{RejectedPerIP: 1},
+		},
+		{
+			name:  "empty ip is not limited",
+			maxIP: 1,
+			ops: []limiterOp{
+				{action: "acquire_ip"},
+				{action: "acquire_ip"},
+			},
+		},
+		{
+			name: "unlimited",
+			ops: []limiterOp{
+				{action: "acquire"},
+				{action: "acquire"},
+				{action: "acquire_ip", ip: "192.0.2.1"},
+				{action: "acquire_ip", ip: "192.0.2.1"},
+			},
+			wantActive: 2,
+			wantUnauth: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newConnLimiter(tt.maxTotal, tt.maxUnauth, tt.maxIP)
+			for i, op := range tt.ops {
+				var err error
+				switch op.action {
+				case "acquire":
+					err = l.acquire()
+				case "acquire_ip":
+					err = l.acquireIP(op.ip)
+				case "auth":
+					l.authenticated()
+				case "release":
+					l.release(false)
+				case "release_authed":
+					l.release(true)
+				case "release_ip":
+					l.releaseIP(op.ip)
+				default:
+					t.Fatalf("unknown action %s", op.action)
+				}
+				if !errors.Is(err, op.wantErr) {
+					t.Fatalf("op %d %s %s: error = %v, want %v", i, op.action, op.ip, err, op.wantErr)
+				}
+			}
+
+			stats := l.stats()
+			if stats.Active != tt.wantActive || stats.Unauthenticated != tt.wantUnauth {
+				t.Errorf("active %d, unauthenticated %d; want %d, %d", stats.Active, stats.Unauthenticated, tt.wantActive, tt.wantUnauth)
+			}
+			if stats.RejectedTotal != tt.wantRejected.RejectedTotal || stats.RejectedUnauth != tt.wantRejected.RejectedUnauth || stats.RejectedPerIP != tt.wantRejected.RejectedPerIP {
+				t.Errorf("rejected total %d, unauth %d, per ip %d; want %d, %d, %d",
+					stats.RejectedTotal, stats.RejectedUnauth, stats.RejectedPerIP,
+					tt.wantRejected.RejectedTotal, tt.wantRejected.RejectedUnauth, tt.wantRejected.RejectedPerIP)
+			}
+		})
+	}
+}
+
+// startLimitedServer 启动只做准入控制和等待认证的服务器，返回监听地址
+func startLimitedServer(t *testing.T, limiter *connLimiter, wrap func(net.Listener) net.Listener) (*Server, string) {
+	t.Helper()
+	return startTestServer(t, &Server{limiter: limiter}, wrap)
+}
+
+// startTestServer 在本地回环地址上运行服务器并接受连接，返回监听地址，测试结束时停止
+func startTestServer(t *testing.T, s *Server, wrap func(net.Listener) net.Listener) (*Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if s.limiter == nil {
+		s.limiter = newConnLimiter(0, 0, 0)
+	}
+	if s.authTimeout == 0 {
+		s.authTimeout = 5 * time.Second
+	}
+
+	listener := ln
+	if wrap != nil {
+		listener = wrap(ln)
+	}
+	go s.acceptConnections(listener)
+	t.Cleanup(func() {
+		s.cancel()
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s, ln.Addr().String()
+}
+
+// dial 连接服务器并发送数据，测试结束时关闭连接
+func dial(t *testing.T, addr string, data string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if data != "" {
+		if _, err := conn.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
+
+// closedByServer 判断服务器是否已关闭连接，服务器保持连接等待认证时返回false
+func closedByServer(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	return !(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// waitActive 等待服务器的活动连接数变为want
+func waitActive(t *testing.T, s *Server, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.limiter.stats().Active != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("active connections = %d, want %d", s.limiter.stats().Active, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerReleasesLimitOnClose(t *testing.T) {
+	s, addr := startLimitedServer(t, newConnLimiter(1, 0, 1), nil)
+
+	first := dial(t, addr, "")
+	waitActive(t, s, 1)
+	if closedByServer(first) {
+		t.Fatal("first connection was rejected")
+	}
+	if second := dial(t, addr, ""); !closedByServer(second) {
+		t.Fatal("connection over the global limit was accepted")
+	}
+
+	// 关闭连接后释放全局和单IP名额
+	first.Close()
+	waitActive(t, s, 0)
+	if third := dial(t, addr, ""); closedByServer(third) {
+		t.Fatal("connection was rejected after the previous one closed")
+	}
+	if stats := s.limiter.stats(); stats.RejectedTotal != 1 || stats.RejectedPerIP != 0 {
+		t.Errorf("stats = %+v, want one rejection by the global limit", stats)
+	}
+}
+
+func TestServerLimitsProxiedClientsByHeaderAddress(t *testing.T) {
+	wrap := func(ln net.Listener) net.Listener {
+		pl, err := newProxyProtoListener(ln, []string{"127.0.0.1/32"}, false, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pl
+	}
+	s, addr := startLimitedServer(t, newConnLimiter(0, 0, 1), wrap)
+	header := func(src string) string {
+		return fmt.Sprintf("PROXY TCP4 %s 198.51.100.1 56324 8888\r\n", src)
+	}
+
+	// 所有连接都来自127.0.0.1，单IP限制按PROXY协议头中的客户端地址计算
+	first := dial(t, addr, header("192.0.2.1"))
+	if closedByServer(first) {
+		t.Fatal("first proxied client was rejected")
+	}
+	if same := dial(t, addr, header("192.0.2.1")); !closedByServer(same) {
+		t.Fatal("second connection from the same proxied client was accepted")
+	}
+	if other := dial(t, addr, header("192.0.2.2")); closedByServer(other) {
+		t.Fatal("connection from another proxied client was rejected")
+	}
+
+	if stats := s.limiter.stats(); stats.RejectedPerIP != 1 {
+		t.Errorf("rejected per ip = %d, want 1", stats.RejectedPerIP)
+	}
+	// 被拒绝的连接释放了全局名额
+	waitActive(t, s, 2)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ListenerConfig 监听器配置
+type ListenerConfig struct {
+	// Network 网络类型：tcp（IPv4/IPv6双栈）、tcp4、tcp6（仅IPv6）、unix
+	Network string `json:"network"`
+	// Addr 监听地址，unix类型时为套接字文件路径
+	Addr string `json:"addr"`
+	// TLS TLS配置，为空时使用明文连接
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// SocketMode Unix域套接字文件权限（八进制字符串，如"0660"）
+	SocketMode string `json:"socket_mode,omitempty"`
+	// ProxyProtocol 是否解析PROXY协议v1/v2头部
+	ProxyProtocol bool `json:"proxy_protocol,omitempty"`
+	// TrustedProxies 允许发送PROXY协议头的上游地址（CIDR或IP）
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// TrustUnixPeers 是否允许Unix域套接字的对端发送PROXY协议头，默认不信任
+	TrustUnixPeers bool `json:"trust_unix_peers,omitempty"`
+	// ProxyHeaderTimeout 读取PROXY协议头的超时时间（秒）
+	ProxyHeaderTimeout int `json:"proxy_header_timeout,omitempty"`
+}
+
+// TLSConfig TLS监听配置
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ClientCAFile 用于验证客户端证书的CA文件，设置后启用mTLS
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+	// RequireClientCert 是否强制要求客户端证书
+	RequireClientCert bool `json:"require_client_cert,omitempty"`
+}
+
+// String 返回监听器描述
+func (lc ListenerConfig) String() string {
+	s := fmt.Sprintf("%s://%s", lc.Network, lc.Addr)
+	if lc.ProxyProtocol {
+		s += " (proxy-protocol)"
+	}
+	if lc.TLS != nil {
+		s += " (tls)"
+	}
+	return s
+}
+
+// listenerConfigs 返回服务器的监听器配置列表，未配置listeners时使用addr
+func (c ServerConfig) listenerConfigs() []ListenerConfig {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+	if c.Addr == "" {
+		return nil
+	}
+	return []ListenerConfig{{Network: "tcp", Addr: c.Addr}}
+}
+
+// newListener 根据配置创建监听器
+func newListener(lc ListenerConfig) (net.Listener, error) {
+	if lc.Network == "" {
+		lc.Network = "tcp"
+	}
+
+	var listener net.Listener
+	var err error
+
+	switch lc.Network {
+	case "tcp", "tcp4", "tcp6":
+		listener, err = net.Listen(lc.Network, lc.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", lc, err)
+		}
+	case "unix":
+		listener, err = newUnixListener(lc)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported listener network: %s", lc.Network)
+	}
+
+	// 解析PROXY协议头（必须在TLS之前）
+	if lc.ProxyProtocol {
+		timeout := time.Duration(lc.ProxyHeaderTimeout) * time.Second
+		proxyListener, err := newProxyProtoListener(listener, lc.TrustedProxies, lc.TrustUnixPeers, timeout)
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("invalid proxy protocol config for %s: %w", lc, err)
+		}
+		listener = proxyListener
+	}
+
+	// 包装TLS
+	if lc.TLS != nil {
+		tlsConfig, err := loadTLSConfig(lc.TLS)
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to load tls config for %s: %w", lc, err)
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	return listener, nil
+}
+
+// newUnixListener 创建Unix域套接字监听器
+func newUnixListener(lc ListenerConfig) (net.Listener, error) {
+	// 清理残留的套接字文件
+	if info, err := os.Lstat(lc.Addr); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("failed to listen on %s: path exists and is not a socket", lc)
+		}
+		if err := os.Remove(lc.Addr); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", lc.Addr, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", lc.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", lc, err)
+	}
+
+	// 设置套接字文件权限
+	if lc.SocketMode != "" {
+		mode, err := strconv.ParseUint(lc.SocketMode, 8, 32)
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("invalid socket mode %q: %w", lc.SocketMode, err)
+		}
+		if err := os.Chmod(lc.Addr, os.FileMode(mode)); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to chmod socket %s: %w", lc.Addr, err)
+		}
+	}
+
+	return listener, nil
+}
+
+// loadTLSConfig 加载TLS配置
+func loadTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("cert_file and key_file are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// 客户端证书验证（mTLS）
+	if c.ClientCAFile != "" {
+		caBytes, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		if c.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	} else if c.RequireClientCert {
+		return nil, errors.New("require_client_cert needs client_ca_file")
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+)
+
+// proxyV2Signature PROXY协议v2签名
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	// proxyV1MaxLen PROXY协议v1头部最大长度
+	proxyV1MaxLen = 107
+	// defaultProxyHeaderTimeout 读取PROXY协议头的默认超时时间
+	defaultProxyHeaderTimeout = 10 * time.Second
+)
+
+// proxyProtoListener 解析PROXY协议头的监听器
+type proxyProtoListener struct {
+	net.Listener
+	trusted   []*net.IPNet
+	trustUnix bool
+	timeout   time.Duration
+}
+
+// newProxyProtoListener 创建PROXY协议监听器，trustUnix为true时信任Unix域套接字的对端
+func newProxyProtoListener(listener net.Listener, trustedProxies []string, trustUnix bool, timeout time.Duration) (*proxyProtoListener, error) {
+	if len(trustedProxies) == 0 && !trustUnix {
+		return nil, errors.New("proxy_protocol requires trusted_proxies or trust_unix_peers")
+	}
+
+	trusted, err := parseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = defaultProxyHeaderTimeout
+	}
+
+	return &proxyProtoListener{
+		Listener:  listener,
+		trusted:   trusted,
+		trustUnix: trustUnix,
+		timeout:   timeout,
+	}, nil
+}
+
+// Accept 接受连接，仅对可信上游的连接解析PROXY协议头
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	return &proxyConn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: l.timeout,
+	}, nil
+}
+
+// isTrusted 检查对端是否为可信上游
+// Unix域套接字的对端没有IP地址，只有显式配置trust_unix_peers时才信任
+func (l *proxyProtoListener) isTrusted(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UnixAddr:
+		return l.trustUnix
+	default:
+		return false
+	}
+
+	for _, n := range l.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn 带PROXY协议头的连接
+// 头部在第一次Read、RemoteAddr或LocalAddr时读取，读取期间使用独立的超时时间，
+// 因此调用方应在设置自己的读超时之前先调用RemoteAddr。
+type proxyConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	timeout    time.Duration
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+// readHeader 读取PROXY协议头
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		c.remoteAddr, c.localAddr, c.err = readProxyHeader(c.reader)
+		if c.err != nil {
+			c.err = fmt.Errorf("%w from %s: %v", ErrInvalidProxyHeader, c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+// Read 读取数据
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr 返回PROXY协议头中的客户端地址
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr 返回PROXY协议头中的目标地址
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.readHeader()
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// rawConn 返回TLS和PROXY协议包装下的底层连接
+func rawConn(conn net.Conn) net.Conn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.Conn
+	}
+	return conn
+}
+
+// hasProxyHeader 判断连接是否来自可信上游、需要读取PROXY协议头
+func hasProxyHeader(conn net.Conn) bool {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	_, ok := conn.(*proxyConn)
+	return ok
+}
+
+// peerAddr 返回底层连接的对端地址，不会读取PROXY协议头
+func peerAddr(conn net.Conn) net.Addr {
+	return rawConn(conn).RemoteAddr()
+}
+
+// readProxyHeader 读取PROXY协议v1或v2头部
+// 返回的地址为nil时表示使用连接的真实地址（LOCAL命令或UNKNOWN协议）
+func readProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(sig, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+
+	prefix, err := r.Peek(6)
+	if err != nil {
+		return nil, nil, err
+	}
+	if string(prefix) == "PROXY " {
+		return readProxyHeaderV1(r)
+	}
+
+	return nil, nil, errors.New("missing header")
+}
+
+// readProxyHeaderV1 读取文本格式的PROXY协议v1头部
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("v1 header too long or not terminated")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 {
+		return nil, nil, errors.New("malformed v1 header")
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, nil, fmt.Errorf("unsupported v1 protocol: %s", fields[1])
+	}
+
+	if len(fields) != 6 {
+		return nil, nil, errors.New("malformed v1 header")
+	}
+
+	srcIP := net.ParseIP(fields[2])
+	dstIP := net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil {
+		return nil, nil, errors.New("invalid v1 address")
+	}
+	srcPort, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid v1 source port: %w", err)
+	}
+	dstPort, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid v1 destination port: %w", err)
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+// readProxyHeaderV2 读取二进制格式的PROXY协议v2头部
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+
+	verCmd := header[12]
+	if verCmd>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported v2 version: %d", verCmd>>4)
+	}
+
+	length := binary.BigEndian.Uint16(header[14:16])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch verCmd & 0x0F {
+	case 0x0:
+		// LOCAL命令：上游自身发起的连接（如健康检查），使用真实地址
+		return nil, nil, nil
+	case 0x1:
+	default:
+		return nil, nil, fmt.Errorf("unsupported v2 command: %d", verCmd&0x0F)
+	}
+
+	// 服务器只接受流式连接，地址族不为AF_UNSPEC时传输协议必须为STREAM
+	family, transport := header[13]>>4, header[13]&0x0F
+	if family != 0x0 && transport != 0x1 {
+		return nil, nil, fmt.Errorf("unsupported v2 transport: %d", transport)
+	}
+
+	switch family {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, nil, errors.New("short v2 ipv4 address block")
+		}
+		src := &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		dst := &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+		return src, dst, nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, nil, errors.New("short v2 ipv6 address block")
+		}
+		src := &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		dst := &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+		return src, dst, nil
+	default:
+		// AF_UNSPEC及AF_UNIX：使用真实地址
+		return nil, nil, nil
+	}
+}
+
+// parseCIDRs 解析CIDR列表，单个IP地址视为主机地址
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %s", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy cidr %s: %w", v, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
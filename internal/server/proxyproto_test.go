@@ -0,0 +1,389 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// v2Header 构造PROXY协议v2头部
+func v2Header(verCmd, famTrans byte, payload []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, verCmd, famTrans, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(payload)))
+	return append(header, payload...)
+}
+
+// v2IPv4 构造v2的IPv4地址块
+func v2IPv4(src, dst string, srcPort, dstPort uint16) []byte {
+	b := append([]byte{}, net.ParseIP(src).To4()...)
+	b = append(b, net.ParseIP(dst).To4()...)
+	b = binary.BigEndian.AppendUint16(b, srcPort)
+	return binary.BigEndian.AppendUint16(b, dstPort)
+}
+
+// v2IPv6 构造v2的IPv6地址块
+func v2IPv6(src, dst string, srcPort, dstPort uint16) []byte {
+	b := append([]byte{}, net.ParseIP(src).To16()...)
+	b = append(b, net.ParseIP(dst).To16()...)
+	b = binary.BigEndian.AppendUint16(b, srcPort)
+	return binary.BigEndian.AppendUint16(b, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		src     string
+		dst     string
+		wantErr bool
+	}{
+		{
+			name:  "v1 tcp4",
+			input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			src:   "192.0.2.1:56324",
+			dst:   "198.51.100.1:443",
+		},
+		{
+			name:  "v1 tcp6",
+			input: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			src:   "[2001:db8::1]:56324",
+			dst:   "[2001:db8::2]:443",
+		},
+		{
+			name:  "v1 unknown",
+			input: []byte("PROXY UNKNOWN\r\n"),
+		},
+		{
+			name:  "v1 unknown with addresses",
+			input: []byte("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n"),
+		},
+		{
+			name:    "v1 truncated",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing crlf",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 too long",
+			input:   []byte("PROXY TCP4 " + strings.Repeat("1", proxyV1MaxLen) + "\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing ports",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 invalid address",
+			input:   []byte("PROXY TCP4 192.0.2.x 198.51.100.1 56324 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 invalid port",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 70000 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 unsupported protocol",
+			input:   []byte("PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "missing header",
+			input:   []byte("GET / HTTP/1.1\r\n"),
+			wantErr: true,
+		},
+		{
+			name:  "v2 tcp4",
+			input: v2Header(0x21, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+			src:   "192.0.2.1:56324",
+			dst:   "198.51.100.1:443",
+		},
+		{
+			name:  "v2 tcp6",
+			input: v2Header(0x21, 0x21, v2IPv6("2001:db8::1", "2001:db8::2", 56324, 443)),
+			src:   "[2001:db8::1]:56324",
+			dst:   "[2001:db8::2]:443",
+		},
+		{
+			name:  "v2 tcp4 with tlvs",
+			input: v2Header(0x21, 0x11, append(v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443), 0x04, 0x00, 0x01, 0xFF)),
+			src:   "192.0.2.1:56324",
+			dst:   "198.51.100.1:443",
+		},
+		{
+			name:  "v2 local",
+			input: v2Header(0x20, 0x00, nil),
+		},
+		{
+			name:  "v2 local with addresses",
+			input: v2Header(0x20, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+		},
+		{
+			name:  "v2 unspec",
+			input: v2Header(0x21, 0x00, nil),
+		},
+		{
+			name:    "v2 udp4",
+			input:   v2Header(0x21, 0x12, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 udp6",
+			input:   v2Header(0x21, 0x22, v2IPv6("2001:db8::1", "2001:db8::2", 56324, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 truncated header",
+			input:   v2Header(0x21, 0x11, nil)[:14],
+			wantErr: true,
+		},
+		{
+			name:    "v2 truncated payload",
+			input:   v2Header(0x21, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443))[:20],
+			wantErr: true,
+		},
+		{
+			name:    "v2 short ipv4 block",
+			input:   v2Header(0x21, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)[:8]),
+			wantErr: true,
+		},
+		{
+			name:    "v2 short ipv6 block",
+			input:   v2Header(0x21, 0x21, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 unsupported version",
+			input:   v2Header(0x11, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 unsupported command",
+			input:   v2Header(0x22, 0x11, v2IPv4("192.0.2.1", "198.51.100.1", 56324, 443)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(tt.input), strings.NewReader("payload")))
+			src, dst, err := readProxyHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got src=%v dst=%v", src, dst)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := addrString(src); got != tt.src {
+				t.Errorf("src = %q, want %q", got, tt.src)
+			}
+			if got := addrString(dst); got != tt.dst {
+				t.Errorf("dst = %q, want %q", got, tt.dst)
+			}
+
+			// 头部之后的数据应保持不变
+			rest, _ := io.ReadAll(r)
+			if string(rest) != "payload" {
+				t.Errorf("data after header = %q, want %q", rest, "payload")
+			}
+		})
+	}
+}
+
+// addrString 返回地址的字符串形式，nil返回空字符串
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func TestProxyProtoListenerIsTrusted(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   []string
+		trustUnix bool
+		addr      net.Addr
+		want      bool
+	}{
+		{
+			name:    "trusted host",
+			trusted: []string{"10.0.0.1"},
+			addr:    &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+			want:    true,
+		},
+		{
+			name:    "trusted cidr",
+			trusted: []string{"10.0.0.0/8"},
+			addr:    &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1234},
+			want:    true,
+		},
+		{
+			name:    "untrusted host",
+			trusted: []string{"10.0.0.0/8"},
+			addr:    &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+		},
+		{
+			name:    "trusted ipv6 cidr",
+			trusted: []string{"2001:db8::/32"},
+			addr:    &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234},
+			want:    true,
+		},
+		{
+			name:    "unix peer without trust_unix_peers",
+			trusted: []string{"10.0.0.0/8"},
+			addr:    &net.UnixAddr{Name: "@", Net: "unix"},
+		},
+		{
+			name:      "unix peer with trust_unix_peers",
+			trustUnix: true,
+			addr:      &net.UnixAddr{Name: "@", Net: "unix"},
+			want:      true,
+		},
+		{
+			name:      "tcp peer with only trust_unix_peers",
+			trustUnix: true,
+			addr:      &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newProxyProtoListener(nil, tt.trusted, tt.trustUnix, 0)
+			if err != nil {
+				t.Fatalf("newProxyProtoListener: %v", err)
+			}
+			if got := l.isTrusted(tt.addr); got != tt.want {
+				t.Errorf("isTrusted(%v) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProxyProtoListenerRequiresTrust(t *testing.T) {
+	if _, err := newProxyProtoListener(nil, nil, false, 0); err == nil {
+		t.Fatal("expected error without trusted_proxies or trust_unix_peers")
+	}
+	if _, err := newProxyProtoListener(nil, []string{"not-an-ip"}, false, 0); err == nil {
+		t.Fatal("expected error for invalid trusted proxy")
+	}
+}
+
+// pipeListener 返回预先创建的连接的监听器
+type pipeListener struct {
+	conns chan net.Conn
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return conn, nil
+}
+
+func (l *pipeListener) Close() error   { return nil }
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+// addrConn 使用指定对端地址的连接
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestProxyProtoListenerAccept(t *testing.T) {
+	header := "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+	tests := []struct {
+		name       string
+		peer       string
+		input      string
+		remoteAddr string
+		data       string
+		wantErr    bool
+	}{
+		{
+			name:       "trusted peer",
+			peer:       "10.0.0.1",
+			input:      header + "hello",
+			remoteAddr: "192.0.2.1:56324",
+			data:       "hello",
+		},
+		{
+			// 不可信对端发送的PROXY头按普通数据处理，不能伪造客户端地址
+			name:       "untrusted peer",
+			peer:       "203.0.113.1",
+			input:      header + "hello",
+			remoteAddr: "203.0.113.1:1234",
+			data:       header + "hello",
+		},
+		{
+			name:       "trusted peer without header",
+			peer:       "10.0.0.1",
+			input:      "hello",
+			remoteAddr: "10.0.0.1:1234",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+
+			pl := &pipeListener{conns: make(chan net.Conn, 1)}
+			pl.conns <- &addrConn{Conn: server, remote: &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 1234}}
+			l, err := newProxyProtoListener(pl, []string{"10.0.0.0/8"}, false, time.Second)
+			if err != nil {
+				t.Fatalf("newProxyProtoListener: %v", err)
+			}
+
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatalf("Accept: %v", err)
+			}
+			defer conn.Close()
+
+			go func() {
+				client.Write([]byte(tt.input))
+				client.Close()
+			}()
+
+			if got := conn.RemoteAddr().String(); got != tt.remoteAddr {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.remoteAddr)
+			}
+			if got := peerAddr(conn).String(); got != tt.peer+":1234" {
+				t.Errorf("peerAddr = %q, want %q", got, tt.peer+":1234")
+			}
+
+			data, err := io.ReadAll(conn)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, read %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(data) != tt.data {
+				t.Errorf("data = %q, want %q", data, tt.data)
+			}
+		})
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,8 +23,8 @@ import (
 
 // Server TCP服务器
 type Server struct {
-	listener      net.Listener
-	addr          string
+	listeners     []net.Listener
+	listenerCfgs  []ListenerConfig
 	authManager   *auth.AuthManager
 	pluginManager plugin.PluginManager
 	clients       map[string]*Client
@@ -32,6 +34,12 @@ type Server struct {
 	wg            sync.WaitGroup
 	pluginsDir    string
 	configDir     string
+	limiter       *connLimiter
+	authTimeout   time.Duration
+	hub           *agentHub
+	agentConfig   *AgentConfig
+	healthAddr    string
+	healthServer  *http.Server
 }
 
 // Client 客户端连接
@@ -40,15 +48,64 @@ type Client struct {
 	sessionID  string
 	clientInfo *auth.Client
 	cipher     *crypto.XXTEACipher
+	role       string
 	ctx        context.Context
 	cancel     context.CancelFunc
+	writeMu    sync.Mutex
+	// inputs 按请求ID登记的命令输入缓冲
+	inputs   map[string]*commandInput
+	inputsMu sync.Mutex
+}
+
+// writeMessage 向客户端写入消息，多个goroutine可以并发调用
+func (c *Client) writeMessage(msg *protocol.Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return protocol.WriteMessage(c.conn, msg)
+}
+
+// caller 返回提供给插件的客户端身份
+func (c *Client) caller() *plugin.Caller {
+	caller := &plugin.Caller{
+		ClientID:   c.clientInfo.ID,
+		SessionID:  c.sessionID,
+		RemoteAddr: c.conn.RemoteAddr().String(),
+	}
+	for _, perm := range c.clientInfo.Permissions {
+		caller.Permissions = append(caller.Permissions, string(perm))
+	}
+	return caller
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Addr       string `json:"addr"`
-	PluginsDir string `json:"plugins_dir"`
-	ConfigDir  string `json:"config_dir"`
+	Addr       string           `json:"addr"`
+	Listeners  []ListenerConfig `json:"listeners,omitempty"`
+	PluginsDir string           `json:"plugins_dir"`
+	ConfigDir  string           `json:"config_dir"`
+	// DataDir 插件数据目录的根目录，每个插件使用其中的<插件ID>子目录，默认为data
+	DataDir string `json:"data_dir,omitempty"`
+
+	// MaxConnections 最大连接数，0表示不限制
+	MaxConnections int `json:"max_connections,omitempty"`
+	// MaxUnauthConnections 最大未认证连接数，0表示不限制
+	MaxUnauthConnections int `json:"max_unauth_connections,omitempty"`
+	// MaxConnectionsPerIP 单个IP最大连接数，0表示不限制
+	MaxConnectionsPerIP int `json:"max_connections_per_ip,omitempty"`
+	// AuthTimeout 认证超时时间（秒），默认30秒
+	AuthTimeout int `json:"auth_timeout,omitempty"`
+
+	// Agent 代理模式配置，配置后主动连接中心节点
+	Agent *AgentConfig `json:"agent,omitempty"`
+
+	// Plugins 按插件ID配置的运行策略（自动启动和重启策略）
+	Plugins map[string]plugin.PluginPolicy `json:"plugins,omitempty"`
+
+	// PluginSigning 插件签名校验配置
+	PluginSigning plugin.TrustConfig `json:"plugin_signing"`
+
+	// HealthAddr 健康检查HTTP服务的监听地址，提供/healthz和/readyz，为空时不启动
+	HealthAddr string `json:"health_addr,omitempty"`
 }
 
 // NewServer 创建新的服务器
@@ -63,8 +120,30 @@ func NewServer(config ServerConfig, pluginManager plugin.PluginManager) (*Server
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	authTimeout := 30 * time.Second
+	if config.AuthTimeout > 0 {
+		authTimeout = time.Duration(config.AuthTimeout) * time.Second
+	}
+
+	// 默认的拦截器链，从外到内依次为审计、日志、权限检查和状态检查
+	defaults := []struct {
+		name        string
+		interceptor plugin.Interceptor
+	}{
+		{"audit", plugin.AuditInterceptor(pluginManager)},
+		{"logging", plugin.LoggingInterceptor()},
+		{"permission", plugin.PermissionInterceptor(pluginManager)},
+		{"state", plugin.StateInterceptor(pluginManager)},
+	}
+	for _, d := range defaults {
+		if err := pluginManager.Use(d.name, d.interceptor); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to register %s interceptor: %w", d.name, err)
+		}
+	}
+
 	return &Server{
-		addr:          config.Addr,
+		listenerCfgs:  config.listenerConfigs(),
 		authManager:   auth.NewAuthManager(),
 		pluginManager: pluginManager,
 		clients:       make(map[string]*Client),
@@ -72,6 +151,11 @@ func NewServer(config ServerConfig, pluginManager plugin.PluginManager) (*Server
 		cancel:        cancel,
 		pluginsDir:    config.PluginsDir,
 		configDir:     config.ConfigDir,
+		limiter:       newConnLimiter(config.MaxConnections, config.MaxUnauthConnections, config.MaxConnectionsPerIP),
+		authTimeout:   authTimeout,
+		hub:           newAgentHub(),
+		agentConfig:   config.Agent,
+		healthAddr:    config.HealthAddr,
 	}, nil
 }
 
@@ -79,35 +163,77 @@ func NewServer(config ServerConfig, pluginManager plugin.PluginManager) (*Server
 func (s *Server) Start() error {
 	// 内置插件已经在main.go中加载
 
-	// 启动TCP监听
-	listener, err := net.Listen("tcp", s.addr)
-	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	if len(s.listenerCfgs) == 0 && s.agentConfig == nil {
+		return errors.New("no listeners configured")
 	}
-	s.listener = listener
 
-	log.Printf("Server started on %s", s.addr)
+	// 创建所有监听器
+	for _, lc := range s.listenerCfgs {
+		listener, err := newListener(lc)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.listeners = append(s.listeners, listener)
+		log.Printf("Server listening on %s", lc)
+	}
+
+	if s.healthAddr != "" {
+		if err := s.startHealthServer(s.healthAddr); err != nil {
+			s.closeListeners()
+			return err
+		}
+	}
 
 	// 接受连接
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		s.acceptConnections()
-	}()
+	for _, listener := range s.listeners {
+		listener := listener
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.acceptConnections(listener)
+		}()
+	}
+
+	// 代理模式：连接中心节点
+	if s.agentConfig != nil {
+		if s.agentConfig.HubAddr == "" || s.agentConfig.ClientID == "" {
+			s.stopHealthServer()
+			s.closeListeners()
+			return errors.New("agent mode requires hub_addr and client_id")
+		}
+		log.Printf("Agent mode enabled, connecting to hub %s as %s", s.agentConfig.HubAddr, s.agentConfig.ClientID)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runAgent(*s.agentConfig)
+		}()
+	}
 
 	return nil
 }
 
+// closeListeners 关闭所有监听器
+func (s *Server) closeListeners() error {
+	var firstErr error
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.listeners = nil
+	return firstErr
+}
+
 // Stop 停止服务器
 func (s *Server) Stop() error {
 	// 取消上下文
 	s.cancel()
 
 	// 关闭监听器
-	if s.listener != nil {
-		if err := s.listener.Close(); err != nil {
-			return fmt.Errorf("failed to close listener: %w", err)
-		}
+	s.stopHealthServer()
+	if err := s.closeListeners(); err != nil {
+		return fmt.Errorf("failed to close listener: %w", err)
 	}
 
 	// 关闭所有客户端连接
@@ -126,9 +252,9 @@ func (s *Server) Stop() error {
 }
 
 // acceptConnections 接受客户端连接
-func (s *Server) acceptConnections() {
+func (s *Server) acceptConnections(listener net.Listener) {
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			select {
 			case <-s.ctx.Done():
@@ -140,6 +266,26 @@ func (s *Server) acceptConnections() {
 			}
 		}
 
+		// 准入控制：超过全局或未认证连接上限时立即关闭，不读取PROXY协议头
+		peer := peerAddr(conn)
+		if err := s.limiter.acquire(); err != nil {
+			log.Printf("Rejected connection from %s: %v", peer, err)
+			conn.Close()
+			continue
+		}
+
+		// 没有PROXY协议头的连接对端即客户端，在读取任何数据之前检查单IP限制
+		var ip string
+		if !hasProxyHeader(conn) {
+			ip = remoteIP(peer)
+			if err := s.limiter.acquireIP(ip); err != nil {
+				log.Printf("Rejected connection from %s: %v", peer, err)
+				s.limiter.release(false)
+				conn.Close()
+				continue
+			}
+		}
+
 		// 处理新连接
 		clientCtx, clientCancel := context.WithCancel(s.ctx)
 		client := &Client{
@@ -154,21 +300,54 @@ func (s *Server) acceptConnections() {
 			defer clientCancel()
 			defer conn.Close()
 
-			s.handleClient(client)
+			s.handleClient(client, ip)
 		}()
 	}
 }
 
-// handleClient 处理客户端连接
-func (s *Server) handleClient(client *Client) {
-	log.Printf("New connection from %s", client.conn.RemoteAddr())
+// handleClient 处理客户端连接，ip为接受连接时已申请单IP名额的地址
+func (s *Server) handleClient(client *Client, ip string) {
+	authed := false
+	defer func() {
+		s.limiter.release(authed)
+	}()
+
+	// 先获取远端地址，启用PROXY协议时会在此读取协议头
+	remoteAddr := client.conn.RemoteAddr()
+
+	// PROXY协议连接在读取协议头后按真实客户端地址限制
+	if hasProxyHeader(client.conn) {
+		ip = remoteIP(remoteAddr)
+		if err := s.limiter.acquireIP(ip); err != nil {
+			log.Printf("Rejected connection from %s: %v", remoteAddr, err)
+			return
+		}
+	}
+	defer s.limiter.releaseIP(ip)
+
+	log.Printf("New connection from %s", remoteAddr)
 
 	// 等待认证
 	if err := s.authenticateClient(client); err != nil {
 		log.Printf("Client authentication failed: %v", err)
 		return
 	}
+	s.limiter.authenticated()
+	authed = true
+
+	// 代理端连接由中心节点管理
+	if client.role == protocol.RoleAgent {
+		s.serveAgent(client)
+		return
+	}
 
+	log.Printf("Client %s authenticated successfully", client.clientInfo.ID)
+
+	s.serveClient(client)
+}
+
+// serveClient 处理已认证客户端的消息
+func (s *Server) serveClient(client *Client) {
 	// 添加到客户端列表
 	s.clientsMu.Lock()
 	s.clients[client.sessionID] = client
@@ -179,11 +358,10 @@ func (s *Server) handleClient(client *Client) {
 		s.clientsMu.Lock()
 		delete(s.clients, client.sessionID)
 		s.clientsMu.Unlock()
+		client.closeInputs()
 		log.Printf("Client %s disconnected", client.clientInfo.ID)
 	}()
 
-	log.Printf("Client %s authenticated successfully", client.clientInfo.ID)
-
 	// 处理客户端消息
 	for {
 		select {
@@ -206,7 +384,7 @@ func (s *Server) handleClient(client *Client) {
 				log.Printf("Error handling message from client %s: %v", client.clientInfo.ID, err)
 				// 发送错误响应
 				errMsg, _ := protocol.NewErrorResponseMessage(msg.Header.RequestID, 500, err.Error(), false)
-				protocol.WriteMessage(client.conn, errMsg)
+				client.writeMessage(errMsg)
 			}
 		}
 	}
@@ -215,7 +393,7 @@ func (s *Server) handleClient(client *Client) {
 // authenticateClient 认证客户端
 func (s *Server) authenticateClient(client *Client) error {
 	// 设置认证超时
-	client.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	client.conn.SetReadDeadline(time.Now().Add(s.authTimeout))
 	defer client.conn.SetReadDeadline(time.Time{})
 
 	// 读取认证消息
@@ -250,6 +428,21 @@ func (s *Server) authenticateClient(client *Client) error {
 		return fmt.Errorf("failed to get client info: %w", err)
 	}
 
+	// 代理端需要注册权限
+	switch authReq.Role {
+	case "":
+	case protocol.RoleAgent:
+		if !clientInfo.HasPermission(auth.PermAgentRegister) {
+			respMsg, _ := protocol.NewAuthResponseMessage(msg.Header.RequestID, false, "", "no permission to register as agent", false)
+			protocol.WriteMessage(client.conn, respMsg)
+			return fmt.Errorf("client %s has no permission to register as agent", clientInfo.ID)
+		}
+	default:
+		respMsg, _ := protocol.NewAuthResponseMessage(msg.Header.RequestID, false, "", "unknown role", false)
+		protocol.WriteMessage(client.conn, respMsg)
+		return fmt.Errorf("unknown role: %s", authReq.Role)
+	}
+
 	// 创建加密器
 	cipher, err := crypto.NewXXTEACipher([]byte(clientInfo.Secret))
 	if err != nil {
@@ -260,6 +453,7 @@ func (s *Server) authenticateClient(client *Client) error {
 	client.sessionID = sessionID
 	client.clientInfo = clientInfo
 	client.cipher = cipher
+	client.role = authReq.Role
 
 	// 发送认证成功响应
 	respMsg, err := protocol.NewAuthResponseMessage(msg.Header.RequestID, true, sessionID, "Authentication successful", false)
@@ -292,6 +486,13 @@ func (s *Server) handleMessage(client *Client, msg *protocol.Message) error {
 		return s.handleCommandRequest(client, msg.Header.RequestID, body, msg.Header.Encrypted)
 	case protocol.HeartbeatRequest:
 		return s.handleHeartbeatRequest(client, msg.Header.RequestID, body, msg.Header.Encrypted)
+	case protocol.HeartbeatResponse:
+		// 代理模式下中心节点对心跳的响应
+		return nil
+	case protocol.AgentListRequest:
+		return s.handleAgentListRequest(client, msg.Header.RequestID, msg.Header.Encrypted)
+	case protocol.DescribeRequest:
+		return s.handleDescribeRequest(client, msg.Header.RequestID, body, msg.Header.Encrypted)
 	case protocol.DataStream:
 		return s.handleDataStream(client, msg.Header.RequestID, body)
 	default:
@@ -306,29 +507,34 @@ func (s *Server) handleCommandRequest(client *Client, requestID string, body []b
 		return fmt.Errorf("failed to parse command request: %w", err)
 	}
 
-	log.Printf("Received command request: plugin=%s, command=%s, args=%v", cmdReq.Plugin, cmdReq.Command, cmdReq.Args)
-
-	// 检查权限
-	hasPermission, err := s.authManager.HasPluginPermission(client.clientInfo.ID, cmdReq.Plugin)
-	if err != nil {
-		return fmt.Errorf("failed to check permission: %w", err)
-	}
-	if !hasPermission {
-		return fmt.Errorf("no permission to use plugin: %s", cmdReq.Plugin)
+	// 路由到代理端执行
+	if cmdReq.Agent != "" {
+		if cmdReq.Input {
+			return errors.New("command input cannot be forwarded to agents")
+		}
+		return s.routeCommandToAgent(client, requestID, &cmdReq, encrypted)
 	}
 
-	// 获取插件
-	p, err := s.pluginManager.GetPlugin(cmdReq.Plugin)
-	if err != nil {
-		return fmt.Errorf("failed to get plugin: %w", err)
+	if !cmdReq.Input {
+		return s.runCommand(client, requestID, &cmdReq, nil, encrypted)
 	}
 
-	// 检查插件状态
-	if p.State() != plugin.Enabled && p.State() != plugin.Running {
-		return fmt.Errorf("plugin %s is not enabled", cmdReq.Plugin)
-	}
+	// 带输入的命令在单独的协程中执行，消息循环继续读取客户端发送的输入
+	input := client.openInput(requestID)
+	go func() {
+		defer client.closeInput(requestID, input)
+		if err := s.runCommand(client, requestID, &cmdReq, input, encrypted); err != nil {
+			log.Printf("Error handling message from client %s: %v", client.clientInfo.ID, err)
+			errMsg, _ := protocol.NewErrorResponseMessage(requestID, 500, err.Error(), false)
+			client.writeMessage(errMsg)
+		}
+	}()
+	return nil
+}
 
-	// 创建管道用于命令输入输出
+// runCommand 执行命令并将输出和结果发送给客户端，input为nil时命令没有输入
+func (s *Server) runCommand(client *Client, requestID string, cmdReq *protocol.CommandRequestBody, input io.Reader, encrypted bool) error {
+	// 创建管道用于命令输出
 	pr, pw := io.Pipe()
 	defer pr.Close()
 	defer pw.Close()
@@ -336,25 +542,15 @@ func (s *Server) handleCommandRequest(client *Client, requestID string, body []b
 	// 创建响应通道
 	respCh := make(chan error, 1)
 
-	// 检查插件类型
-	if p.Type() != plugin.CommandPlugin {
-		// 不支持的插件类型
-		return fmt.Errorf("plugin %s is not a command plugin", cmdReq.Plugin)
-	}
-
-	// 获取命令插件
-	cmdPlugin, err := s.pluginManager.GetCommandPlugin(cmdReq.Plugin)
-	if err != nil {
-		return fmt.Errorf("failed to get command plugin: %w", err)
-	}
-
-	// 执行命令
+	// 经过拦截器链执行命令，权限、状态检查和审计由拦截器完成
 	go func() {
-		// 创建上下文，并将插件管理器传递给插件
-		ctx := context.WithValue(client.ctx, "plugin_manager", s.pluginManager)
-
-		// 执行命令
-		err := cmdPlugin.Execute(ctx, append([]string{cmdReq.Command}, cmdReq.Args...), nil, pw)
+		err := s.pluginManager.ExecuteCommand(client.ctx, &plugin.CommandInvocation{
+			Plugin: cmdReq.Plugin,
+			Args:   append([]string{cmdReq.Command}, cmdReq.Args...),
+			Caller: client.caller(),
+			Input:  input,
+			Output: pw,
+		})
 
 		// 关闭写入端，表示命令执行完成
 		pw.Close()
@@ -364,45 +560,42 @@ func (s *Server) handleCommandRequest(client *Client, requestID string, body []b
 	}()
 
 	// 读取命令输出并发送给客户端
-	log.Printf("Reading command output...")
 	buf := make([]byte, 4096)
 	for {
 		n, err := pr.Read(buf)
 		if err != nil {
 			if err == io.EOF {
-				log.Printf("Command output completed (EOF)")
 				break
 			}
 			return fmt.Errorf("failed to read command output: %w", err)
 		}
 
-		log.Printf("Read %d bytes from command output", n)
-
 		// 发送数据流消息
 		dataMsg := protocol.NewDataStreamMessage(requestID, buf[:n], encrypted)
-		if err := protocol.WriteMessage(client.conn, dataMsg); err != nil {
+		if err := client.writeMessage(dataMsg); err != nil {
 			return fmt.Errorf("failed to send data stream: %w", err)
 		}
-		log.Printf("Data stream sent to client")
 	}
 
-	// 等待命令执行完成
-	log.Printf("Waiting for command execution to complete...")
+	// 等待命令执行完成，命令的执行日志由拦截器记录
 	cmdErr := <-respCh
-	log.Printf("Command execution completed with error: %v", cmdErr)
 
 	// 发送命令响应
 	var respMsg *protocol.Message
 	if cmdErr != nil {
-		log.Printf("Command execution failed: %v", cmdErr)
+		// 外部程序的退出状态，包括进程插件传回的退出状态
+		exitCode := 0
+		var exitErr interface{ ExitCode() int }
+		if errors.As(cmdErr, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
 		var err error
-		respMsg, err = protocol.NewCommandResponseMessage(requestID, false, cmdErr.Error(), nil, encrypted)
+		respMsg, err = protocol.NewCommandExitResponseMessage(requestID, false, cmdErr.Error(), exitCode, encrypted)
 		if err != nil {
 			log.Printf("Failed to create command response message: %v", err)
 			return fmt.Errorf("failed to create command response message: %w", err)
 		}
 	} else {
-		log.Printf("Command executed successfully")
 		var err error
 		respMsg, err = protocol.NewCommandResponseMessage(requestID, true, "Command executed successfully", nil, encrypted)
 		if err != nil {
@@ -411,17 +604,62 @@ func (s *Server) handleCommandRequest(client *Client, requestID string, body []b
 		}
 	}
 
-	log.Printf("Sending command response: requestID=%s, success=%v", requestID, cmdErr == nil)
-	if err := protocol.WriteMessage(client.conn, respMsg); err != nil {
+	if err := client.writeMessage(respMsg); err != nil {
 		log.Printf("Failed to send command response: %v", err)
 		return fmt.Errorf("failed to send command response: %w", err)
 	}
 
-	log.Printf("Command response sent successfully")
-
 	return nil
 }
 
+// handleDescribeRequest 处理命令描述请求，只返回客户端有权使用的已启用插件
+func (s *Server) handleDescribeRequest(client *Client, requestID string, body []byte, encrypted bool) error {
+	var req protocol.DescribeRequestBody
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("failed to parse describe request: %w", err)
+	}
+
+	var plugins []plugin.Plugin
+	if req.Plugin != "" {
+		if !client.clientInfo.HasPluginPermission(req.Plugin) {
+			return fmt.Errorf("no permission to use plugin: %s", req.Plugin)
+		}
+		p, err := s.pluginManager.GetPlugin(req.Plugin)
+		if err != nil {
+			return fmt.Errorf("failed to get plugin: %w", err)
+		}
+		plugins = append(plugins, p)
+	} else {
+		plugins = s.pluginManager.ListPlugins()
+		sort.Slice(plugins, func(i, j int) bool { return plugins[i].ID() < plugins[j].ID() })
+	}
+
+	descriptions := make([]protocol.PluginDescription, 0, len(plugins))
+	for _, p := range plugins {
+		if p.State() == plugin.Disabled || !client.clientInfo.HasPluginPermission(p.ID()) {
+			continue
+		}
+		commands, err := s.pluginManager.DescribeCommands(p.ID())
+		if err != nil {
+			// 不提供命令的插件
+			continue
+		}
+		descriptions = append(descriptions, protocol.PluginDescription{
+			ID:       p.ID(),
+			Name:     p.Name(),
+			Version:  p.Version(),
+			Commands: commands,
+		})
+	}
+
+	respMsg, err := protocol.NewDescribeResponseMessage(requestID, descriptions, encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to create describe response: %w", err)
+	}
+
+	return client.writeMessage(respMsg)
+}
+
 // handleHeartbeatRequest 处理心跳请求
 func (s *Server) handleHeartbeatRequest(client *Client, requestID string, body []byte, encrypted bool) error {
 	var heartbeatReq protocol.HeartbeatRequestBody
@@ -436,17 +674,16 @@ func (s *Server) handleHeartbeatRequest(client *Client, requestID string, body [
 	}
 
 	// 发送心跳响应
-	if err := protocol.WriteMessage(client.conn, respMsg); err != nil {
+	if err := client.writeMessage(respMsg); err != nil {
 		return fmt.Errorf("failed to send heartbeat response: %w", err)
 	}
 
 	return nil
 }
 
-// handleDataStream 处理数据流
+// handleDataStream 处理数据流，写入对应命令的输入，没有等待输入的命令时忽略
 func (s *Server) handleDataStream(client *Client, requestID string, body []byte) error {
-	// 数据流通常是作为命令执行的一部分处理的
-	// 这里可以添加额外的处理逻辑
+	client.writeInput(requestID, body)
 	return nil
 }
 
@@ -525,6 +762,11 @@ func (s *Server) loadBuiltinPlugins() error {
 	return nil
 }
 
+// ConnStats 返回连接统计信息
+func (s *Server) ConnStats() ConnStats {
+	return s.limiter.stats()
+}
+
 // RegisterClient 注册客户端
 func (s *Server) RegisterClient(client *auth.Client) error {
 	return s.authManager.AddClient(client)
@@ -540,9 +782,9 @@ func (s *Server) LoadPlugin(path string) (plugin.Plugin, error) {
 	return s.pluginManager.LoadPlugin(path)
 }
 
-// UnloadPlugin 卸载插件
-func (s *Server) UnloadPlugin(id string) error {
-	return s.pluginManager.UnloadPlugin(id)
+// UnloadPlugin 卸载插件，有其他插件依赖时需要force
+func (s *Server) UnloadPlugin(id string, force bool) error {
+	return s.pluginManager.UnloadPlugin(id, force)
 }
 
 // EnablePlugin 启用插件
@@ -550,9 +792,9 @@ func (s *Server) EnablePlugin(id string) error {
 	return s.pluginManager.EnablePlugin(id)
 }
 
-// DisablePlugin 禁用插件
-func (s *Server) DisablePlugin(id string) error {
-	return s.pluginManager.DisablePlugin(id)
+// DisablePlugin 禁用插件，有已启用的插件依赖时需要force
+func (s *Server) DisablePlugin(id string, force bool) error {
+	return s.pluginManager.DisablePlugin(id, force)
 }
 
 // GetPlugin 获取插件
@@ -0,0 +1,404 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+var (
+	ErrNotConnected = errors.New("client not connected")
+)
+
+// Config 客户端配置
+type Config struct {
+	ServerAddr string `json:"server_addr"`
+	// Network 网络类型：tcp（默认）或unix
+	Network  string     `json:"network,omitempty"`
+	TLS      *TLSConfig `json:"tls,omitempty"`
+	ClientID string     `json:"client_id"`
+	Secret   string     `json:"secret"`
+}
+
+// TLSConfig 客户端TLS配置
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// Result 命令执行结果
+type Result struct {
+	Success  bool
+	Message  string
+	ExitCode int
+	Data     []byte
+}
+
+// Client 服务器客户端，同一时间只能执行一个请求
+type Client struct {
+	config    Config
+	conn      net.Conn
+	sessionID string
+	mu        sync.Mutex
+}
+
+// New 创建客户端
+func New(config Config) *Client {
+	return &Client{
+		config: config,
+	}
+}
+
+// Connect 连接服务器并认证
+func (c *Client) Connect(ctx context.Context) error {
+	conn, err := Dial(ctx, c.config)
+	if err != nil {
+		return err
+	}
+
+	// 上下文取消时中断认证
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	sessionID, err := Authenticate(conn, c.config.ClientID, c.config.Secret)
+	if err != nil {
+		conn.Close()
+		return ctxErr(ctx, err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.sessionID = sessionID
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// SessionID 返回认证后的会话ID
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// Execute 执行命令，输出流写入output，agent不为空时由中心节点转发到指定代理端执行
+func (c *Client) Execute(ctx context.Context, agent, plugin, command string, args []string, output io.Writer) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	stop := closeOnDone(ctx, c.conn)
+	defer stop()
+
+	requestID := uuid.New().String()
+	cmdMsg, err := protocol.NewAgentCommandRequestMessage(requestID, agent, plugin, command, args, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create command request: %w", err)
+	}
+
+	if err := protocol.WriteMessage(c.conn, cmdMsg); err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("failed to send command request: %w", err))
+	}
+
+	for {
+		respMsg, err := protocol.ReadMessage(c.conn)
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("failed to read response: %w", err))
+		}
+
+		if respMsg.Header.RequestID != requestID {
+			continue
+		}
+
+		switch respMsg.Header.Type {
+		case protocol.CommandResponse:
+			var cmdResp protocol.CommandResponseBody
+			if err := json.Unmarshal(respMsg.Body, &cmdResp); err != nil {
+				return nil, fmt.Errorf("failed to parse command response: %w", err)
+			}
+			return &Result{
+				Success:  cmdResp.Success,
+				Message:  cmdResp.Message,
+				ExitCode: cmdResp.ExitCode,
+				Data:     cmdResp.Data,
+			}, nil
+		case protocol.DataStream:
+			if output != nil {
+				if _, err := output.Write(respMsg.Body); err != nil {
+					return nil, fmt.Errorf("failed to write output: %w", err)
+				}
+			}
+		case protocol.ErrorResponse:
+			var errResp protocol.ErrorResponseBody
+			if err := json.Unmarshal(respMsg.Body, &errResp); err != nil {
+				return nil, fmt.Errorf("failed to parse error response: %w", err)
+			}
+			return nil, fmt.Errorf("error: %s", errResp.Message)
+		}
+	}
+}
+
+// ListAgents 列出中心节点上已连接的代理端
+func (c *Client) ListAgents(ctx context.Context) ([]protocol.AgentInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	stop := closeOnDone(ctx, c.conn)
+	defer stop()
+
+	requestID := uuid.New().String()
+	if err := protocol.WriteMessage(c.conn, protocol.NewAgentListRequestMessage(requestID, false)); err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("failed to send agent list request: %w", err))
+	}
+
+	for {
+		respMsg, err := protocol.ReadMessage(c.conn)
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("failed to read response: %w", err))
+		}
+		if respMsg.Header.RequestID != requestID {
+			continue
+		}
+
+		switch respMsg.Header.Type {
+		case protocol.AgentListResponse:
+			var resp protocol.AgentListResponseBody
+			if err := json.Unmarshal(respMsg.Body, &resp); err != nil {
+				return nil, fmt.Errorf("failed to parse agent list: %w", err)
+			}
+			return resp.Agents, nil
+		case protocol.ErrorResponse:
+			var errResp protocol.ErrorResponseBody
+			if err := json.Unmarshal(respMsg.Body, &errResp); err != nil {
+				return nil, fmt.Errorf("failed to parse error response: %w", err)
+			}
+			return nil, fmt.Errorf("error: %s", errResp.Message)
+		}
+	}
+}
+
+// Describe 获取插件命令的描述，plugin为空时返回所有有权使用的插件
+func (c *Client) Describe(ctx context.Context, plugin string) ([]protocol.PluginDescription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	stop := closeOnDone(ctx, c.conn)
+	defer stop()
+
+	requestID := uuid.New().String()
+	reqMsg, err := protocol.NewDescribeRequestMessage(requestID, plugin, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create describe request: %w", err)
+	}
+	if err := protocol.WriteMessage(c.conn, reqMsg); err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("failed to send describe request: %w", err))
+	}
+
+	for {
+		respMsg, err := protocol.ReadMessage(c.conn)
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("failed to read response: %w", err))
+		}
+		if respMsg.Header.RequestID != requestID {
+			continue
+		}
+
+		switch respMsg.Header.Type {
+		case protocol.DescribeResponse:
+			var resp protocol.DescribeResponseBody
+			if err := json.Unmarshal(respMsg.Body, &resp); err != nil {
+				return nil, fmt.Errorf("failed to parse describe response: %w", err)
+			}
+			return resp.Plugins, nil
+		case protocol.ErrorResponse:
+			var errResp protocol.ErrorResponseBody
+			if err := json.Unmarshal(respMsg.Body, &errResp); err != nil {
+				return nil, fmt.Errorf("failed to parse error response: %w", err)
+			}
+			return nil, fmt.Errorf("error: %s", errResp.Message)
+		}
+	}
+}
+
+// Dial 连接服务器，配置了TLS时完成TLS握手，未设置ServerName时使用服务器地址中的主机名
+func Dial(ctx context.Context, config Config) (net.Conn, error) {
+	network := config.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, network, config.ServerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	if config.TLS == nil {
+		return conn, nil
+	}
+
+	tlsConfig, err := LoadTLSConfig(config.TLS)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to load tls config: %w", err)
+	}
+	if tlsConfig.ServerName == "" && network != "unix" {
+		if host, _, err := net.SplitHostPort(config.ServerAddr); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, ctxErr(ctx, fmt.Errorf("tls handshake failed: %w", err))
+	}
+
+	return tlsConn, nil
+}
+
+// Authenticate 使用HMAC签名认证，返回会话ID
+func Authenticate(conn net.Conn, clientID, secret string) (string, error) {
+	return authenticate(conn, clientID, secret, false)
+}
+
+// AuthenticateAgent 以代理端身份使用HMAC签名向中心节点认证，返回会话ID
+func AuthenticateAgent(conn net.Conn, clientID, secret string) (string, error) {
+	return authenticate(conn, clientID, secret, true)
+}
+
+// authenticate 发送认证请求并读取认证响应
+func authenticate(conn net.Conn, clientID, secret string, agent bool) (string, error) {
+	nonce := uuid.New().String()
+	timestamp := time.Now().Unix()
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(fmt.Sprintf("%s:%s:%d", clientID, nonce, timestamp)))
+	signature := hex.EncodeToString(h.Sum(nil))
+
+	var authMsg *protocol.Message
+	var err error
+	if agent {
+		authMsg, err = protocol.NewAgentAuthRequestMessage(uuid.New().String(), clientID, nonce, timestamp, signature)
+	} else {
+		authMsg, err = protocol.NewAuthRequestMessage(uuid.New().String(), clientID, nonce, timestamp, signature, false)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create auth request: %w", err)
+	}
+
+	if err := protocol.WriteMessage(conn, authMsg); err != nil {
+		return "", fmt.Errorf("failed to send auth request: %w", err)
+	}
+
+	respMsg, err := protocol.ReadMessage(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read auth response: %w", err)
+	}
+	if respMsg.Header.Type != protocol.AuthResponse {
+		return "", fmt.Errorf("unexpected response type: %d", respMsg.Header.Type)
+	}
+
+	var authResp protocol.AuthResponseBody
+	if err := json.Unmarshal(respMsg.Body, &authResp); err != nil {
+		return "", fmt.Errorf("failed to parse auth response: %w", err)
+	}
+	if !authResp.Success {
+		return "", fmt.Errorf("authentication failed: %s", authResp.Message)
+	}
+
+	return authResp.SessionID, nil
+}
+
+// LoadTLSConfig 加载客户端TLS配置
+func LoadTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	// 加载CA证书
+	if c.CAFile != "" {
+		caBytes, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// 加载客户端证书（mTLS）
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// closeOnDone 上下文取消时关闭连接，返回的函数用于停止监听
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ctxErr 上下文已取消时返回上下文错误，便于调用方区分超时
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
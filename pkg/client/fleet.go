@@ -0,0 +1,267 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Host 清单中的主机，未设置的连接参数使用清单的defaults
+type Host struct {
+	Name       string     `json:"name"`
+	ServerAddr string     `json:"server_addr,omitempty"`
+	Network    string     `json:"network,omitempty"`
+	TLS        *TLSConfig `json:"tls,omitempty"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Secret     string     `json:"secret,omitempty"`
+	// Agent 通过中心节点转发时的代理端ID
+	Agent string `json:"agent,omitempty"`
+}
+
+// Inventory 主机清单
+type Inventory struct {
+	Defaults Config `json:"defaults"`
+	Hosts    []Host `json:"hosts"`
+}
+
+// LoadInventory 从JSON文件加载主机清单
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+
+	var inv Inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range inv.Hosts {
+		host := &inv.Hosts[i]
+		if host.Name == "" {
+			host.Name = host.Agent
+		}
+		if host.Name == "" {
+			host.Name = host.ServerAddr
+		}
+		if host.Name == "" {
+			return nil, fmt.Errorf("inventory host %d has no name", i)
+		}
+		if seen[host.Name] {
+			return nil, fmt.Errorf("duplicate inventory host: %s", host.Name)
+		}
+		seen[host.Name] = true
+
+		if inv.config(*host).ServerAddr == "" {
+			return nil, fmt.Errorf("inventory host %s has no server_addr", host.Name)
+		}
+	}
+
+	return &inv, nil
+}
+
+// config 合并主机配置与默认配置
+func (inv *Inventory) config(host Host) Config {
+	config := inv.Defaults
+	if host.ServerAddr != "" {
+		config.ServerAddr = host.ServerAddr
+	}
+	if host.Network != "" {
+		config.Network = host.Network
+	}
+	if host.TLS != nil {
+		config.TLS = host.TLS
+	}
+	if host.ClientID != "" {
+		config.ClientID = host.ClientID
+	}
+	if host.Secret != "" {
+		config.Secret = host.Secret
+	}
+	return config
+}
+
+// FleetOptions 批量执行选项
+type FleetOptions struct {
+	// Concurrency 最大并发主机数，默认10
+	Concurrency int
+	// Timeout 单台主机的超时时间（含连接和认证），0表示不限制
+	Timeout time.Duration
+	// Output 带主机前缀的输出，为nil时丢弃
+	Output io.Writer
+}
+
+// HostResult 单台主机的执行结果
+type HostResult struct {
+	Host     string
+	Success  bool
+	ExitCode int
+	Duration time.Duration
+	Err      error
+}
+
+// RunFleet 在清单中的所有主机上并发执行命令，结果按清单顺序返回
+func RunFleet(ctx context.Context, inv *Inventory, plugin, command string, args []string, opts FleetOptions) []HostResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+	var outputMu sync.Mutex
+
+	results := make([]HostResult, len(inv.Hosts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, host := range inv.Hosts {
+		wg.Add(1)
+		go func(i int, host Host) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = HostResult{Host: host.Name, ExitCode: -1, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-sem }()
+
+			w := &prefixWriter{w: output, mu: &outputMu, prefix: "[" + host.Name + "] "}
+			results[i] = runHost(ctx, inv.config(host), host, plugin, command, args, opts.Timeout, w)
+			w.Flush()
+		}(i, host)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// runHost 在单台主机上执行命令
+func runHost(ctx context.Context, config Config, host Host, plugin, command string, args []string, timeout time.Duration, output *prefixWriter) HostResult {
+	start := time.Now()
+	result := HostResult{Host: host.Name, ExitCode: -1}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c := New(config)
+	err := c.Connect(ctx)
+	if err == nil {
+		defer c.Close()
+
+		var res *Result
+		res, err = c.Execute(ctx, host.Agent, plugin, command, args, output)
+		if err == nil {
+			if len(res.Data) > 0 {
+				output.Write(res.Data)
+			}
+			result.Success = res.Success
+			result.ExitCode = res.ExitCode
+			if !res.Success {
+				err = errors.New(res.Message)
+				// 插件未返回退出状态时标记为未知
+				if res.ExitCode == 0 {
+					result.ExitCode = -1
+				}
+			}
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	result.Err = err
+	result.Duration = time.Since(start)
+	return result
+}
+
+// WriteSummary 输出执行结果汇总表
+func WriteSummary(w io.Writer, results []HostResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tSTATUS\tEXIT\tDURATION\tERROR")
+
+	succeeded := 0
+	for _, r := range results {
+		status := "FAILED"
+		if r.Success {
+			status = "OK"
+			succeeded++
+		}
+		exit := "-"
+		if r.ExitCode >= 0 {
+			exit = strconv.Itoa(r.ExitCode)
+		}
+		errMsg := ""
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Host, status, exit, r.Duration.Round(time.Millisecond), errMsg)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "%d/%d hosts succeeded\n", succeeded, len(results))
+}
+
+// prefixWriter 按行为输出添加主机前缀，多台主机共享同一输出时保证行不交错
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte
+}
+
+// Write 写入输出，不完整的行缓存到下次写入或Flush
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:idx+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[idx+1:]
+	}
+
+	return len(data), nil
+}
+
+// Flush 输出缓存中不完整的行
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+// writeLine 写入带前缀的一行
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := io.WriteString(p.w, p.prefix); err != nil {
+		return err
+	}
+	_, err := p.w.Write(line)
+	return err
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sorc/tcpserver/pkg/protocol"
+)
+
+// hostHandler 处理测试主机收到的命令请求
+type hostHandler func(conn net.Conn, requestID string, req protocol.CommandRequestBody)
+
+// startTestHost 启动接受任意认证的测试主机，每个连接的命令请求交给handle处理，返回监听地址
+func startTestHost(t *testing.T, handle hostHandler) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+
+				authMsg, err := protocol.ReadMessage(conn)
+				if err != nil {
+					return
+				}
+				resp, _ := protocol.NewAuthResponseMessage(authMsg.Header.RequestID, true, "session", "ok", false)
+				if protocol.WriteMessage(conn, resp) != nil {
+					return
+				}
+
+				msg, err := protocol.ReadMessage(conn)
+				if err != nil {
+					return
+				}
+				var req protocol.CommandRequestBody
+				if err := json.Unmarshal(msg.Body, &req); err != nil {
+					return
+				}
+				handle(conn, msg.Header.RequestID, req)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// respond 依次发送数据流并以命令响应结束
+func respond(conn net.Conn, requestID string, success bool, message string, exitCode int, chunks ...string) {
+	for _, chunk := range chunks {
+		protocol.WriteMessage(conn, protocol.NewDataStreamMessage(requestID, []byte(chunk), false))
+	}
+	resp, _ := protocol.NewCommandExitResponseMessage(requestID, success, message, exitCode, false)
+	protocol.WriteMessage(conn, resp)
+}
+
+// testInventory 创建主机清单，hosts为主机名到监听地址的映射
+func testInventory(hosts map[string]string) *Inventory {
+	inv := &Inventory{Defaults: Config{ClientID: "fleet", Secret: "0123456789abcdef"}}
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		inv.Hosts = append(inv.Hosts, Host{Name: name, ServerAddr: hosts[name]})
+	}
+	return inv
+}
+
+func TestRunFleetResults(t *testing.T) {
+	hang := func(conn net.Conn, requestID string, req protocol.CommandRequestBody) {
+		// 不响应，直到客户端超时后关闭连接
+		conn.Read(make([]byte, 1))
+	}
+
+	tests := []struct {
+		name         string
+		handle       hostHandler
+		wantSuccess  bool
+		wantExitCode int
+		wantErr      string
+	}{
+		{
+			name:        "success",
+			handle:      func(conn net.Conn, id string, req protocol.CommandRequestBody) { respond(conn, id, true, "ok", 0) },
+			wantSuccess: true,
+		},
+		{
+			name: "exit status",
+			handle: func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+				respond(conn, id, false, "exit status 3", 3)
+			},
+			wantExitCode: 3,
+			wantErr:      "exit status 3",
+		},
+		{
+			name: "failure without exit status",
+			handle: func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+				respond(conn, id, false, "plugin failed", 0)
+			},
+			wantExitCode: -1,
+			wantErr:      "plugin failed",
+		},
+		{
+			name: "error response",
+			handle: func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+				msg, _ := protocol.NewErrorResponseMessage(id, 500, "plugin not found", false)
+				protocol.WriteMessage(conn, msg)
+			},
+			wantExitCode: -1,
+			wantErr:      "plugin not found",
+		},
+		{name: "timeout", handle: hang, wantExitCode: -1, wantErr: "timed out after 200ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := testInventory(map[string]string{"host": startTestHost(t, tt.handle)})
+			results := RunFleet(context.Background(), inv, "shell", "exec", nil, FleetOptions{Timeout: 200 * time.Millisecond})
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+
+			r := results[0]
+			if r.Host != "host" || r.Success != tt.wantSuccess || r.ExitCode != tt.wantExitCode {
+				t.Errorf("result = %+v, want success %v, exit code %d", r, tt.wantSuccess, tt.wantExitCode)
+			}
+			if tt.wantErr == "" {
+				if r.Err != nil {
+					t.Errorf("unexpected error: %v", r.Err)
+				}
+			} else if r.Err == nil || !strings.Contains(r.Err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", r.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunFleetTimeoutIsPerHost(t *testing.T) {
+	slow := startTestHost(t, func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+		conn.Read(make([]byte, 1))
+	})
+	fast := startTestHost(t, func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+		respond(conn, id, true, "ok", 0)
+	})
+	inv := testInventory(map[string]string{"a-slow": slow, "b-fast": fast})
+
+	results := RunFleet(context.Background(), inv, "shell", "exec", nil, FleetOptions{Timeout: 200 * time.Millisecond})
+	if results[0].Success || results[0].Err == nil {
+		t.Errorf("slow host result = %+v, want timeout", results[0])
+	}
+	if !results[1].Success {
+		t.Errorf("fast host result = %+v, want success", results[1])
+	}
+}
+
+func TestRunFleetConcurrency(t *testing.T) {
+	var active, peak int32
+	handle := func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		respond(conn, id, true, "ok", 0)
+	}
+
+	hosts := make(map[string]string)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		hosts[name] = startTestHost(t, handle)
+	}
+	inv := testInventory(hosts)
+
+	results := RunFleet(context.Background(), inv, "shell", "exec", nil, FleetOptions{Concurrency: 2})
+	for i, r := range results {
+		if r.Host != inv.Hosts[i].Name || !r.Success {
+			t.Errorf("result %d = %+v, want success for %s", i, r, inv.Hosts[i].Name)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("%d hosts ran concurrently, want at most 2", peak)
+	}
+}
+
+func TestRunFleetCanceled(t *testing.T) {
+	addr := startTestHost(t, func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+		respond(conn, id, true, "ok", 0)
+	})
+	inv := testInventory(map[string]string{"host": addr})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := RunFleet(ctx, inv, "shell", "exec", nil, FleetOptions{})
+	if r := results[0]; r.Success || r.Err == nil || r.ExitCode != -1 {
+		t.Errorf("result = %+v, want failure", r)
+	}
+}
+
+func TestRunFleetPrefixesOutput(t *testing.T) {
+	hosts := make(map[string]string)
+	for _, name := range []string{"a", "b"} {
+		hosts[name] = startTestHost(t, func(conn net.Conn, id string, req protocol.CommandRequestBody) {
+			respond(conn, id, true, "ok", 0, "first\nsec", "ond\n", "partial")
+		})
+	}
+	var output bytes.Buffer
+	RunFleet(context.Background(), testInventory(hosts), "shell", "exec", nil, FleetOptions{Output: &output})
+
+	// 两台主机的输出可能交错，但每行都完整且带有所属主机的前缀
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	sort.Strings(lines)
+	want := []string{"[a] first", "[a] partial", "[a] second", "[b] first", "[b] partial", "[b] second"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("output lines = %q, want %q", lines, want)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{name: "complete lines", writes: []string{"a\nb\n"}, want: "> a\n> b\n"},
+		{name: "line split across writes", writes: []string{"he", "llo\nwor", "ld\n"}, want: "> hello\n> world\n"},
+		{name: "partial line flushed", writes: []string{"a\nb"}, want: "> a\n> b\n"},
+		{name: "empty lines", writes: []string{"\n\n"}, want: "> \n> \n"},
+		{name: "no output", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := &prefixWriter{w: &buf, mu: &sync.Mutex{}, prefix: "> "}
+			for _, w := range tt.writes {
+				n, err := p.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if err := p.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		results  []HostResult
+		wantRows []string
+		wantLast string
+	}{
+		{
+			name: "mixed results",
+			results: []HostResult{
+				{Host: "web1", Success: true, Duration: 1500 * time.Microsecond},
+				{Host: "web2", ExitCode: 3, Err: errors.New("exit status 3")},
+				{Host: "web3", ExitCode: -1, Err: errors.New("timed out after 1s")},
+			},
+			wantRows: []string{
+				"web1  OK      0     2ms",
+				"web2  FAILED  3     0s        exit status 3",
+				"web3  FAILED  -     0s        timed out after 1s",
+			},
+			wantLast: "1/3 hosts succeeded",
+		},
+		{name: "no hosts", wantLast: "0/0 hosts succeeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			WriteSummary(&buf, tt.results)
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != len(tt.wantRows)+2 {
+				t.Fatalf("summary:\n%s", buf.String())
+			}
+			if !strings.HasPrefix(lines[0], "HOST") {
+				t.Errorf("header = %q", lines[0])
+			}
+			for i, want := range tt.wantRows {
+				if got := strings.TrimRight(lines[i+1], " "); got != want {
+					t.Errorf("row %d = %q, want %q", i, got, want)
+				}
+			}
+			if got := lines[len(lines)-1]; got != tt.wantLast {
+				t.Errorf("last line = %q, want %q", got, tt.wantLast)
+			}
+		})
+	}
+}
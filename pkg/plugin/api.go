@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// APIVersion 插件接口（pkg/plugin）的版本。
+// 接口有不兼容的变更时增加主版本号，新增可选接口或方法时增加次版本号
+const APIVersion = "1.3.0"
+
+// ServerVersion 服务器版本，编译时可以通过-ldflags "-X github.com/sorc/tcpserver/pkg/plugin.ServerVersion=x.y.z"设置
+var ServerVersion = "1.0.0"
+
+var (
+	ErrIncompatibleAPI     = errors.New("incompatible plugin API version")
+	ErrServerVersionTooOld = errors.New("server version too old")
+)
+
+// CheckCompatibility 检查插件元数据中声明的接口版本和最低服务器版本是否与当前服务器兼容。
+// 插件的接口主版本号必须与服务器相同，次版本号不能高于服务器；未声明接口版本的插件视为兼容
+func CheckCompatibility(metadata PluginMetadata) error {
+	if metadata.APIVersion != "" {
+		pluginAPI, err := ParseVersion(metadata.APIVersion)
+		if err != nil {
+			return fmt.Errorf("%w: invalid api_version %q in metadata of plugin %s", ErrIncompatibleAPI, metadata.APIVersion, metadata.ID)
+		}
+		hostAPI, _ := ParseVersion(APIVersion)
+
+		switch {
+		case pluginAPI.Major != hostAPI.Major:
+			return fmt.Errorf("%w: plugin %s was built against plugin API %s, server provides %s; rebuild the plugin against this server's pkg/plugin",
+				ErrIncompatibleAPI, metadata.ID, metadata.APIVersion, APIVersion)
+		case pluginAPI.Minor > hostAPI.Minor:
+			return fmt.Errorf("%w: plugin %s requires plugin API %s, server provides %s; upgrade the server or use an older build of the plugin",
+				ErrIncompatibleAPI, metadata.ID, metadata.APIVersion, APIVersion)
+		}
+	}
+
+	if metadata.MinServerVersion != "" {
+		minServer, err := ParseVersion(metadata.MinServerVersion)
+		if err != nil {
+			return fmt.Errorf("%w: invalid min_server_version %q in metadata of plugin %s", ErrServerVersionTooOld, metadata.MinServerVersion, metadata.ID)
+		}
+		server, err := ParseVersion(ServerVersion)
+		if err == nil && server.Compare(minServer) < 0 {
+			return fmt.Errorf("%w: plugin %s requires server %s or later, running %s; upgrade the server",
+				ErrServerVersionTooOld, metadata.ID, metadata.MinServerVersion, ServerVersion)
+		}
+	}
+
+	return nil
+}
+
+// explainOpenError 为plugin.Open的常见错误补充处理建议
+func explainOpenError(metadata PluginMetadata, err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "different version of package"):
+		hint := "rebuild the plugin with the same Go toolchain and dependency versions as the server"
+		if metadata.APIVersion == "" {
+			hint += ", and declare api_version in its metadata"
+		}
+		return fmt.Errorf("failed to open plugin: %w; %s", err, hint)
+	case strings.Contains(msg, "plugin already loaded"):
+		return fmt.Errorf("failed to open plugin: %w; a shared object cannot be replaced in a running process, restart the server or use a different file name", err)
+	default:
+		return fmt.Errorf("failed to open plugin: %w", err)
+	}
+}
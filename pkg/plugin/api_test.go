@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	host, err := ParseVersion(APIVersion)
+	if err != nil {
+		t.Fatalf("ParseVersion(APIVersion): %v", err)
+	}
+	api := func(major, minor int) string {
+		return fmt.Sprintf("%d.%d.0", major, minor)
+	}
+
+	defer func(v string) { ServerVersion = v }(ServerVersion)
+	ServerVersion = "1.4.2"
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		minServer  string
+		wantErr    error
+	}{
+		{name: "undeclared"},
+		{name: "same version", apiVersion: APIVersion},
+		{name: "older minor", apiVersion: api(host.Major, 0)},
+		{name: "newer patch", apiVersion: fmt.Sprintf("%d.%d.%d", host.Major, host.Minor, host.Patch+1)},
+		{name: "too new minor", apiVersion: api(host.Major, host.Minor+1), wantErr: ErrIncompatibleAPI},
+		{name: "older major", apiVersion: api(host.Major-1, host.Minor), wantErr: ErrIncompatibleAPI},
+		{name: "newer major", apiVersion: api(host.Major+1, 0), wantErr: ErrIncompatibleAPI},
+		{name: "invalid api version", apiVersion: "one", wantErr: ErrIncompatibleAPI},
+		{name: "server new enough", apiVersion: APIVersion, minServer: "1.4.0"},
+		{name: "server exact", minServer: "1.4.2"},
+		{name: "server too old", apiVersion: APIVersion, minServer: "1.5.0", wantErr: ErrServerVersionTooOld},
+		{name: "server prerelease required", minServer: "2.0.0-rc.1", wantErr: ErrServerVersionTooOld},
+		{name: "invalid min server version", minServer: "latest", wantErr: ErrServerVersionTooOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCompatibility(PluginMetadata{ID: "test", APIVersion: tt.apiVersion, MinServerVersion: tt.minServer})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("CheckCompatibility: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckCompatibility error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditFile 命令执行审计记录文件，位于配置目录下，每行一条JSON记录
+const auditFile = "audit.jsonl"
+
+// AuditRecord 命令执行审计记录
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	ClientID string    `json:"client_id"`
+	Plugin   string    `json:"plugin"`
+	Args     []string  `json:"args"`
+	// Via 通过Host.Invoke发起调用的插件链，客户端直接执行的命令为空
+	Via []string `json:"via,omitempty"`
+	// Duration 命令执行时间，单位毫秒
+	Duration int64  `json:"duration_ms"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RecordAudit 追加审计记录，写入失败时只记录日志
+func (pm *DefaultPluginManager) RecordAudit(record AuditRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Failed to encode audit record: %v", err)
+		return
+	}
+
+	pm.auditMu.Lock()
+	defer pm.auditMu.Unlock()
+
+	if err := os.MkdirAll(pm.configDir, 0755); err != nil {
+		log.Printf("Failed to record audit: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(pm.configDir, auditFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Failed to record audit: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to record audit: %v", err)
+	}
+}
+
+// AuditLog 获取审计记录，id为空时返回所有插件的记录，limit大于0时只返回最近的limit条
+func (pm *DefaultPluginManager) AuditLog(id string, limit int) ([]AuditRecord, error) {
+	pm.auditMu.Lock()
+	defer pm.auditMu.Unlock()
+
+	f, err := os.Open(filepath.Join(pm.configDir, auditFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if id == "" || record.Plugin == id || containsString(record.Via, id) {
+			records = append(records, record)
+		}
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return records, scanner.Err()
+}
+
+// containsString 检查切片中是否包含s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
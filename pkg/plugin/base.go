@@ -109,20 +109,37 @@ func (p *BaseServicePlugin) Restart(ctx context.Context) error {
 	return p.Start(ctx)
 }
 
-// Pause 暂停服务（基础实现）
+// Pause 暂停服务（基础实现）。暂停的服务保留监听器等资源和已建立的连接，但不再接受新的连接或请求，
+// Resume后恢复；与Stop不同，暂停不释放资源，恢复也不需要重新初始化。
+// 基础实现只修改状态，服务类插件应覆盖Pause和Resume实现实际的暂停，并在最后调用基础实现。
+// 服务未启动时返回ErrServiceNotStarted，已暂停时不做任何操作
 func (p *BaseServicePlugin) Pause() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.state = Paused
-	return nil
+	switch p.state {
+	case Running:
+		p.state = Paused
+		return nil
+	case Paused:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrServiceNotStarted, p.id)
+	}
 }
 
-// Resume 恢复服务（基础实现）
+// Resume 恢复暂停的服务（基础实现），服务未启动时返回ErrServiceNotStarted，运行中时不做任何操作
 func (p *BaseServicePlugin) Resume() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.state = Running
-	return nil
+	switch p.state {
+	case Paused:
+		p.state = Running
+		return nil
+	case Running:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrServiceNotStarted, p.id)
+	}
 }
 
 // BaseCommandPlugin 提供命令类插件基础实现
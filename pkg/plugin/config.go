@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sorc/tcpserver/pkg/schema"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidConfig           = errors.New("invalid plugin config")
+	ErrReconfigureNotSupported = errors.New("plugin does not support live reconfiguration")
+)
+
+// 配置字段类型
+const (
+	FieldString   = schema.FieldString
+	FieldInt      = schema.FieldInt
+	FieldFloat    = schema.FieldFloat
+	FieldBool     = schema.FieldBool
+	FieldDuration = schema.FieldDuration
+	FieldAddr     = schema.FieldAddr
+	FieldList     = schema.FieldList
+	FieldMap      = schema.FieldMap
+)
+
+// ConfigField 配置字段，定义在pkg/schema中
+type ConfigField = schema.ConfigField
+
+// ConfigSchema 插件配置（config_dir/<id>.yml）的结构描述
+type ConfigSchema struct {
+	Fields []ConfigField `json:"fields"`
+	// AllowUnknown 允许未声明的字段
+	AllowUnknown bool `json:"allow_unknown,omitempty"`
+}
+
+// IConfigurable 插件可选实现的接口，发布配置结构，配置在写入前按结构校验
+type IConfigurable interface {
+	// ConfigSchema 返回插件配置的结构描述
+	ConfigSchema() ConfigSchema
+}
+
+// IReconfigurable 插件可选实现的接口，支持在不重启插件的情况下应用新配置
+type IReconfigurable interface {
+	// Reconfigure 应用新配置，失败时插件应保持原配置继续运行
+	Reconfigure(ctx context.Context, config []byte) error
+}
+
+// Validate 按结构校验YAML配置
+func (s ConfigSchema) Validate(config []byte) error {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(config, &values); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	var problems []string
+	fields := make(map[string]ConfigField, len(s.Fields))
+	for _, field := range s.Fields {
+		fields[field.Name] = field
+		value, exists := values[field.Name]
+		if !exists || value == nil {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", field.Name))
+			}
+			continue
+		}
+		if err := checkField(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field.Name, err))
+		}
+	}
+
+	if !s.AllowUnknown {
+		for name := range values {
+			if _, exists := fields[name]; !exists {
+				problems = append(problems, fmt.Sprintf("unknown field %s", name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkField 检查字段值的类型和取值
+func checkField(f ConfigField, value interface{}) error {
+	switch f.Type {
+	case FieldString, FieldDuration, FieldAddr:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected %s, got %T", f.Type, value)
+		}
+		if f.Type == FieldDuration {
+			if _, err := time.ParseDuration(s); err != nil {
+				return fmt.Errorf("invalid duration %q", s)
+			}
+		}
+		if f.Type == FieldAddr {
+			if _, _, err := net.SplitHostPort(s); err != nil {
+				return fmt.Errorf("invalid address %q, expected host:port", s)
+			}
+		}
+		if len(f.Enum) > 0 {
+			for _, allowed := range f.Enum {
+				if s == allowed {
+					return nil
+				}
+			}
+			return fmt.Errorf("must be one of %s", strings.Join(f.Enum, ", "))
+		}
+	case FieldInt:
+		if _, ok := value.(int); !ok {
+			return fmt.Errorf("expected int, got %T", value)
+		}
+	case FieldFloat:
+		switch value.(type) {
+		case int, float64:
+		default:
+			return fmt.Errorf("expected float, got %T", value)
+		}
+	case FieldBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected bool, got %T", value)
+		}
+	case FieldList:
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("expected list, got %T", value)
+		}
+	case FieldMap:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("expected map, got %T", value)
+		}
+	}
+	return nil
+}
+
+// GetConfigSchema 获取插件发布的配置结构，插件未发布时返回false
+func (pm *DefaultPluginManager) GetConfigSchema(id string) (ConfigSchema, bool, error) {
+	p, err := pm.GetPlugin(id)
+	if err != nil {
+		return ConfigSchema{}, false, err
+	}
+
+	configurable, ok := p.(IConfigurable)
+	if !ok {
+		return ConfigSchema{}, false, nil
+	}
+	schema := configurable.ConfigSchema()
+	return schema, len(schema.Fields) > 0, nil
+}
+
+// ReconfigurePlugin 校验并保存插件配置，插件支持时立即应用，应用失败时恢复原配置。
+// 返回配置是否已经生效，未生效时需要重启插件
+func (pm *DefaultPluginManager) ReconfigurePlugin(id string, config []byte) (bool, error) {
+	p, err := pm.GetPlugin(id)
+	if err != nil {
+		return false, err
+	}
+
+	// 校验配置
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(config, &values); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if schema, ok, _ := pm.GetConfigSchema(id); ok {
+		if err := schema.Validate(config); err != nil {
+			return false, err
+		}
+	}
+
+	// 保存新配置，保留原配置用于回滚
+	configPath := filepath.Join(pm.configDir, id+".yml")
+	oldConfig, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	existed := err == nil
+	if err := writeConfig(configPath, config); err != nil {
+		return false, err
+	}
+
+	reconfigurable, ok := p.(IReconfigurable)
+	if !ok {
+		return false, nil
+	}
+
+	err = reconfigurable.Reconfigure(pm.ctx, config)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, ErrReconfigureNotSupported) {
+		return false, nil
+	}
+
+	// 恢复原配置文件和插件的运行配置
+	if existed {
+		if werr := writeConfig(configPath, oldConfig); werr != nil {
+			log.Printf("Failed to restore config of plugin %s: %v", id, werr)
+		}
+	} else {
+		os.Remove(configPath)
+	}
+	if rerr := reconfigurable.Reconfigure(pm.ctx, oldConfig); rerr != nil {
+		log.Printf("Failed to restore runtime config of plugin %s: %v", id, rerr)
+	}
+	return false, fmt.Errorf("failed to apply config, previous config restored: %w", err)
+}
+
+// writeConfig 原子写入配置文件
+func writeConfig(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
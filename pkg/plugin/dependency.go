@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrDependencyMissing   = errors.New("dependency not installed")
+	ErrDependencyVersion   = errors.New("dependency version mismatch")
+	ErrDependencyDisabled  = errors.New("dependency is disabled")
+	ErrDependencyCycle     = errors.New("dependency cycle")
+	ErrPluginHasDependents = errors.New("plugin is required by other plugins")
+)
+
+// Dependency 插件依赖，格式为"<插件ID>[ <版本约束>]"，如"file"、"file >=1.0.0, <2.0.0"、"proxy@^1.2"
+type Dependency struct {
+	ID         string
+	Constraint Constraint
+}
+
+// ParseDependency 解析插件依赖
+func ParseDependency(s string) (Dependency, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexAny(s, " \t@<>=!^~")
+	if end < 0 {
+		end = len(s)
+	}
+
+	dep := Dependency{ID: s[:end]}
+	if dep.ID == "" {
+		return Dependency{}, fmt.Errorf("invalid dependency: %q", s)
+	}
+
+	constraint, err := ParseConstraint(strings.TrimPrefix(strings.TrimSpace(s[end:]), "@"))
+	if err != nil {
+		return Dependency{}, fmt.Errorf("invalid dependency %q: %w", s, err)
+	}
+	dep.Constraint = constraint
+	return dep, nil
+}
+
+// String 返回依赖的描述
+func (d Dependency) String() string {
+	if d.Constraint.raw == "" {
+		return d.ID
+	}
+	return d.ID + " " + d.Constraint.raw
+}
+
+// Check 检查已安装的插件版本是否满足依赖
+func (d Dependency) Check(version string) error {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return fmt.Errorf("%w: %s requires %s, installed version %q is not a valid version", ErrDependencyVersion, d.ID, d.Constraint, version)
+	}
+	if !d.Constraint.Check(v) {
+		return fmt.Errorf("%w: requires %s, installed version is %s", ErrDependencyVersion, d, version)
+	}
+	return nil
+}
+
+// ParseDependencies 解析元数据中声明的所有依赖
+func (m PluginMetadata) ParseDependencies() ([]Dependency, error) {
+	deps := make([]Dependency, 0, len(m.Dependencies))
+	for _, s := range m.Dependencies {
+		dep, err := ParseDependency(s)
+		if err != nil {
+			return nil, err
+		}
+		if dep.ID == m.ID {
+			return nil, fmt.Errorf("%w: plugin %s depends on itself", ErrDependencyCycle, m.ID)
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// SortByDependencies 按依赖关系排序插件，被依赖的插件排在前面。
+// 依赖缺失、版本不满足或存在循环依赖的插件（以及依赖它们的插件）不会出现在结果中，原因通过错误返回
+func SortByDependencies(metadata []PluginMetadata) ([]PluginMetadata, error) {
+	byID := make(map[string]PluginMetadata, len(metadata))
+	ids := make([]string, 0, len(metadata))
+	for _, m := range metadata {
+		if _, exists := byID[m.ID]; exists {
+			continue
+		}
+		byID[m.ID] = m
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(ids))
+	failed := make(map[string]error)
+	var ordered []PluginMetadata
+	var errs []error
+
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case done:
+			return failed[id]
+		case visiting:
+			// 从路径中找到循环的起点
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), id)
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+		}
+
+		state[id] = visiting
+		m := byID[id]
+		err := func() error {
+			deps, err := m.ParseDependencies()
+			if err != nil {
+				return err
+			}
+			for _, dep := range deps {
+				depMeta, exists := byID[dep.ID]
+				if !exists {
+					return fmt.Errorf("%w: requires %s", ErrDependencyMissing, dep)
+				}
+				if err := visit(dep.ID, append(path, id)); err != nil {
+					if errors.Is(err, ErrDependencyCycle) {
+						return err
+					}
+					return fmt.Errorf("dependency %s cannot be loaded", dep.ID)
+				}
+				if err := dep.Check(depMeta.Version); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		state[id] = done
+
+		if err != nil {
+			failed[id] = err
+			errs = append(errs, fmt.Errorf("plugin %s: %w", id, err))
+			return err
+		}
+		ordered = append(ordered, m)
+		return nil
+	}
+
+	for _, id := range ids {
+		visit(id, nil)
+	}
+
+	return ordered, errors.Join(errs...)
+}
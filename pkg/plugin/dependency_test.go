@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		input      string
+		id         string
+		constraint string
+		wantErr    bool
+	}{
+		{input: "file", id: "file", constraint: "*"},
+		{input: " file ", id: "file", constraint: "*"},
+		{input: "file >=1.0.0, <2.0.0", id: "file", constraint: ">=1.0.0, <2.0.0"},
+		{input: "proxy@^1.2", id: "proxy", constraint: "^1.2"},
+		{input: "proxy^1.2", id: "proxy", constraint: "^1.2"},
+		{input: "shell 1.0.0", id: "shell", constraint: "1.0.0"},
+		{input: "", wantErr: true},
+		{input: ">=1.0.0", wantErr: true},
+		{input: "file >=x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			dep, err := ParseDependency(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDependency(%q) = %v, expected error", tt.input, dep)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDependency(%q): %v", tt.input, err)
+			}
+			if dep.ID != tt.id || dep.Constraint.String() != tt.constraint {
+				t.Errorf("ParseDependency(%q) = %s %s, want %s %s", tt.input, dep.ID, dep.Constraint, tt.id, tt.constraint)
+			}
+		})
+	}
+}
+
+// testMetadata 构造测试用的插件元数据
+func testMetadata(id, version string, deps ...string) PluginMetadata {
+	return PluginMetadata{ID: id, Version: version, Dependencies: deps}
+}
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata []PluginMetadata
+		want     []string
+		wantErrs []error
+	}{
+		{
+			name:     "no dependencies",
+			metadata: []PluginMetadata{testMetadata("b", "1.0.0"), testMetadata("a", "1.0.0")},
+			want:     []string{"a", "b"},
+		},
+		{
+			name: "chain",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "b"),
+				testMetadata("b", "1.0.0", "c >=1.0.0"),
+				testMetadata("c", "1.2.0"),
+			},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "shared dependency",
+			metadata: []PluginMetadata{
+				testMetadata("web", "1.0.0", "file", "proxy"),
+				testMetadata("proxy", "1.0.0", "file"),
+				testMetadata("file", "1.0.0"),
+			},
+			want: []string{"file", "proxy", "web"},
+		},
+		{
+			name: "duplicate id",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0"),
+				testMetadata("a", "2.0.0"),
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "missing dependency",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "missing"),
+				testMetadata("b", "1.0.0"),
+			},
+			want:     []string{"b"},
+			wantErrs: []error{ErrDependencyMissing},
+		},
+		{
+			name: "dependent of missing dependency",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "missing"),
+				testMetadata("b", "1.0.0", "a"),
+				testMetadata("c", "1.0.0"),
+			},
+			want:     []string{"c"},
+			wantErrs: []error{ErrDependencyMissing},
+		},
+		{
+			name: "version mismatch",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "b ^2.0"),
+				testMetadata("b", "1.5.0"),
+			},
+			want:     []string{"b"},
+			wantErrs: []error{ErrDependencyVersion},
+		},
+		{
+			name: "cycle",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "b"),
+				testMetadata("b", "1.0.0", "c"),
+				testMetadata("c", "1.0.0", "a"),
+				testMetadata("d", "1.0.0"),
+			},
+			want:     []string{"d"},
+			wantErrs: []error{ErrDependencyCycle},
+		},
+		{
+			name: "dependent of cycle",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "b"),
+				testMetadata("b", "1.0.0", "a"),
+				testMetadata("x", "1.0.0", "a"),
+			},
+			want:     []string{},
+			wantErrs: []error{ErrDependencyCycle},
+		},
+		{
+			name: "self dependency",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "a"),
+			},
+			want:     []string{},
+			wantErrs: []error{ErrDependencyCycle},
+		},
+		{
+			name: "missing and cycle",
+			metadata: []PluginMetadata{
+				testMetadata("a", "1.0.0", "b"),
+				testMetadata("b", "1.0.0", "a"),
+				testMetadata("c", "1.0.0", "missing"),
+				testMetadata("d", "1.0.0"),
+			},
+			want:     []string{"d"},
+			wantErrs: []error{ErrDependencyCycle, ErrDependencyMissing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := SortByDependencies(tt.metadata)
+
+			got := []string{}
+			for _, m := range ordered {
+				got = append(got, m.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("error = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import "github.com/sorc/tcpserver/pkg/schema"
+
+// 命令描述的类型定义在pkg/schema中，通信协议不必依赖插件运行时
+type (
+	CommandArg        = schema.CommandArg
+	CommandFlag       = schema.CommandFlag
+	CommandOutput     = schema.CommandOutput
+	CommandDescriptor = schema.CommandDescriptor
+)
+
+// 命令输出格式
+const (
+	OutputText = schema.OutputText
+	OutputJSON = schema.OutputJSON
+)
+
+// ICommandDescriber 插件可选实现的接口，提供命令的参数、选项和输出等描述
+type ICommandDescriber interface {
+	// DescribeCommands 返回命令描述，应包含GetCommands中的所有命令
+	DescribeCommands() []CommandDescriptor
+}
+
+// DescribeCommands 获取插件命令的描述，未实现ICommandDescriber的插件只包含命令名称
+func (pm *DefaultPluginManager) DescribeCommands(id string) ([]CommandDescriptor, error) {
+	handler, err := pm.GetCommandHandler(id)
+	if err != nil {
+		return nil, err
+	}
+
+	described := make(map[string]CommandDescriptor)
+	if describer, ok := handler.(ICommandDescriber); ok {
+		for _, d := range describer.DescribeCommands() {
+			described[d.Name] = d
+		}
+	}
+
+	// 按GetCommands的顺序返回，没有描述的命令只填写名称
+	commands := handler.GetCommands()
+	descriptors := make([]CommandDescriptor, 0, len(commands))
+	for _, name := range commands {
+		d, exists := described[name]
+		if !exists {
+			d = CommandDescriptor{Name: name}
+		}
+		if d.Output.Format == "" {
+			d.Output.Format = OutputText
+		}
+		descriptors = append(descriptors, d)
+	}
+	return descriptors, nil
+}
@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// TopicAll 订阅所有事件的主题
+const TopicAll = "*"
+
+// Event 插件发布的事件
+type Event struct {
+	Topic string
+	// Source 发布事件的插件ID
+	Source string
+	Time   time.Time
+	Data   interface{}
+}
+
+// subscription 事件订阅
+type subscription struct {
+	id      uint64
+	plugin  string
+	topic   string
+	handler func(Event)
+}
+
+// publish 向订阅者发送事件，每个订阅者在单独的协程中处理
+func (pm *DefaultPluginManager) publish(source, topic string, data interface{}) error {
+	if topic == "" || topic == TopicAll {
+		return errors.New("invalid event topic")
+	}
+
+	event := Event{Topic: topic, Source: source, Time: time.Now(), Data: data}
+
+	pm.eventsMu.Lock()
+	var handlers []*subscription
+	for _, sub := range pm.subscriptions {
+		if sub.topic == topic || sub.topic == TopicAll {
+			handlers = append(handlers, sub)
+		}
+	}
+	pm.eventsMu.Unlock()
+
+	for _, sub := range handlers {
+		go func(sub *subscription) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Event handler of plugin %s panicked on %s: %v", sub.plugin, topic, r)
+				}
+			}()
+			sub.handler(event)
+		}(sub)
+	}
+	return nil
+}
+
+// subscribe 订阅事件，返回取消订阅的函数
+func (pm *DefaultPluginManager) subscribe(plugin, topic string, handler func(Event)) (func(), error) {
+	if topic == "" || handler == nil {
+		return nil, errors.New("topic and handler are required")
+	}
+
+	pm.eventsMu.Lock()
+	defer pm.eventsMu.Unlock()
+
+	pm.nextSubscription++
+	id := pm.nextSubscription
+	pm.subscriptions[id] = &subscription{id: id, plugin: plugin, topic: topic, handler: handler}
+
+	return func() {
+		pm.eventsMu.Lock()
+		defer pm.eventsMu.Unlock()
+		delete(pm.subscriptions, id)
+	}, nil
+}
+
+// unsubscribeAll 取消插件的所有订阅，插件卸载时调用
+func (pm *DefaultPluginManager) unsubscribeAll(plugin string) {
+	pm.eventsMu.Lock()
+	defer pm.eventsMu.Unlock()
+
+	for id, sub := range pm.subscriptions {
+		if sub.plugin == plugin {
+			delete(pm.subscriptions, id)
+		}
+	}
+}
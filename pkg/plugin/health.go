@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthStatus 健康状态
+type HealthStatus string
+
+const (
+	// HealthHealthy 正常
+	HealthHealthy HealthStatus = "healthy"
+	// HealthDegraded 部分功能不可用
+	HealthDegraded HealthStatus = "degraded"
+	// HealthUnhealthy 不可用
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+const (
+	// healthPollInterval 定期检查插件健康状态的间隔
+	healthPollInterval = 15 * time.Second
+	// healthCheckTimeout 单个插件健康检查的超时时间
+	healthCheckTimeout = 5 * time.Second
+)
+
+// Health 插件的健康检查结果
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Message 状态的简要说明
+	Message string `json:"message,omitempty"`
+	// Details 各组成部分的状态，如监听器地址和是否可连接
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// HealthChecker 插件可选实现的健康检查接口，插件管理器定期调用。
+// 返回Status为空的结果表示插件当前不提供健康检查，由插件管理器根据插件状态判断
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) Health
+}
+
+// PluginHealth 插件的健康状态
+type PluginHealth struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	Health
+	// CheckedAt 最近一次健康检查的时间，未实现HealthChecker的插件为检查报告的时间
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// HealthReport 所有已启用插件的健康报告，Status为各插件状态中最差的一个
+type HealthReport struct {
+	Status  HealthStatus   `json:"status"`
+	Time    time.Time      `json:"time"`
+	Plugins []PluginHealth `json:"plugins"`
+}
+
+// healthRecord 最近一次健康检查的结果
+type healthRecord struct {
+	health    Health
+	checkedAt time.Time
+}
+
+// healthRank 健康状态的严重程度
+func healthRank(status HealthStatus) int {
+	switch status {
+	case HealthHealthy:
+		return 0
+	case HealthDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// stateName 插件状态名称
+func stateName(state PluginState) string {
+	switch state {
+	case Disabled:
+		return "disabled"
+	case Enabled:
+		return "enabled"
+	case Running:
+		return "running"
+	case Paused:
+		return "paused"
+	default:
+		return fmt.Sprintf("state(%d)", state)
+	}
+}
+
+// pollHealth 定期检查实现了HealthChecker的已启用插件
+func (pm *DefaultPluginManager) pollHealth() {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pm.checkAllHealth()
+
+		select {
+		case <-pm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAllHealth 并发检查所有实现了HealthChecker的已启用插件
+func (pm *DefaultPluginManager) checkAllHealth() {
+	var wg sync.WaitGroup
+	for _, p := range pm.ListPlugins() {
+		checker, ok := p.(HealthChecker)
+		if !ok || p.State() == Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func(id string, checker HealthChecker) {
+			defer wg.Done()
+			pm.checkHealth(id, checker)
+		}(p.ID(), checker)
+	}
+	wg.Wait()
+}
+
+// checkHealth 检查插件的健康状态并记录结果，检查超时或panic时记为unhealthy
+func (pm *DefaultPluginManager) checkHealth(id string, checker HealthChecker) healthRecord {
+	ctx, cancel := context.WithTimeout(pm.ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := make(chan Health, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- Health{Status: HealthUnhealthy, Message: fmt.Sprintf("health check panicked: %v", r)}
+			}
+		}()
+		result <- checker.CheckHealth(ctx)
+	}()
+
+	var health Health
+	select {
+	case health = <-result:
+	case <-ctx.Done():
+		health = Health{Status: HealthUnhealthy, Message: fmt.Sprintf("health check did not complete within %v", healthCheckTimeout)}
+	}
+
+	record := healthRecord{health: health, checkedAt: time.Now()}
+	pm.healthMu.Lock()
+	pm.health[id] = record
+	pm.healthMu.Unlock()
+	return record
+}
+
+// HealthReport 返回已启用插件的健康报告。实现了HealthChecker的插件使用最近一次定期检查的结果，
+// 其他插件根据状态判断：被隔离、服务报告故障或期望运行的服务未运行时为unhealthy
+func (pm *DefaultPluginManager) HealthReport() HealthReport {
+	now := time.Now()
+	report := HealthReport{Status: HealthHealthy, Time: now}
+
+	plugins := pm.ListPlugins()
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].ID() < plugins[j].ID() })
+
+	for _, p := range plugins {
+		id := p.ID()
+		state := p.State()
+
+		entry := PluginHealth{ID: id, State: stateName(state), CheckedAt: now}
+		if record, quarantined := pm.Quarantined(id); quarantined {
+			entry.Health = Health{Status: HealthUnhealthy, Message: "quarantined: " + record.Reason}
+		} else if state == Disabled {
+			continue
+		} else {
+			entry.Health = pm.stateHealth(p)
+			if checker, ok := p.(HealthChecker); ok && entry.Status == HealthHealthy {
+				pm.healthMu.Lock()
+				record, checked := pm.health[id]
+				pm.healthMu.Unlock()
+				// 加载后尚未定期检查过的插件立即检查一次
+				if !checked {
+					record = pm.checkHealth(id, checker)
+				}
+				if record.health.Status != "" {
+					entry.Health = record.health
+					entry.CheckedAt = record.checkedAt
+				}
+			}
+		}
+
+		if healthRank(entry.Status) > healthRank(report.Status) {
+			report.Status = entry.Status
+		}
+		report.Plugins = append(report.Plugins, entry)
+	}
+	return report
+}
+
+// stateHealth 根据插件状态和服务监管状态判断健康状态
+func (pm *DefaultPluginManager) stateHealth(p Plugin) Health {
+	sp, ok := p.(IServicePlugin)
+	if !ok || p.Type() != ServicePlugin {
+		return Health{Status: HealthHealthy}
+	}
+
+	state := sp.State()
+	if watcher, ok := p.(IServiceWatcher); ok && (state == Running || state == Paused) {
+		if err := watcher.Failed(); err != nil {
+			return Health{Status: HealthUnhealthy, Message: err.Error()}
+		}
+	}
+
+	if state == Paused {
+		return Health{Status: HealthHealthy, Message: "service is paused"}
+	}
+
+	status, err := pm.GetServiceStatus(p.ID())
+	if err == nil && status.Supervised && state != Running {
+		message := "service is expected to run but is " + stateName(state)
+		if status.LastError != "" {
+			message += ": " + status.LastError
+		}
+		return Health{Status: HealthUnhealthy, Message: message}
+	}
+	return Health{Status: HealthHealthy}
+}
+
+// forgetHealth 删除插件的健康检查记录，插件卸载时调用
+func (pm *DefaultPluginManager) forgetHealth(id string) {
+	pm.healthMu.Lock()
+	defer pm.healthMu.Unlock()
+	delete(pm.health, id)
+}
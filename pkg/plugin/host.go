@@ -0,0 +1,217 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrAccessDenied    = errors.New("plugin access denied")
+	ErrHostUnsupported = errors.New("host service not available to this plugin")
+)
+
+// CapabilityManagePlugins 允许插件通过Host.Manager访问插件管理器，在元数据的capabilities中声明
+const CapabilityManagePlugins = "manage_plugins"
+
+// Caller 执行命令的客户端
+type Caller struct {
+	ClientID    string   `json:"client_id"`
+	SessionID   string   `json:"session_id,omitempty"`
+	RemoteAddr  string   `json:"remote_addr,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// HasPermission 检查客户端是否有指定权限
+func (c Caller) HasPermission(perm string) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPluginPermission 检查客户端是否有使用特定插件的权限
+func (c Caller) HasPluginPermission(pluginID string) bool {
+	return c.HasPermission("plugin:use") || c.HasPermission(fmt.Sprintf("plugin:%s:use", pluginID))
+}
+
+// Host 服务器提供给插件的服务。插件在Init和Execute时通过HostFromContext获取，
+// Execute时的Host还包含执行命令的客户端
+type Host interface {
+	// PluginID 返回Host所属插件的ID
+	PluginID() string
+	// Logger 返回带插件ID前缀的日志记录器
+	Logger() *log.Logger
+	// DataDir 返回插件专用的数据目录（data_dir/<id>），目录已创建
+	DataDir() string
+	// Caller 返回执行命令的客户端，不在命令执行中时返回false
+	Caller() (Caller, bool)
+	// RegisterMetric 注册指标，collect在读取指标时调用，同名指标会被替换，插件卸载时自动注销
+	RegisterMetric(name, help string, collect func() float64) error
+	// Publish 发布事件，订阅者在各自的协程中收到事件
+	Publish(topic string, data interface{}) error
+	// Subscribe 订阅事件，topic为*时订阅所有事件，返回取消订阅的函数，插件卸载时自动取消
+	Subscribe(topic string, handler func(Event)) (func(), error)
+	// Plugin 获取其他插件，只能访问元数据中声明依赖的插件。Init期间不能调用
+	Plugin(id string) (Plugin, error)
+	// Manager 获取插件管理器，插件需要在元数据中声明manage_plugins能力
+	Manager() (PluginManager, error)
+	// Invoke 以执行命令的客户端身份执行其他插件的命令，args[0]为命令名。
+	// 只能调用声明依赖的插件，客户端需要有相应权限，ctx取消时命令一并取消，调用记录在审计日志中
+	Invoke(ctx context.Context, pluginID string, args []string, input io.Reader, output io.Writer) error
+	// Intercept 注册命令拦截器，插件需要在元数据中声明intercept_commands能力，插件卸载时自动注销
+	Intercept(name string, interceptor Interceptor) error
+}
+
+// hostKey 上下文中Host的键
+type hostKey struct{}
+
+// WithHost 返回携带Host的上下文
+func WithHost(ctx context.Context, host Host) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+// HostFromContext 从上下文中获取Host
+func HostFromContext(ctx context.Context) (Host, bool) {
+	host, ok := ctx.Value(hostKey{}).(Host)
+	return host, ok
+}
+
+// pluginHost 插件管理器为每个插件提供的Host
+type pluginHost struct {
+	pm       *DefaultPluginManager
+	metadata PluginMetadata
+	logger   *log.Logger
+	dataDir  string
+	caller   *Caller
+}
+
+// newHost 创建插件的Host，调用方需持有锁
+func (pm *DefaultPluginManager) newHost(metadata PluginMetadata, caller *Caller) *pluginHost {
+	return &pluginHost{
+		pm:       pm,
+		metadata: metadata,
+		logger:   log.New(log.Writer(), fmt.Sprintf("[%s] ", metadata.ID), log.Flags()|log.Lmsgprefix),
+		dataDir:  filepath.Join(pm.dataDir, metadata.ID),
+		caller:   caller,
+	}
+}
+
+// Host 获取插件的Host，caller为执行命令的客户端，可以为nil
+func (pm *DefaultPluginManager) Host(id string, caller *Caller) (Host, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	metadata, exists := pm.metadata[id]
+	if !exists {
+		return nil, ErrPluginNotFound
+	}
+	return pm.newHost(metadata, caller), nil
+}
+
+// SetDataDir 设置插件数据目录的根目录，默认为data
+func (pm *DefaultPluginManager) SetDataDir(dir string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.dataDir = dir
+}
+
+// PluginID 返回Host所属插件的ID
+func (h *pluginHost) PluginID() string {
+	return h.metadata.ID
+}
+
+// Logger 返回带插件ID前缀的日志记录器
+func (h *pluginHost) Logger() *log.Logger {
+	return h.logger
+}
+
+// DataDir 返回插件专用的数据目录
+func (h *pluginHost) DataDir() string {
+	if err := os.MkdirAll(h.dataDir, 0755); err != nil {
+		h.logger.Printf("Failed to create data directory %s: %v", h.dataDir, err)
+	}
+	return h.dataDir
+}
+
+// Caller 返回执行命令的客户端
+func (h *pluginHost) Caller() (Caller, bool) {
+	if h.caller == nil {
+		return Caller{}, false
+	}
+	return *h.caller, true
+}
+
+// RegisterMetric 注册指标
+func (h *pluginHost) RegisterMetric(name, help string, collect func() float64) error {
+	return h.pm.registerMetric(h.metadata.ID, name, help, collect)
+}
+
+// Publish 发布事件
+func (h *pluginHost) Publish(topic string, data interface{}) error {
+	return h.pm.publish(h.metadata.ID, topic, data)
+}
+
+// Subscribe 订阅事件
+func (h *pluginHost) Subscribe(topic string, handler func(Event)) (func(), error) {
+	return h.pm.subscribe(h.metadata.ID, topic, handler)
+}
+
+// Plugin 获取声明了依赖的插件
+func (h *pluginHost) Plugin(id string) (Plugin, error) {
+	if !h.hasCapability(CapabilityManagePlugins) && !h.dependsOn(id) {
+		return nil, fmt.Errorf("%w: %s does not declare a dependency on %s", ErrAccessDenied, h.metadata.ID, id)
+	}
+	return h.pm.GetPlugin(id)
+}
+
+// Manager 获取插件管理器
+func (h *pluginHost) Manager() (PluginManager, error) {
+	if !h.hasCapability(CapabilityManagePlugins) {
+		return nil, fmt.Errorf("%w: %s requires the %s capability", ErrAccessDenied, h.metadata.ID, CapabilityManagePlugins)
+	}
+	return h.pm, nil
+}
+
+// Invoke 执行其他插件的命令
+func (h *pluginHost) Invoke(ctx context.Context, pluginID string, args []string, input io.Reader, output io.Writer) error {
+	return h.pm.invoke(ctx, h, pluginID, args, input, output)
+}
+
+// Intercept 注册命令拦截器
+func (h *pluginHost) Intercept(name string, interceptor Interceptor) error {
+	if !h.hasCapability(CapabilityInterceptCommands) {
+		return fmt.Errorf("%w: %s requires the %s capability", ErrAccessDenied, h.metadata.ID, CapabilityInterceptCommands)
+	}
+	return h.pm.addInterceptor(h.metadata.ID, name, interceptor)
+}
+
+// dependsOn 检查插件是否声明了对id的依赖
+func (h *pluginHost) dependsOn(id string) bool {
+	deps, err := h.metadata.ParseDependencies()
+	if err != nil {
+		return false
+	}
+	for _, dep := range deps {
+		if dep.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCapability 检查插件是否声明了能力
+func (h *pluginHost) hasCapability(capability string) bool {
+	for _, c := range h.metadata.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// CapabilityInterceptCommands 允许插件通过Host.Intercept注册命令拦截器，在元数据的capabilities中声明
+const CapabilityInterceptCommands = "intercept_commands"
+
+// CommandInvocation 一次命令执行，拦截器可以修改其中的参数、输入和输出
+type CommandInvocation struct {
+	// Plugin 执行命令的插件ID
+	Plugin string
+	// Args 命令及参数，Args[0]为命令名
+	Args []string
+	// Caller 执行命令的客户端，服务器内部执行时为nil
+	Caller *Caller
+	// Via 通过Host.Invoke发起调用的插件链，客户端直接执行的命令为空
+	Via    []string
+	Input  io.Reader
+	Output io.Writer
+}
+
+// Command 返回命令名
+func (inv *CommandInvocation) Command() string {
+	if len(inv.Args) == 0 {
+		return ""
+	}
+	return inv.Args[0]
+}
+
+// CommandFunc 执行命令的函数
+type CommandFunc func(ctx context.Context, inv *CommandInvocation) error
+
+// Interceptor 命令拦截器，调用next继续执行，不调用next即拒绝执行
+type Interceptor func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error
+
+// interceptorEntry 已注册的拦截器
+type interceptorEntry struct {
+	name string
+	// plugin 注册拦截器的插件ID，服务器注册的为空
+	plugin      string
+	interceptor Interceptor
+}
+
+// Use 注册命令拦截器，先注册的拦截器在外层。同名拦截器会被替换并保持原来的位置
+func (pm *DefaultPluginManager) Use(name string, interceptor Interceptor) error {
+	return pm.addInterceptor("", name, interceptor)
+}
+
+// addInterceptor 注册拦截器，plugin为注册拦截器的插件ID
+func (pm *DefaultPluginManager) addInterceptor(plugin, name string, interceptor Interceptor) error {
+	if name == "" || interceptor == nil {
+		return errors.New("interceptor name and function are required")
+	}
+
+	pm.interceptorsMu.Lock()
+	defer pm.interceptorsMu.Unlock()
+
+	entry := interceptorEntry{name: name, plugin: plugin, interceptor: interceptor}
+	for i, e := range pm.interceptors {
+		if e.name == name {
+			if e.plugin != plugin {
+				return fmt.Errorf("interceptor %s is already registered by %s", name, interceptorOwner(e.plugin))
+			}
+			pm.interceptors[i] = entry
+			return nil
+		}
+	}
+	pm.interceptors = append(pm.interceptors, entry)
+	return nil
+}
+
+// removeInterceptors 注销插件注册的拦截器，插件卸载时调用
+func (pm *DefaultPluginManager) removeInterceptors(plugin string) {
+	pm.interceptorsMu.Lock()
+	defer pm.interceptorsMu.Unlock()
+
+	kept := pm.interceptors[:0]
+	for _, e := range pm.interceptors {
+		if e.plugin != plugin {
+			kept = append(kept, e)
+		}
+	}
+	pm.interceptors = kept
+}
+
+// Interceptors 返回已注册的拦截器名称，按执行顺序从外到内排列
+func (pm *DefaultPluginManager) Interceptors() []string {
+	pm.interceptorsMu.RLock()
+	defer pm.interceptorsMu.RUnlock()
+
+	names := make([]string, 0, len(pm.interceptors))
+	for _, e := range pm.interceptors {
+		if e.plugin != "" {
+			names = append(names, fmt.Sprintf("%s (%s)", e.name, e.plugin))
+		} else {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// ExecuteCommand 经过拦截器链执行插件命令，插件和拦截器中的panic转换为错误返回
+func (pm *DefaultPluginManager) ExecuteCommand(ctx context.Context, inv *CommandInvocation) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if inner, ok := r.(nextPanic); ok {
+				r = inner.value
+			}
+			log.Printf("Panic while executing %s %v: %v\n%s", inv.Plugin, inv.Args, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", ErrPluginPanicked, r)
+		}
+	}()
+
+	if len(inv.Args) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+
+	pm.interceptorsMu.RLock()
+	chain := make([]interceptorEntry, len(pm.interceptors))
+	copy(chain, pm.interceptors)
+	pm.interceptorsMu.RUnlock()
+
+	next := pm.executeCommand
+	for i := len(chain) - 1; i >= 0; i-- {
+		interceptor, inner := chain[i].interceptor, next
+		if chain[i].plugin != "" {
+			if !pm.interceptorActive(chain[i].plugin) {
+				continue
+			}
+			interceptor = pm.recoverInterceptor(chain[i])
+		}
+		next = func(ctx context.Context, inv *CommandInvocation) error {
+			return interceptor(ctx, inv, inner)
+		}
+	}
+	return next(ctx, inv)
+}
+
+// executeCommand 拦截器链的末端，在隔离的协程中调用插件的命令接口
+func (pm *DefaultPluginManager) executeCommand(ctx context.Context, inv *CommandInvocation) error {
+	handler, err := pm.GetCommandHandler(inv.Plugin)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", inv.Plugin, err)
+	}
+	host, err := pm.Host(inv.Plugin, inv.Caller)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", inv.Plugin, err)
+	}
+
+	ctx = WithHost(ctx, host)
+	return pm.runIsolated(ctx, inv, func(ctx context.Context, inv *CommandInvocation) error {
+		return handler.Execute(ctx, inv.Args, inv.Input, inv.Output)
+	})
+}
+
+// interceptorOwner 格式化拦截器的注册者
+func interceptorOwner(plugin string) string {
+	if plugin == "" {
+		return "the server"
+	}
+	return "plugin " + plugin
+}
+
+// PermissionInterceptor 检查客户端是否有使用插件的权限，以及命令描述中声明的权限，服务器内部执行的命令不检查
+func PermissionInterceptor(pm PluginManager) Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		if inv.Caller != nil {
+			if err := checkCommandPermissions(pm, *inv.Caller, inv.Plugin, inv.Command()); err != nil {
+				return err
+			}
+		}
+		return next(ctx, inv)
+	}
+}
+
+// checkCommandPermissions 检查客户端是否有使用插件id的权限，以及命令描述中为command声明的权限
+func checkCommandPermissions(pm PluginManager, caller Caller, id, command string) error {
+	if !caller.HasPluginPermission(id) {
+		return fmt.Errorf("%w: client %s has no permission to use plugin %s", ErrAccessDenied, caller.ClientID, id)
+	}
+
+	descriptors, err := pm.DescribeCommands(id)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", id, err)
+	}
+	for _, d := range descriptors {
+		if d.Name != command {
+			continue
+		}
+		for _, perm := range d.Permissions {
+			if !caller.HasPermission(perm) {
+				return fmt.Errorf("%w: client %s lacks %s required by %s %s", ErrAccessDenied, caller.ClientID, perm, id, d.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// StateInterceptor 拒绝执行已禁用或被隔离插件的命令，已停止或暂停的服务类插件仍可执行命令（如查询状态）
+func StateInterceptor(pm PluginManager) Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		if record, quarantined := pm.Quarantined(inv.Plugin); quarantined {
+			return fmt.Errorf("%w: %s (%s), enable it to release", ErrPluginQuarantined, inv.Plugin, record.Reason)
+		}
+		p, err := pm.GetPlugin(inv.Plugin)
+		if err != nil {
+			return fmt.Errorf("failed to get plugin: %w", err)
+		}
+		if p.State() == Disabled {
+			return fmt.Errorf("%w: %s", ErrPluginDisabled, inv.Plugin)
+		}
+		return next(ctx, inv)
+	}
+}
+
+// LoggingInterceptor 记录命令的开始、结束和执行时间
+func LoggingInterceptor() Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		client := "-"
+		if inv.Caller != nil {
+			client = inv.Caller.ClientID
+		}
+		log.Printf("Executing command: client=%s, plugin=%s, args=%v, via=%s", client, inv.Plugin, inv.Args, strings.Join(inv.Via, ">"))
+
+		start := time.Now()
+		err := next(ctx, inv)
+		log.Printf("Command %s %s completed in %v with error: %v", inv.Plugin, inv.Command(), time.Since(start), err)
+		return err
+	}
+}
+
+// AuditInterceptor 将命令执行追加到审计记录
+func AuditInterceptor(pm PluginManager) Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		start := time.Now()
+		err := next(ctx, inv)
+
+		record := AuditRecord{
+			Time:     start,
+			Plugin:   inv.Plugin,
+			Args:     inv.Args,
+			Via:      inv.Via,
+			Duration: time.Since(start).Milliseconds(),
+		}
+		if inv.Caller != nil {
+			record.ClientID = inv.Caller.ClientID
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		pm.RecordAudit(record)
+		return err
+	}
+}
@@ -0,0 +1,360 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCommandPlugin 测试用的命令插件，记录执行过的命令
+type testCommandPlugin struct {
+	*BaseCommandPlugin
+	descriptors []CommandDescriptor
+	executed    []string
+}
+
+func (p *testCommandPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
+	p.executed = append(p.executed, args[0])
+	return nil
+}
+
+func (p *testCommandPlugin) GetCommands() []string {
+	commands := make([]string, 0, len(p.descriptors))
+	for _, d := range p.descriptors {
+		commands = append(commands, d.Name)
+	}
+	return commands
+}
+
+func (p *testCommandPlugin) DescribeCommands() []CommandDescriptor {
+	return p.descriptors
+}
+
+// newTestManager 创建使用临时目录的插件管理器，测试结束时关闭
+func newTestManager(t *testing.T) *DefaultPluginManager {
+	t.Helper()
+	pm := NewPluginManager(t.TempDir(), t.TempDir()).(*DefaultPluginManager)
+	pm.SetDataDir(t.TempDir())
+	t.Cleanup(func() { pm.Shutdown() })
+	return pm
+}
+
+// registerTestCommands 注册测试用的命令插件
+func registerTestCommands(t *testing.T, pm *DefaultPluginManager, id string, descriptors ...CommandDescriptor) *testCommandPlugin {
+	t.Helper()
+	p := &testCommandPlugin{
+		BaseCommandPlugin: NewBaseCommandPlugin(id, id, "1.0.0", OneTimeCommand),
+		descriptors:       descriptors,
+	}
+	if err := pm.RegisterPlugin(p); err != nil {
+		t.Fatalf("RegisterPlugin(%s): %v", id, err)
+	}
+	return p
+}
+
+func TestPermissionInterceptor(t *testing.T) {
+	pm := newTestManager(t)
+	p := registerTestCommands(t, pm, "admin",
+		CommandDescriptor{Name: "list"},
+		CommandDescriptor{Name: "install", Permissions: []string{"plugin:manage"}},
+	)
+	if err := pm.Use("permission", PermissionInterceptor(pm)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		caller  *Caller
+		command string
+		wantErr error
+	}{
+		{name: "server", caller: nil, command: "install"},
+		{name: "no permissions", caller: &Caller{ClientID: "c"}, command: "list", wantErr: ErrAccessDenied},
+		{name: "plugin use", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:use"}}, command: "list"},
+		{name: "plugin scoped use", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:admin:use"}}, command: "list"},
+		{name: "other plugin scoped use", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:other:use"}}, command: "list", wantErr: ErrAccessDenied},
+		{name: "plugin use without manage", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:use"}}, command: "install", wantErr: ErrAccessDenied},
+		{name: "manage without use", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:manage"}}, command: "install", wantErr: ErrAccessDenied},
+		{name: "plugin use and manage", caller: &Caller{ClientID: "c", Permissions: []string{"plugin:use", "plugin:manage"}}, command: "install"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.executed = nil
+			err := pm.ExecuteCommand(context.Background(), &CommandInvocation{
+				Plugin: "admin",
+				Args:   []string{tt.command},
+				Caller: tt.caller,
+				Output: io.Discard,
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if len(p.executed) != 0 {
+					t.Errorf("refused command was executed: %v", p.executed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(p.executed) != 1 || p.executed[0] != tt.command {
+				t.Errorf("executed = %v, want [%s]", p.executed, tt.command)
+			}
+		})
+	}
+}
+
+// denyInterceptor 拒绝所有命令的拦截器
+func denyInterceptor(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+	return ErrAccessDenied
+}
+
+func TestInterceptorSkippedForInactivePlugin(t *testing.T) {
+	tests := []struct {
+		name       string
+		deactivate func(pm *DefaultPluginManager) error
+	}{
+		{name: "disabled", deactivate: func(pm *DefaultPluginManager) error { return pm.DisablePlugin("guard", false) }},
+		{name: "quarantined", deactivate: func(pm *DefaultPluginManager) error {
+			pm.quarantined["guard"] = QuarantineRecord{Reason: "test"}
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := newTestManager(t)
+			registerTestCommands(t, pm, "target", CommandDescriptor{Name: "run"})
+			guard := registerTestCommands(t, pm, "guard")
+			guard.SetState(Enabled)
+			if err := pm.addInterceptor("guard", "deny", denyInterceptor); err != nil {
+				t.Fatal(err)
+			}
+
+			inv := &CommandInvocation{Plugin: "target", Args: []string{"run"}, Output: io.Discard}
+			if err := pm.ExecuteCommand(context.Background(), inv); !errors.Is(err, ErrAccessDenied) {
+				t.Fatalf("active interceptor: error = %v, want %v", err, ErrAccessDenied)
+			}
+
+			if err := tt.deactivate(pm); err != nil {
+				t.Fatal(err)
+			}
+			if err := pm.ExecuteCommand(context.Background(), inv); err != nil {
+				t.Fatalf("inactive interceptor still applied: %v", err)
+			}
+
+			// 重新启用后拦截器恢复生效
+			pm.release("guard")
+			guard.SetState(Enabled)
+			if err := pm.ExecuteCommand(context.Background(), inv); !errors.Is(err, ErrAccessDenied) {
+				t.Fatalf("re-enabled interceptor: error = %v, want %v", err, ErrAccessDenied)
+			}
+		})
+	}
+}
+
+func TestRecoverInterceptorBlame(t *testing.T) {
+	passThrough := func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		return next(ctx, inv)
+	}
+	panicking := func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		panic("boom")
+	}
+
+	// 拦截器从外到内依次为guard插件、服务器、other插件
+	tests := []struct {
+		name      string
+		guard     Interceptor
+		server    Interceptor
+		other     Interceptor
+		wantGuard int
+		wantOther int
+	}{
+		{name: "guard panics", guard: panicking, server: passThrough, other: passThrough, wantGuard: 1},
+		{name: "server interceptor panics", guard: passThrough, server: panicking, other: passThrough},
+		{name: "inner plugin interceptor panics", guard: passThrough, server: passThrough, other: panicking, wantOther: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := newTestManager(t)
+			registerTestCommands(t, pm, "target", CommandDescriptor{Name: "run"})
+			for _, id := range []string{"guard", "other"} {
+				registerTestCommands(t, pm, id).SetState(Enabled)
+			}
+			if err := pm.addInterceptor("guard", "guard", tt.guard); err != nil {
+				t.Fatal(err)
+			}
+			if err := pm.Use("server", tt.server); err != nil {
+				t.Fatal(err)
+			}
+			if err := pm.addInterceptor("other", "other", tt.other); err != nil {
+				t.Fatal(err)
+			}
+
+			err := pm.ExecuteCommand(context.Background(), &CommandInvocation{Plugin: "target", Args: []string{"run"}, Output: io.Discard})
+			if !errors.Is(err, ErrPluginPanicked) {
+				t.Fatalf("error = %v, want %v", err, ErrPluginPanicked)
+			}
+
+			pm.isolationMu.Lock()
+			gotGuard, gotOther := len(pm.panics["guard"]), len(pm.panics["other"])
+			pm.isolationMu.Unlock()
+			if gotGuard != tt.wantGuard || gotOther != tt.wantOther {
+				t.Errorf("panics recorded: guard %d, other %d; want guard %d, other %d", gotGuard, gotOther, tt.wantGuard, tt.wantOther)
+			}
+		})
+	}
+}
+
+// tracingInterceptor 在next前后记录拦截器名称，deny为true时不调用next
+func tracingInterceptor(trace *[]string, name string, deny bool) Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		*trace = append(*trace, name+">")
+		if deny {
+			return fmt.Errorf("%w: denied by %s", ErrAccessDenied, name)
+		}
+		err := next(ctx, inv)
+		*trace = append(*trace, "<"+name)
+		return err
+	}
+}
+
+func TestInterceptorChain(t *testing.T) {
+	// 拦截器从外到内依次为服务器的outer、guard插件的middle、服务器的inner
+	tests := []struct {
+		name         string
+		deny         string
+		wantTrace    []string
+		wantExecuted bool
+	}{
+		{name: "all pass", wantTrace: []string{"outer>", "middle>", "inner>", "<inner", "<middle", "<outer"}, wantExecuted: true},
+		{name: "outer denies", deny: "outer", wantTrace: []string{"outer>"}},
+		{name: "plugin interceptor denies", deny: "middle", wantTrace: []string{"outer>", "middle>", "<outer"}},
+		{name: "inner denies", deny: "inner", wantTrace: []string{"outer>", "middle>", "inner>", "<middle", "<outer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := newTestManager(t)
+			target := registerTestCommands(t, pm, "target", CommandDescriptor{Name: "run"})
+			registerTestCommands(t, pm, "guard").SetState(Enabled)
+
+			var trace []string
+			if err := pm.Use("outer", tracingInterceptor(&trace, "outer", tt.deny == "outer")); err != nil {
+				t.Fatal(err)
+			}
+			if err := pm.addInterceptor("guard", "middle", tracingInterceptor(&trace, "middle", tt.deny == "middle")); err != nil {
+				t.Fatal(err)
+			}
+			if err := pm.Use("inner", tracingInterceptor(&trace, "inner", tt.deny == "inner")); err != nil {
+				t.Fatal(err)
+			}
+
+			err := pm.ExecuteCommand(context.Background(), &CommandInvocation{Plugin: "target", Args: []string{"run"}, Output: io.Discard})
+			if tt.wantExecuted {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if !errors.Is(err, ErrAccessDenied) || !strings.Contains(err.Error(), tt.deny) {
+				t.Fatalf("error = %v, want denial by %s", err, tt.deny)
+			}
+			if !reflect.DeepEqual(trace, tt.wantTrace) {
+				t.Errorf("trace = %v, want %v", trace, tt.wantTrace)
+			}
+			if executed := len(target.executed) == 1; executed != tt.wantExecuted {
+				t.Errorf("command executed = %v, want %v", executed, tt.wantExecuted)
+			}
+		})
+	}
+}
+
+func TestInterceptorRegistration(t *testing.T) {
+	pm := newTestManager(t)
+	registerTestCommands(t, pm, "guard").SetState(Enabled)
+	noop := func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		return next(ctx, inv)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if err := pm.Use(name, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pm.addInterceptor("guard", "c", noop); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同名拦截器替换后保持原来的位置，不能替换其他注册者的拦截器
+	if err := pm.Use("a", noop); err != nil {
+		t.Errorf("replacing own interceptor: %v", err)
+	}
+	if err := pm.Use("c", noop); err == nil {
+		t.Error("server replaced an interceptor registered by a plugin")
+	}
+	if err := pm.addInterceptor("guard", "b", noop); err == nil {
+		t.Error("plugin replaced an interceptor registered by the server")
+	}
+	if err := pm.Use("", noop); err == nil {
+		t.Error("interceptor without name was registered")
+	}
+
+	want := []string{"a", "b", "c (guard)"}
+	if got := pm.Interceptors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Interceptors() = %v, want %v", got, want)
+	}
+}
+
+func TestInterceptorsRemovedOnUnload(t *testing.T) {
+	pm := newTestManager(t)
+	target := registerTestCommands(t, pm, "target", CommandDescriptor{Name: "run"})
+	registerTestCommands(t, pm, "guard").SetState(Enabled)
+	if err := pm.Use("server", func(ctx context.Context, inv *CommandInvocation, next CommandFunc) error {
+		return next(ctx, inv)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 插件通过Host注册拦截器需要声明intercept_commands能力
+	host, err := pm.Host("guard", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Intercept("deny", denyInterceptor); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("Intercept without capability: error = %v, want %v", err, ErrAccessDenied)
+	}
+	metadata := pm.metadata["guard"]
+	metadata.Capabilities = []string{CapabilityInterceptCommands}
+	pm.metadata["guard"] = metadata
+	if host, err = pm.Host("guard", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Intercept("deny", denyInterceptor); err != nil {
+		t.Fatalf("Intercept: %v", err)
+	}
+
+	inv := &CommandInvocation{Plugin: "target", Args: []string{"run"}, Output: io.Discard}
+	if err := pm.ExecuteCommand(context.Background(), inv); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("error = %v, want %v", err, ErrAccessDenied)
+	}
+
+	// 卸载插件后注销其拦截器，服务器注册的拦截器保留
+	if err := pm.UnloadPlugin("guard", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := pm.Interceptors(); !reflect.DeepEqual(got, []string{"server"}) {
+		t.Errorf("Interceptors() = %v, want [server]", got)
+	}
+	if err := pm.ExecuteCommand(context.Background(), inv); err != nil {
+		t.Fatalf("interceptor of unloaded plugin still applied: %v", err)
+	}
+	if len(target.executed) != 1 {
+		t.Errorf("executed = %v, want one command", target.executed)
+	}
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrInvokeCycle = errors.New("plugin invocation cycle")
+)
+
+// maxInvokeDepth 插件间调用的最大嵌套层数
+const maxInvokeDepth = 8
+
+// invokeChainKey 上下文中插件调用链的键
+type invokeChainKey struct{}
+
+// invokeChain 返回上下文中发起调用的插件链
+func invokeChain(ctx context.Context) []string {
+	chain, _ := ctx.Value(invokeChainKey{}).([]string)
+	return chain
+}
+
+// invoke 以调用方客户端的身份经过拦截器链执行插件id的命令。
+// 调用方必须声明了对id的依赖（或具有manage_plugins能力），客户端必须有使用该插件和该命令所需的权限
+func (pm *DefaultPluginManager) invoke(ctx context.Context, from *pluginHost, id string, args []string, input io.Reader, output io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+	if from.caller == nil {
+		return fmt.Errorf("%w: %s cannot invoke %s outside of a client command", ErrAccessDenied, from.metadata.ID, id)
+	}
+	caller := *from.caller
+
+	if !from.hasCapability(CapabilityManagePlugins) && !from.dependsOn(id) {
+		return fmt.Errorf("%w: %s does not declare a dependency on %s", ErrAccessDenied, from.metadata.ID, id)
+	}
+
+	// 调用链包含发起方，防止插件间互相调用形成循环
+	chain := append(append([]string(nil), invokeChain(ctx)...), from.metadata.ID)
+	if containsString(chain, id) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvokeCycle, strings.Join(chain, " -> "), id)
+	}
+	if len(chain) > maxInvokeDepth {
+		return fmt.Errorf("%w: invocation depth exceeds %d", ErrInvokeCycle, maxInvokeDepth)
+	}
+
+	if err := checkCommandPermissions(pm, caller, id, args[0]); err != nil {
+		return err
+	}
+
+	// 被调用的插件使用调用方的上下文，客户端断开或调用方取消时一并取消；
+	// 状态检查和审计由拦截器链完成
+	ctx = context.WithValue(ctx, invokeChainKey{}, chain)
+	return pm.ExecuteCommand(ctx, &CommandInvocation{
+		Plugin: id,
+		Args:   args,
+		Caller: &caller,
+		Via:    chain,
+		Input:  input,
+		Output: output,
+	})
+}
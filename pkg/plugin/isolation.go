@@ -0,0 +1,223 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrPluginPanicked    = errors.New("plugin panicked")
+	ErrCommandTimeout    = errors.New("command timed out")
+	ErrPluginQuarantined = errors.New("plugin is quarantined")
+)
+
+const (
+	// defaultMaxPanics 默认隔离插件前允许的panic次数
+	defaultMaxPanics = 3
+	// panicWindow 统计panic次数的时间窗口
+	panicWindow = 10 * time.Minute
+	// abandonGrace 命令被取消或超时后等待插件返回的时间，超过后不再等待
+	abandonGrace = 5 * time.Second
+)
+
+// QuarantineRecord 插件被隔离的原因
+type QuarantineRecord struct {
+	Time   time.Time
+	Reason string
+}
+
+// maxPanics 返回隔离插件前允许的panic次数，0表示不隔离
+func (p PluginPolicy) maxPanics() int {
+	switch {
+	case p.MaxPanics < 0:
+		return 0
+	case p.MaxPanics == 0:
+		return defaultMaxPanics
+	default:
+		return p.MaxPanics
+	}
+}
+
+// commandTimeout 返回命令的最长执行时间，0表示不限制
+func (p PluginPolicy) commandTimeout(command string) time.Duration {
+	if timeout, exists := p.CommandTimeouts[command]; exists {
+		return time.Duration(timeout) * time.Second
+	}
+	return time.Duration(p.Timeout) * time.Second
+}
+
+// policy 返回插件的运行策略
+func (pm *DefaultPluginManager) policy(id string) PluginPolicy {
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+	return pm.policies[id]
+}
+
+// runIsolated 在单独的协程中执行命令：panic转换为错误，超时或取消后插件未及时返回时不再等待，
+// 之后插件写入的输出被丢弃
+func (pm *DefaultPluginManager) runIsolated(ctx context.Context, inv *CommandInvocation, execute CommandFunc) error {
+	command := inv.Command()
+	timeout := pm.policy(inv.Plugin).commandTimeout(command)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	output := &detachableWriter{w: inv.Output}
+	isolated := *inv
+	isolated.Output = output
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- pm.recordPanic(inv.Plugin, fmt.Sprintf("command %s", command), r)
+			}
+		}()
+		done <- execute(ctx, &isolated)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 等待插件响应取消
+		select {
+		case err = <-done:
+		case <-time.After(abandonGrace):
+			output.detach()
+			log.Printf("Plugin %s did not return from command %s within %v after cancellation, abandoning it", inv.Plugin, command, abandonGrace)
+			err = ctx.Err()
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, ErrPluginPanicked) {
+		return fmt.Errorf("%w: %s %s exceeded %v", ErrCommandTimeout, inv.Plugin, command, timeout)
+	}
+	return err
+}
+
+// recordPanic 记录插件的panic和调用栈，时间窗口内次数达到策略上限时隔离插件，返回替代panic的错误
+func (pm *DefaultPluginManager) recordPanic(id, where string, r interface{}) error {
+	log.Printf("Plugin %s panicked in %s: %v\n%s", id, where, r, debug.Stack())
+
+	limit := pm.policy(id).maxPanics()
+
+	pm.isolationMu.Lock()
+	now := time.Now()
+	recent := pm.panics[id][:0]
+	for _, t := range pm.panics[id] {
+		if now.Sub(t) < panicWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	pm.panics[id] = recent
+	quarantine := limit > 0 && len(recent) >= limit
+	if quarantine {
+		pm.quarantined[id] = QuarantineRecord{
+			Time:   now,
+			Reason: fmt.Sprintf("%d panics within %v, last in %s: %v", len(recent), panicWindow, where, r),
+		}
+		delete(pm.panics, id)
+	}
+	pm.isolationMu.Unlock()
+
+	if quarantine {
+		// 禁用插件但不修改记录的期望状态，重新启用插件即解除隔离
+		if err := pm.disablePlugin(id, true); err != nil && !errors.Is(err, ErrPluginDisabled) {
+			log.Printf("Failed to disable quarantined plugin %s: %v", id, err)
+		}
+		log.Printf("Plugin %s quarantined after %d panics", id, len(recent))
+	}
+
+	return fmt.Errorf("%w: %v", ErrPluginPanicked, r)
+}
+
+// Quarantined 获取插件被隔离的原因，未被隔离时返回false
+func (pm *DefaultPluginManager) Quarantined(id string) (QuarantineRecord, bool) {
+	pm.isolationMu.Lock()
+	defer pm.isolationMu.Unlock()
+	record, exists := pm.quarantined[id]
+	return record, exists
+}
+
+// release 解除插件的隔离并清空panic记录
+func (pm *DefaultPluginManager) release(id string) {
+	pm.isolationMu.Lock()
+	defer pm.isolationMu.Unlock()
+	if _, exists := pm.quarantined[id]; exists {
+		log.Printf("Plugin %s released from quarantine", id)
+	}
+	delete(pm.quarantined, id)
+	delete(pm.panics, id)
+}
+
+// nextPanic 插件拦截器调用next时内层发生的panic，向外传递时不计入该插件的panic次数
+type nextPanic struct {
+	value interface{}
+}
+
+// recoverInterceptor 将插件注册的拦截器中的panic转换为错误并计入该插件的panic次数，
+// 内层拦截器或命令中的panic原样向外传递
+func (pm *DefaultPluginManager) recoverInterceptor(entry interceptorEntry) Interceptor {
+	return func(ctx context.Context, inv *CommandInvocation, next CommandFunc) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if inner, ok := r.(nextPanic); ok {
+					panic(inner)
+				}
+				err = pm.recordPanic(entry.plugin, fmt.Sprintf("interceptor %s", entry.name), r)
+			}
+		}()
+		return entry.interceptor(ctx, inv, func(ctx context.Context, inv *CommandInvocation) error {
+			defer func() {
+				if r := recover(); r != nil {
+					if _, ok := r.(nextPanic); ok {
+						panic(r)
+					}
+					panic(nextPanic{value: r})
+				}
+			}()
+			return next(ctx, inv)
+		})
+	}
+}
+
+// interceptorActive 判断插件注册的拦截器是否生效，插件被隔离或禁用时跳过其拦截器
+func (pm *DefaultPluginManager) interceptorActive(id string) bool {
+	if _, quarantined := pm.Quarantined(id); quarantined {
+		return false
+	}
+	p, err := pm.GetPlugin(id)
+	return err == nil && p.State() != Disabled
+}
+
+// detachableWriter 命令被放弃后丢弃插件的输出
+type detachableWriter struct {
+	w        io.Writer
+	detached atomic.Bool
+}
+
+// Write 写入输出，被放弃后返回io.ErrClosedPipe
+func (d *detachableWriter) Write(p []byte) (int, error) {
+	if d.detached.Load() {
+		return 0, io.ErrClosedPipe
+	}
+	if d.w == nil {
+		return len(p), nil
+	}
+	return d.w.Write(p)
+}
+
+// detach 放弃命令，之后的写入被丢弃
+func (d *detachableWriter) detach() {
+	d.detached.Store(true)
+}
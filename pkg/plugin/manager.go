@@ -2,13 +2,19 @@ package plugin
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"plugin"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/tetratelabs/wazero"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,6 +25,8 @@ var (
 	ErrPluginDisabled      = errors.New("plugin is disabled")
 	ErrPluginEnabled       = errors.New("plugin is already enabled")
 	ErrInvalidPluginFile   = errors.New("invalid plugin file")
+	ErrPluginNoCommands    = errors.New("plugin does not provide commands")
+	ErrServiceNotStarted   = errors.New("service is not started")
 )
 
 // PluginManager 定义插件管理器接口
@@ -27,44 +35,175 @@ type PluginManager interface {
 	RegisterPlugin(p Plugin) error
 	// LoadPlugin 加载插件
 	LoadPlugin(path string) (Plugin, error)
-	// UnloadPlugin 卸载插件
-	UnloadPlugin(id string) error
+	// LoadStaticPlugin 加载静态编译的插件
+	LoadStaticPlugin(id string) (Plugin, error)
+	// UnloadPlugin 卸载插件，有其他插件依赖时需要force
+	UnloadPlugin(id string, force bool) error
 	// EnablePlugin 启用插件
 	EnablePlugin(id string) error
-	// DisablePlugin 禁用插件
-	DisablePlugin(id string) error
-	// UpgradePlugin 升级插件
-	UpgradePlugin(id string, path string) error
+	// DisablePlugin 禁用插件，有已启用的插件依赖时需要force
+	DisablePlugin(id string, force bool) error
+	// UnpackPlugin 校验插件包并解压到目录destDir，返回插件文件路径
+	UnpackPlugin(pkgPath, destDir string) (string, error)
+	// UpgradePlugin 升级插件，失败时恢复旧版本
+	UpgradePlugin(id string, path string, opts UpgradeOptions) error
+	// UpgradeHistory 获取升级历史，id为空时返回所有插件的记录
+	UpgradeHistory(id string) ([]UpgradeRecord, error)
 	// GetPlugin 获取插件
 	GetPlugin(id string) (Plugin, error)
+	// GetPluginMetadata 获取插件元数据
+	GetPluginMetadata(id string) (PluginMetadata, error)
+	// GetDependents 获取依赖指定插件的插件ID
+	GetDependents(id string) ([]string, error)
 	// ListPlugins 列出所有插件
 	ListPlugins() []Plugin
 	// GetServicePlugin 获取服务类插件
 	GetServicePlugin(id string) (IServicePlugin, error)
 	// GetCommandPlugin 获取命令类插件
 	GetCommandPlugin(id string) (ICommandPlugin, error)
+	// GetCommandHandler 获取插件的命令接口，不限插件类型
+	GetCommandHandler(id string) (CommandHandler, error)
+	// DescribeCommands 获取插件命令的参数、选项和输出等描述
+	DescribeCommands(id string) ([]CommandDescriptor, error)
+	// GetConfigSchema 获取插件发布的配置结构，插件未发布时返回false
+	GetConfigSchema(id string) (ConfigSchema, bool, error)
+	// ReconfigurePlugin 校验并保存插件配置，插件支持时立即应用，返回配置是否已经生效
+	ReconfigurePlugin(id string, config []byte) (bool, error)
+	// SetTrustConfig 设置插件签名校验配置
+	SetTrustConfig(cfg TrustConfig) error
+	// SetPolicies 设置插件运行策略（自动启动和重启策略）
+	SetPolicies(policies map[string]PluginPolicy) error
+	// AutostartServices 启动所有配置了autostart且没有记录期望状态的服务
+	AutostartServices() error
+	// RestoreStates 使已加载的插件进入上次记录的启用、禁用或运行状态
+	RestoreStates() error
+	// DesiredState 获取插件记录的期望状态，没有记录时返回false
+	DesiredState(id string) (string, bool)
+	// StartService 启动服务并纳入监管
+	StartService(id string) error
+	// StopService 停止服务并解除监管
+	StopService(id string) error
+	// RestartService 重启服务并纳入监管
+	RestartService(id string) error
+	// PauseService 暂停服务，不再接受新的连接但保留已建立的连接
+	PauseService(id string) error
+	// ResumeService 恢复暂停的服务
+	ResumeService(id string) error
+	// GetServiceStatus 获取服务的监管状态
+	GetServiceStatus(id string) (ServiceStatus, error)
+	// SetDataDir 设置插件数据目录的根目录
+	SetDataDir(dir string)
+	// Host 获取插件的Host，caller为执行命令的客户端，可以为nil
+	Host(id string, caller *Caller) (Host, error)
+	// Metrics 读取所有插件注册的指标
+	Metrics() []Metric
+	// Use 注册命令拦截器，先注册的拦截器在外层，同名拦截器会被替换
+	Use(name string, interceptor Interceptor) error
+	// Interceptors 返回已注册的拦截器名称，按执行顺序从外到内排列
+	Interceptors() []string
+	// ExecuteCommand 经过拦截器链执行插件命令
+	ExecuteCommand(ctx context.Context, inv *CommandInvocation) error
+	// HealthReport 返回已启用插件的健康报告
+	HealthReport() HealthReport
+	// Quarantined 获取插件因反复panic被隔离的原因，未被隔离时返回false，重新启用插件即解除隔离
+	Quarantined(id string) (QuarantineRecord, bool)
+	// RecordAudit 追加命令执行审计记录
+	RecordAudit(record AuditRecord)
+	// AuditLog 获取审计记录，id为空时返回所有记录，limit大于0时只返回最近的limit条
+	AuditLog(id string, limit int) ([]AuditRecord, error)
+	// Shutdown 停止所有服务并清理所有插件，结束插件进程
+	Shutdown() error
 }
 
 // DefaultPluginManager 默认插件管理器实现
 type DefaultPluginManager struct {
 	plugins    map[string]Plugin
+	metadata   map[string]PluginMetadata
 	pluginsDir string
 	configDir  string
+	dataDir    string
 	mu         sync.RWMutex
 	ctx        context.Context
 	cancelFunc context.CancelFunc
+
+	// 插件签名校验配置，由mu保护
+	trustedKeys   []ed25519.PublicKey
+	allowUnsigned bool
+
+	// 服务监管状态，由supMu保护
+	supMu    sync.Mutex
+	policies map[string]PluginPolicy
+	services map[string]*serviceRecord
+
+	// 插件期望状态，由stateMu保护
+	stateMu sync.Mutex
+	states  map[string]string
+
+	// 事件订阅，由eventsMu保护
+	eventsMu         sync.Mutex
+	subscriptions    map[uint64]*subscription
+	nextSubscription uint64
+
+	// 插件注册的指标，由metricsMu保护
+	metricsMu sync.Mutex
+	metrics   map[string]*metricEntry
+
+	// 审计记录文件的写入，由auditMu保护
+	auditMu sync.Mutex
+
+	// 命令拦截器，由interceptorsMu保护
+	interceptorsMu sync.RWMutex
+	interceptors   []interceptorEntry
+
+	// wasm模块的编译缓存，首次加载wasm插件时创建
+	wasmCacheOnce sync.Once
+	wasmCache     wazero.CompilationCache
+
+	// 插件最近一次健康检查的结果，由healthMu保护
+	healthMu sync.Mutex
+	health   map[string]healthRecord
+
+	// 命令panic记录和被隔离的插件，由isolationMu保护
+	isolationMu sync.Mutex
+	panics      map[string][]time.Time
+	quarantined map[string]QuarantineRecord
+
+	// 每个插件的升级锁，同一插件的升级依次执行，由upgradeMu保护
+	upgradeMu    sync.Mutex
+	upgradeLocks map[string]*sync.Mutex
 }
 
 // NewPluginManager 创建新的插件管理器
 func NewPluginManager(pluginsDir, configDir string) PluginManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &DefaultPluginManager{
+	pm := &DefaultPluginManager{
 		plugins:    make(map[string]Plugin),
+		metadata:   make(map[string]PluginMetadata),
 		pluginsDir: pluginsDir,
 		configDir:  configDir,
+		dataDir:    "data",
 		ctx:        ctx,
 		cancelFunc: cancel,
+		policies:   make(map[string]PluginPolicy),
+		services:   make(map[string]*serviceRecord),
+
+		subscriptions: make(map[uint64]*subscription),
+		metrics:       make(map[string]*metricEntry),
+		panics:        make(map[string][]time.Time),
+		quarantined:   make(map[string]QuarantineRecord),
+		health:        make(map[string]healthRecord),
+		upgradeLocks:  make(map[string]*sync.Mutex),
 	}
+
+	states, err := loadStates(filepath.Join(configDir, stateFile))
+	if err != nil {
+		log.Printf("Warning: failed to load plugin states, all plugins will be enabled: %v", err)
+	}
+	pm.states = states
+
+	go pm.supervise()
+	go pm.pollHealth()
+	return pm
 }
 
 // RegisterPlugin 注册内建插件
@@ -77,6 +216,55 @@ func (pm *DefaultPluginManager) RegisterPlugin(p Plugin) error {
 		return ErrPluginAlreadyExists
 	}
 
+	metadata := PluginMetadata{
+		ID:         p.ID(),
+		Name:       p.Name(),
+		Version:    p.Version(),
+		Type:       p.Type(),
+		APIVersion: APIVersion,
+		Static:     true,
+	}
+
+	return pm.initPlugin(p, metadata)
+}
+
+// LoadStaticPlugin 加载静态编译的插件，元数据来自插件注册时提供的信息
+func (pm *DefaultPluginManager) LoadStaticPlugin(id string) (Plugin, error) {
+	reg, exists := lookupRegistration(id)
+	if !exists {
+		return nil, ErrPluginNotFound
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	// 检查插件是否已存在
+	if _, exists := pm.plugins[id]; exists {
+		return nil, ErrPluginAlreadyExists
+	}
+
+	if err := pm.checkDependencies(reg.Metadata); err != nil {
+		return nil, err
+	}
+
+	p := reg.Factory()
+	if p.Type() != reg.Metadata.Type {
+		return nil, ErrPluginTypeMismatch
+	}
+
+	metadata := reg.Metadata
+	metadata.Static = true
+	metadata.APIVersion = APIVersion
+
+	if err := pm.initPlugin(p, metadata); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// initPlugin 读取插件配置、初始化插件并存储，调用方需持有写锁
+func (pm *DefaultPluginManager) initPlugin(p Plugin, metadata PluginMetadata) error {
 	// 读取插件配置
 	configPath := filepath.Join(pm.configDir, p.ID()+".yml")
 	var configBytes []byte
@@ -87,13 +275,15 @@ func (pm *DefaultPluginManager) RegisterPlugin(p Plugin) error {
 		}
 	}
 
-	// 初始化插件
-	if err := p.Init(pm.ctx, configBytes); err != nil {
+	// 初始化插件，通过上下文向插件提供Host，插件管理器只能通过声明了manage_plugins的Host.Manager获取
+	ctx := WithHost(pm.ctx, pm.newHost(metadata, nil))
+	if err := p.Init(ctx, configBytes); err != nil {
 		return fmt.Errorf("failed to initialize plugin: %w", err)
 	}
 
 	// 存储插件
 	pm.plugins[p.ID()] = p
+	pm.metadata[p.ID()] = metadata
 
 	return nil
 }
@@ -103,32 +293,86 @@ func (pm *DefaultPluginManager) LoadPlugin(path string) (Plugin, error) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	// 检查插件文件是否存在
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("plugin file not found: %s", path)
+	// 读取插件元数据
+	metadata, err := ReadPluginMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查插件是否已存在
+	if _, exists := pm.plugins[metadata.ID]; exists {
+		return nil, ErrPluginAlreadyExists
 	}
 
-	// 读取插件元数据
+	// 校验插件签名和文件校验和
+	if err := pm.verifyPlugin(path, metadata); err != nil {
+		return nil, err
+	}
+
+	// 打开插件前检查接口版本和服务器版本
+	if err := CheckCompatibility(metadata); err != nil {
+		return nil, err
+	}
+	if metadata.APIVersion == "" {
+		log.Printf("Warning: plugin %s does not declare api_version, compatibility with plugin API %s cannot be checked", metadata.ID, APIVersion)
+	}
+
+	// 检查依赖是否已加载且版本满足要求
+	if err := pm.checkDependencies(metadata); err != nil {
+		return nil, err
+	}
+
+	// 检查插件文件是否存在，配置了command的进程插件不需要插件文件
+	if metadata.Runtime != RuntimeProcess || metadata.Command == "" {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, fmt.Errorf("plugin file not found: %s", path)
+		}
+	}
+
+	var p Plugin
+	switch metadata.Runtime {
+	case "", RuntimeSharedObject:
+		p, err = openSharedObject(path, metadata)
+	case RuntimeProcess:
+		p, err = newProcessPlugin(pm.ctx, metadata, path)
+	case RuntimeWasm:
+		p, err = newWasmPlugin(metadata, path, pm.wasmCompilationCache(), func() WasmSandbox { return pm.policy(metadata.ID).Sandbox })
+	default:
+		err = fmt.Errorf("unknown plugin runtime: %s", metadata.Runtime)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pm.initPlugin(p, metadata); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ReadPluginMetadata 读取插件文件对应的元数据文件（<插件文件>.yml）
+func ReadPluginMetadata(path string) (PluginMetadata, error) {
 	metadataPath := filepath.Join(filepath.Dir(path), filepath.Base(path)+".yml")
 	metadataBytes, err := os.ReadFile(metadataPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read plugin metadata: %w", err)
+		return PluginMetadata{}, fmt.Errorf("failed to read plugin metadata: %w", err)
 	}
 
 	var metadata PluginMetadata
 	if err := yaml.Unmarshal(metadataBytes, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to parse plugin metadata: %w", err)
+		return PluginMetadata{}, fmt.Errorf("failed to parse plugin metadata: %w", err)
 	}
+	metadata.Path = path
 
-	// 检查插件是否已存在
-	if _, exists := pm.plugins[metadata.ID]; exists {
-		return nil, ErrPluginAlreadyExists
-	}
+	return metadata, nil
+}
 
-	// 加载插件
+// openSharedObject 打开.so插件并通过导出的工厂函数创建插件实例
+func openSharedObject(path string, metadata PluginMetadata) (Plugin, error) {
 	plug, err := plugin.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open plugin: %w", err)
+		return nil, explainOpenError(metadata, err)
 	}
 
 	// 获取插件工厂函数
@@ -157,7 +401,7 @@ func (pm *DefaultPluginManager) LoadPlugin(path string) (Plugin, error) {
 				return nil, ErrPluginTypeMismatch
 			}
 		} else {
-			return nil, ErrPluginTypeMismatch
+			return nil, factoryMismatch(factory)
 		}
 
 	case CommandPlugin:
@@ -181,38 +425,34 @@ func (pm *DefaultPluginManager) LoadPlugin(path string) (Plugin, error) {
 				return nil, ErrPluginTypeMismatch
 			}
 		} else {
-			return nil, ErrPluginTypeMismatch
+			return nil, factoryMismatch(factory)
 		}
 
 	default:
 		return nil, fmt.Errorf("unknown plugin type: %d", metadata.Type)
 	}
 
-	// 读取插件配置
-	configPath := filepath.Join(pm.configDir, metadata.ID+".yml")
-	var configBytes []byte
-	if _, err := os.Stat(configPath); err == nil {
-		configBytes, err = os.ReadFile(configPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read plugin config: %w", err)
-		}
-	}
-
-	// 初始化插件
-	// 创建上下文，并将插件管理器传递给插件
-	ctx := context.WithValue(pm.ctx, "plugin_manager", pm)
-	if err := p.Init(ctx, configBytes); err != nil {
-		return nil, fmt.Errorf("failed to initialize plugin: %w", err)
-	}
+	return p, nil
+}
 
-	// 存储插件
-	pm.plugins[p.ID()] = p
+// factoryMismatch 工厂函数签名与服务器的插件接口不一致，通常是插件基于其他版本的pkg/plugin编译
+func factoryMismatch(factory interface{}) error {
+	return fmt.Errorf("%w: factory function has type %T, the plugin was probably built against a different plugin API (server provides %s)",
+		ErrPluginTypeMismatch, factory, APIVersion)
+}
 
-	return p, nil
+// UnloadPlugin 卸载插件，有其他插件依赖时需要force。期望状态记录为禁用，
+// 插件文件仍在或为静态编译的插件时，重启服务器后不会被重新启用
+func (pm *DefaultPluginManager) UnloadPlugin(id string, force bool) error {
+	if err := pm.unloadPlugin(id, force); err != nil {
+		return err
+	}
+	pm.setDesiredState(id, DesiredDisabled)
+	return nil
 }
 
-// UnloadPlugin 卸载插件
-func (pm *DefaultPluginManager) UnloadPlugin(id string) error {
+// unloadPlugin 卸载插件，不修改记录的期望状态
+func (pm *DefaultPluginManager) unloadPlugin(id string, force bool) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -221,6 +461,13 @@ func (pm *DefaultPluginManager) UnloadPlugin(id string) error {
 		return ErrPluginNotFound
 	}
 
+	if dependents := pm.dependents(id, false); len(dependents) > 0 {
+		if !force {
+			return fmt.Errorf("%w: %s", ErrPluginHasDependents, strings.Join(dependents, ", "))
+		}
+		log.Printf("Warning: unloading plugin %s required by %s", id, strings.Join(dependents, ", "))
+	}
+
 	// 清理插件资源
 	if err := p.Cleanup(); err != nil {
 		return fmt.Errorf("failed to cleanup plugin: %w", err)
@@ -228,12 +475,29 @@ func (pm *DefaultPluginManager) UnloadPlugin(id string) error {
 
 	// 从管理器中移除插件
 	delete(pm.plugins, id)
+	delete(pm.metadata, id)
+	pm.unsupervise(id, true)
+	pm.unsubscribeAll(id)
+	pm.unregisterMetrics(id)
+	pm.removeInterceptors(id)
+	pm.release(id)
+	pm.forgetHealth(id)
 
 	return nil
 }
 
 // EnablePlugin 启用插件
 func (pm *DefaultPluginManager) EnablePlugin(id string) error {
+	if err := pm.enablePlugin(id); err != nil {
+		return err
+	}
+	pm.release(id)
+	pm.setDesiredState(id, DesiredEnabled)
+	return nil
+}
+
+// enablePlugin 启用插件，不修改记录的期望状态
+func (pm *DefaultPluginManager) enablePlugin(id string) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -246,11 +510,35 @@ func (pm *DefaultPluginManager) EnablePlugin(id string) error {
 		return ErrPluginEnabled
 	}
 
+	// 依赖的插件必须已启用
+	deps, err := pm.metadata[id].ParseDependencies()
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		depPlugin, exists := pm.plugins[dep.ID]
+		if !exists {
+			return fmt.Errorf("%w: requires %s", ErrDependencyMissing, dep)
+		}
+		if depPlugin.State() == Disabled {
+			return fmt.Errorf("%w: %s", ErrDependencyDisabled, dep.ID)
+		}
+	}
+
 	return p.SetState(Enabled)
 }
 
-// DisablePlugin 禁用插件
-func (pm *DefaultPluginManager) DisablePlugin(id string) error {
+// DisablePlugin 禁用插件，有已启用的插件依赖时需要force
+func (pm *DefaultPluginManager) DisablePlugin(id string, force bool) error {
+	if err := pm.disablePlugin(id, force); err != nil {
+		return err
+	}
+	pm.setDesiredState(id, DesiredDisabled)
+	return nil
+}
+
+// disablePlugin 禁用插件，不修改记录的期望状态
+func (pm *DefaultPluginManager) disablePlugin(id string, force bool) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -263,6 +551,14 @@ func (pm *DefaultPluginManager) DisablePlugin(id string) error {
 		return ErrPluginDisabled
 	}
 
+	if dependents := pm.dependents(id, true); len(dependents) > 0 {
+		if !force {
+			return fmt.Errorf("%w: %s", ErrPluginHasDependents, strings.Join(dependents, ", "))
+		}
+		log.Printf("Warning: disabling plugin %s required by %s", id, strings.Join(dependents, ", "))
+	}
+	pm.unsupervise(id, false)
+
 	// 如果是服务类插件且正在运行，先停止服务
 	if p.Type() == ServicePlugin {
 		if sp, ok := p.(IServicePlugin); ok && sp.State() == Running {
@@ -275,33 +571,104 @@ func (pm *DefaultPluginManager) DisablePlugin(id string) error {
 	return p.SetState(Disabled)
 }
 
-// UpgradePlugin 升级插件
-func (pm *DefaultPluginManager) UpgradePlugin(id string, path string) error {
-	// 先卸载旧插件
-	if err := pm.UnloadPlugin(id); err != nil {
-		return fmt.Errorf("failed to unload old plugin: %w", err)
+// GetPlugin 获取插件
+func (pm *DefaultPluginManager) GetPlugin(id string) (Plugin, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	p, exists := pm.plugins[id]
+	if !exists {
+		return nil, ErrPluginNotFound
+	}
+
+	return p, nil
+}
+
+// GetPluginMetadata 获取插件元数据
+func (pm *DefaultPluginManager) GetPluginMetadata(id string) (PluginMetadata, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	metadata, exists := pm.metadata[id]
+	if !exists {
+		return PluginMetadata{}, ErrPluginNotFound
 	}
 
-	// 加载新插件
-	_, err := pm.LoadPlugin(path)
+	return metadata, nil
+}
+
+// GetDependents 获取依赖指定插件的插件ID
+func (pm *DefaultPluginManager) GetDependents(id string) ([]string, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	if _, exists := pm.plugins[id]; !exists {
+		return nil, ErrPluginNotFound
+	}
+
+	return pm.dependents(id, false), nil
+}
+
+// checkDependencies 检查插件的依赖是否已加载且版本满足要求，调用方需持有锁
+func (pm *DefaultPluginManager) checkDependencies(metadata PluginMetadata) error {
+	deps, err := metadata.ParseDependencies()
 	if err != nil {
-		return fmt.Errorf("failed to load new plugin: %w", err)
+		return err
 	}
 
+	for _, dep := range deps {
+		depMeta, exists := pm.metadata[dep.ID]
+		if !exists {
+			return fmt.Errorf("%w: requires %s", ErrDependencyMissing, dep)
+		}
+		if err := dep.Check(depMeta.Version); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetPlugin 获取插件
-func (pm *DefaultPluginManager) GetPlugin(id string) (Plugin, error) {
+// checkDependents 检查插件的新版本是否满足依赖它的插件的版本约束
+func (pm *DefaultPluginManager) checkDependents(id, version string) error {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	p, exists := pm.plugins[id]
-	if !exists {
-		return nil, ErrPluginNotFound
+	for _, dependent := range pm.dependents(id, false) {
+		deps, err := pm.metadata[dependent].ParseDependencies()
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if dep.ID != id {
+				continue
+			}
+			if err := dep.Check(version); err != nil {
+				return fmt.Errorf("plugin %s: %w", dependent, err)
+			}
+		}
 	}
+	return nil
+}
 
-	return p, nil
+// dependents 返回依赖指定插件的插件ID，activeOnly时只包含未禁用的插件，调用方需持有锁
+func (pm *DefaultPluginManager) dependents(id string, activeOnly bool) []string {
+	var ids []string
+	for otherID, metadata := range pm.metadata {
+		if otherID == id {
+			continue
+		}
+		if activeOnly && pm.plugins[otherID].State() == Disabled {
+			continue
+		}
+		for _, s := range metadata.Dependencies {
+			if dep, err := ParseDependency(s); err == nil && dep.ID == id {
+				ids = append(ids, otherID)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
 }
 
 // ListPlugins 列出所有插件
@@ -360,3 +727,84 @@ func (pm *DefaultPluginManager) GetCommandPlugin(id string) (ICommandPlugin, err
 
 	return cp, nil
 }
+
+// GetCommandHandler 获取插件的命令接口，不限插件类型
+func (pm *DefaultPluginManager) GetCommandHandler(id string) (CommandHandler, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	p, exists := pm.plugins[id]
+	if !exists {
+		return nil, ErrPluginNotFound
+	}
+
+	handler, ok := p.(CommandHandler)
+	if !ok {
+		return nil, ErrPluginNoCommands
+	}
+
+	return handler, nil
+}
+
+// Shutdown 停止所有服务并清理所有插件，结束插件进程
+func (pm *DefaultPluginManager) Shutdown() error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	// 先解除监管，避免监管程序重启正在停止的服务
+	pm.supMu.Lock()
+	for _, rec := range pm.services {
+		rec.desired = false
+	}
+	pm.supMu.Unlock()
+
+	// 按依赖关系的逆序停止插件，依赖其他插件的插件先停止
+	ids := pm.shutdownOrder()
+
+	var errs []error
+	for _, id := range ids {
+		p := pm.plugins[id]
+		if sp, ok := p.(IServicePlugin); ok && p.Type() == ServicePlugin && (sp.State() == Running || sp.State() == Paused) {
+			if err := sp.Stop(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop plugin %s: %w", id, err))
+			}
+		}
+		if err := p.Cleanup(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to cleanup plugin %s: %w", id, err))
+		}
+		delete(pm.plugins, id)
+		delete(pm.metadata, id)
+	}
+
+	if pm.wasmCache != nil {
+		pm.wasmCache.Close(context.Background())
+	}
+
+	pm.cancelFunc()
+	return errors.Join(errs...)
+}
+
+// shutdownOrder 返回停止插件的顺序，为依赖关系排序的逆序，调用方需持有锁
+func (pm *DefaultPluginManager) shutdownOrder() []string {
+	metadata := make([]PluginMetadata, 0, len(pm.metadata))
+	for _, m := range pm.metadata {
+		metadata = append(metadata, m)
+	}
+	ordered, _ := SortByDependencies(metadata)
+
+	ids := make([]string, 0, len(pm.plugins))
+	seen := make(map[string]bool, len(pm.plugins))
+	for i := len(ordered) - 1; i >= 0; i-- {
+		ids = append(ids, ordered[i].ID)
+		seen[ordered[i].ID] = true
+	}
+	// 依赖无法解析的插件（如被强制卸载了依赖）最先停止
+	var rest []string
+	for id := range pm.plugins {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	return append(rest, ids...)
+}
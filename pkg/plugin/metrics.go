@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Metric 插件注册的指标
+type Metric struct {
+	Plugin string `json:"plugin"`
+	// Name 指标名称，格式为plugin_<插件ID>_<名称>
+	Name  string  `json:"name"`
+	Help  string  `json:"help,omitempty"`
+	Value float64 `json:"value"`
+}
+
+// metricEntry 已注册的指标
+type metricEntry struct {
+	plugin  string
+	name    string
+	help    string
+	collect func() float64
+}
+
+// registerMetric 注册插件的指标，同名指标会被替换
+func (pm *DefaultPluginManager) registerMetric(plugin, name, help string, collect func() float64) error {
+	if name == "" || collect == nil {
+		return errors.New("metric name and collector are required")
+	}
+
+	fullName := "plugin_" + metricName(plugin) + "_" + metricName(name)
+
+	pm.metricsMu.Lock()
+	defer pm.metricsMu.Unlock()
+	pm.metrics[fullName] = &metricEntry{plugin: plugin, name: fullName, help: help, collect: collect}
+	return nil
+}
+
+// unregisterMetrics 注销插件的所有指标，插件卸载时调用
+func (pm *DefaultPluginManager) unregisterMetrics(plugin string) {
+	pm.metricsMu.Lock()
+	defer pm.metricsMu.Unlock()
+
+	for name, m := range pm.metrics {
+		if m.plugin == plugin {
+			delete(pm.metrics, name)
+		}
+	}
+}
+
+// Metrics 读取所有插件注册的指标，按名称排序
+func (pm *DefaultPluginManager) Metrics() []Metric {
+	pm.metricsMu.Lock()
+	entries := make([]*metricEntry, 0, len(pm.metrics))
+	for _, m := range pm.metrics {
+		entries = append(entries, m)
+	}
+	pm.metricsMu.Unlock()
+
+	// 在锁外调用插件的collect，避免插件回调阻塞注册
+	metrics := make([]Metric, 0, len(entries))
+	for _, m := range entries {
+		metrics = append(metrics, Metric{Plugin: m.plugin, Name: m.name, Help: m.help, Value: m.collect()})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
+
+// metricName 将名称中指标名不允许的字符替换为下划线
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,631 @@
+package plugin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// packageEntry 插件包中的文件
+type packageEntry struct {
+	name string
+	data []byte
+}
+
+// testKey 生成测试用的签名密钥
+func testKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	key, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	return pub, key
+}
+
+// writeTestPlugin 在dir中写入插件文件和元数据文件，返回插件文件路径
+func writeTestPlugin(t *testing.T, dir, metadata string) string {
+	t.Helper()
+	path := filepath.Join(dir, "test.so")
+	if err := os.WriteFile(path, []byte("plugin binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".yml", []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readPackageEntries 读取插件包中的所有文件
+func readPackageEntries(t *testing.T, path string) []packageEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []packageEntry
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, packageEntry{name: hdr.Name, data: data})
+	}
+}
+
+// writePackageEntries 将文件写入插件包
+func writePackageEntries(t *testing.T, path string, entries []packageEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// modifyEntry 修改指定文件的内容
+func modifyEntry(name string, modify func([]byte) []byte) func([]packageEntry) []packageEntry {
+	return func(entries []packageEntry) []packageEntry {
+		for i := range entries {
+			if entries[i].name == name {
+				entries[i].data = modify(entries[i].data)
+			}
+		}
+		return entries
+	}
+}
+
+// removeEntry 删除指定文件
+func removeEntry(name string) func([]packageEntry) []packageEntry {
+	return func(entries []packageEntry) []packageEntry {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.name != name {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+}
+
+// addEntry 追加文件
+func addEntry(name string) func([]packageEntry) []packageEntry {
+	return func(entries []packageEntry) []packageEntry {
+		return append(entries, packageEntry{name: name, data: []byte("extra")})
+	}
+}
+
+func TestPackageRoundTrip(t *testing.T) {
+	signerPub, signerKey := testKey(t)
+	otherPub, _ := testKey(t)
+
+	tests := []struct {
+		name          string
+		trustedKeys   []string
+		allowUnsigned bool
+		modify        func([]packageEntry) []packageEntry
+		wantErr       error
+	}{
+		{
+			name:        "trusted signature",
+			trustedKeys: []string{signerPub},
+		},
+		{
+			name:        "untrusted key",
+			trustedKeys: []string{otherPub},
+			wantErr:     ErrUntrustedSignature,
+		},
+		{
+			name:          "untrusted key with allow_unsigned",
+			trustedKeys:   []string{otherPub},
+			allowUnsigned: true,
+		},
+		{
+			name:        "tampered plugin file",
+			trustedKeys: []string{signerPub},
+			modify:      modifyEntry("test.so", func([]byte) []byte { return []byte("malicious binary") }),
+			wantErr:     ErrPluginTampered,
+		},
+		{
+			name:        "tampered metadata",
+			trustedKeys: []string{signerPub},
+			modify: modifyEntry("test.so.yml", func(b []byte) []byte {
+				return append(b, []byte("capabilities: [manage_plugins]\n")...)
+			}),
+			wantErr: ErrPluginTampered,
+		},
+		{
+			name:          "tampered plugin file with allow_unsigned",
+			trustedKeys:   []string{signerPub},
+			allowUnsigned: true,
+			modify:        modifyEntry("test.so", func([]byte) []byte { return []byte("malicious binary") }),
+			wantErr:       ErrPluginTampered,
+		},
+		{
+			name:        "tampered manifest",
+			trustedKeys: []string{signerPub},
+			modify: modifyEntry(packageManifest, func(b []byte) []byte {
+				var m Manifest
+				json.Unmarshal(b, &m)
+				m.Version = "9.9.9"
+				out, _ := json.MarshalIndent(m, "", "  ")
+				return out
+			}),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:        "bad signature",
+			trustedKeys: []string{signerPub},
+			modify: modifyEntry(packageSignature, func(b []byte) []byte {
+				var sig Signature
+				json.Unmarshal(b, &sig)
+				raw, _ := base64.StdEncoding.DecodeString(sig.Signature)
+				raw[0] ^= 0xFF
+				sig.Signature = base64.StdEncoding.EncodeToString(raw)
+				out, _ := json.Marshal(sig)
+				return out
+			}),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:          "bad signature with allow_unsigned",
+			trustedKeys:   []string{signerPub},
+			allowUnsigned: true,
+			modify: modifyEntry(packageSignature, func(b []byte) []byte {
+				var sig Signature
+				json.Unmarshal(b, &sig)
+				sig.Key = otherPub
+				out, _ := json.Marshal(sig)
+				return out
+			}),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:        "missing signature",
+			trustedKeys: []string{signerPub},
+			modify:      removeEntry(packageSignature),
+			wantErr:     ErrUnsignedPlugin,
+		},
+		{
+			name:          "missing signature with allow_unsigned",
+			trustedKeys:   []string{signerPub},
+			allowUnsigned: true,
+			modify:        removeEntry(packageSignature),
+		},
+		{
+			name:        "missing manifest",
+			trustedKeys: []string{signerPub},
+			modify:      removeEntry(packageManifest),
+			wantErr:     ErrInvalidPackage,
+		},
+		{
+			name:        "parent directory entry",
+			trustedKeys: []string{signerPub},
+			modify:      addEntry("../evil"),
+			wantErr:     ErrInvalidPackage,
+		},
+		{
+			name:        "hidden entry",
+			trustedKeys: []string{signerPub},
+			modify:      addEntry(".hidden"),
+			wantErr:     ErrInvalidPackage,
+		},
+		{
+			name:        "nested entry",
+			trustedKeys: []string{signerPub},
+			modify:      addEntry("sub/file"),
+			wantErr:     ErrInvalidPackage,
+		},
+		{
+			name:        "absolute entry",
+			trustedKeys: []string{signerPub},
+			modify:      addEntry("/tmp/evil"),
+			wantErr:     ErrInvalidPackage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			path := writeTestPlugin(t, srcDir, "id: test\nname: Test\nversion: 1.0.0\n")
+
+			pkgPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			if err := BuildPackage(path, pkgPath, signerKey); err != nil {
+				t.Fatalf("BuildPackage: %v", err)
+			}
+			if tt.modify != nil {
+				writePackageEntries(t, pkgPath, tt.modify(readPackageEntries(t, pkgPath)))
+			}
+
+			pm := &DefaultPluginManager{}
+			if err := pm.SetTrustConfig(TrustConfig{TrustedKeys: tt.trustedKeys, AllowUnsigned: tt.allowUnsigned}); err != nil {
+				t.Fatalf("SetTrustConfig: %v", err)
+			}
+
+			parent := t.TempDir()
+			destDir := filepath.Join(parent, "plugins")
+			if err := os.Mkdir(destDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			installed, err := pm.UnpackPlugin(pkgPath, destDir)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("UnpackPlugin error = %v, want %v", err, tt.wantErr)
+				}
+				// 校验失败时不应安装任何文件
+				if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+					t.Errorf("files left in plugin directory: %v", entries)
+				}
+				if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+					t.Error("package entry was written outside the plugin directory")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnpackPlugin: %v", err)
+			}
+
+			metadata, err := ReadPluginMetadata(installed)
+			if err != nil {
+				t.Fatalf("ReadPluginMetadata: %v", err)
+			}
+			if err := pm.verifyPlugin(installed, metadata); err != nil {
+				t.Fatalf("verifyPlugin: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyInstalledPlugin(t *testing.T) {
+	signerPub, signerKey := testKey(t)
+
+	tests := []struct {
+		name          string
+		allowUnsigned bool
+		modify        func(t *testing.T, path string)
+		wantErr       error
+	}{
+		{
+			name: "unmodified",
+		},
+		{
+			name: "tampered plugin file",
+			modify: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("replaced"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrPluginTampered,
+		},
+		{
+			name: "tampered metadata",
+			modify: func(t *testing.T, path string) {
+				if err := os.WriteFile(path+".yml", []byte("id: test\nversion: 2.0.0\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrPluginTampered,
+		},
+		{
+			name: "missing manifest",
+			modify: func(t *testing.T, path string) {
+				os.Remove(path + manifestSuffix)
+			},
+			wantErr: ErrUnsignedPlugin,
+		},
+		{
+			name:          "missing manifest with allow_unsigned",
+			allowUnsigned: true,
+			modify: func(t *testing.T, path string) {
+				os.Remove(path + manifestSuffix)
+			},
+		},
+		{
+			name: "missing signature",
+			modify: func(t *testing.T, path string) {
+				os.Remove(path + signatureSuffix)
+			},
+			wantErr: ErrUnsignedPlugin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestPlugin(t, t.TempDir(), "id: test\nversion: 1.0.0\n")
+			pkgPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			if err := BuildPackage(path, pkgPath, signerKey); err != nil {
+				t.Fatalf("BuildPackage: %v", err)
+			}
+
+			pm := &DefaultPluginManager{}
+			if err := pm.SetTrustConfig(TrustConfig{TrustedKeys: []string{signerPub}, AllowUnsigned: tt.allowUnsigned}); err != nil {
+				t.Fatalf("SetTrustConfig: %v", err)
+			}
+			installed, err := pm.UnpackPlugin(pkgPath, t.TempDir())
+			if err != nil {
+				t.Fatalf("UnpackPlugin: %v", err)
+			}
+			metadata, err := ReadPluginMetadata(installed)
+			if err != nil {
+				t.Fatalf("ReadPluginMetadata: %v", err)
+			}
+
+			if tt.modify != nil {
+				tt.modify(t, installed)
+			}
+			if err := pm.verifyPlugin(installed, metadata); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyPlugin error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessPluginCommand(t *testing.T) {
+	signerPub, signerKey := testKey(t)
+
+	tests := []struct {
+		name          string
+		command       string
+		runCommand    string
+		allowUnsigned bool
+		signErr       error
+		verifyErr     error
+	}{
+		{
+			name:    "command in plugin directory",
+			command: "./server",
+		},
+		{
+			name:    "absolute command",
+			command: "/bin/sh",
+			signErr: ErrUnverifiedCommand,
+		},
+		{
+			name:    "command from PATH",
+			command: "sh",
+			signErr: ErrUnverifiedCommand,
+		},
+		{
+			name:    "command outside plugin directory",
+			command: "../server",
+			signErr: ErrUnverifiedCommand,
+		},
+		{
+			name:       "command changed to absolute path after signing",
+			command:    "./server",
+			runCommand: "/bin/sh",
+			verifyErr:  ErrUnverifiedCommand,
+		},
+		{
+			name:          "command changed to absolute path with allow_unsigned",
+			command:       "./server",
+			runCommand:    "/bin/sh",
+			allowUnsigned: true,
+		},
+		{
+			name:       "command changed to unsigned file",
+			command:    "./server",
+			runCommand: "./other",
+			verifyErr:  ErrUnverifiedCommand,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestPlugin(t, dir, "id: test\nversion: 1.0.0\nruntime: process\ncommand: "+tt.command+"\n")
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "server"), []byte("#!/bin/sh\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			pkgPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			err := BuildPackage(path, pkgPath, signerKey)
+			if !errors.Is(err, tt.signErr) {
+				t.Fatalf("BuildPackage error = %v, want %v", err, tt.signErr)
+			}
+			if err != nil {
+				return
+			}
+
+			pm := &DefaultPluginManager{}
+			if err := pm.SetTrustConfig(TrustConfig{TrustedKeys: []string{signerPub}, AllowUnsigned: tt.allowUnsigned}); err != nil {
+				t.Fatalf("SetTrustConfig: %v", err)
+			}
+			installed, err := pm.UnpackPlugin(pkgPath, t.TempDir())
+			if err != nil {
+				t.Fatalf("UnpackPlugin: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(installed), "server")); err != nil {
+				t.Fatalf("command file not installed: %v", err)
+			}
+
+			metadata, err := ReadPluginMetadata(installed)
+			if err != nil {
+				t.Fatalf("ReadPluginMetadata: %v", err)
+			}
+			if tt.runCommand != "" {
+				metadata.Command = tt.runCommand
+			}
+			if err := pm.verifyPlugin(installed, metadata); !errors.Is(err, tt.verifyErr) {
+				t.Fatalf("verifyPlugin error = %v, want %v", err, tt.verifyErr)
+			}
+		})
+	}
+}
+
+func TestUnpackPluginRefusesOverwrite(t *testing.T) {
+	signerPub, signerKey := testKey(t)
+
+	tests := []struct {
+		name      string
+		existing  string
+		installed string
+		wantErr   error
+	}{
+		{name: "empty directory"},
+		{name: "existing plugin file", existing: "test.so", wantErr: ErrPluginAlreadyExists},
+		{name: "existing metadata", existing: "test.so.yml", wantErr: ErrPluginAlreadyExists},
+		{name: "existing signature", existing: "test.so.sig", wantErr: ErrPluginAlreadyExists},
+		{name: "same id installed in directory", installed: "other.so", wantErr: ErrPluginAlreadyExists},
+		{name: "same id installed elsewhere", installed: filepath.Join("..", "elsewhere", "test.so")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestPlugin(t, t.TempDir(), "id: test\nname: Test\nversion: 2.0.0\n")
+			pkgPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			if err := BuildPackage(path, pkgPath, signerKey); err != nil {
+				t.Fatalf("BuildPackage: %v", err)
+			}
+
+			destDir := filepath.Join(t.TempDir(), "plugins")
+			if err := os.Mkdir(destDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			pm := &DefaultPluginManager{metadata: make(map[string]PluginMetadata)}
+			if err := pm.SetTrustConfig(TrustConfig{TrustedKeys: []string{signerPub}}); err != nil {
+				t.Fatalf("SetTrustConfig: %v", err)
+			}
+			if tt.existing != "" {
+				if err := os.WriteFile(filepath.Join(destDir, tt.existing), []byte("installed"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.installed != "" {
+				pm.metadata["test"] = PluginMetadata{ID: "test", Version: "1.0.0", Path: filepath.Join(destDir, tt.installed)}
+			}
+
+			installed, err := pm.UnpackPlugin(pkgPath, destDir)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("UnpackPlugin: %v", err)
+				}
+				if installed != filepath.Join(destDir, "test.so") {
+					t.Errorf("installed = %s, want test.so in %s", installed, destDir)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnpackPlugin error = %v, want %v", err, tt.wantErr)
+			}
+
+			// 拒绝时目标目录保持原样
+			entries, _ := os.ReadDir(destDir)
+			want := 0
+			if tt.existing != "" {
+				want = 1
+			}
+			if len(entries) != want {
+				t.Errorf("plugin directory changed: %v", entries)
+			}
+			if tt.existing != "" {
+				data, err := os.ReadFile(filepath.Join(destDir, tt.existing))
+				if err != nil || string(data) != "installed" {
+					t.Errorf("existing file %s was overwritten", tt.existing)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPackageLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		data    []byte
+		wantErr bool
+	}{
+		{name: "within limit", size: 5, data: []byte("hello")},
+		{name: "entry over limit", size: maxPackageFileSize + 1, wantErr: true},
+		{name: "truncated entry", size: 100, data: []byte("short"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 只写入文件头和部分数据，不关闭tar，模拟声明大小与内容不符的插件包
+			pkgPath := filepath.Join(t.TempDir(), "test.tar.gz")
+			f, err := os.Create(pkgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gw := gzip.NewWriter(f)
+			tw := tar.NewWriter(gw)
+			if err := tw.WriteHeader(&tar.Header{Name: "test.so", Mode: 0644, Size: tt.size, Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(tt.data)) == tt.size {
+				if err := tw.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := gw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			dir := t.TempDir()
+			err = extractPackage(pkgPath, dir)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("extractPackage: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPackage) {
+				t.Fatalf("extractPackage error = %v, want %v", err, ErrInvalidPackage)
+			}
+			if tt.size > maxPackageFileSize {
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("oversized entry was written: %v", entries)
+				}
+			}
+		})
+	}
+}
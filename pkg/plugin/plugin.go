@@ -35,7 +35,7 @@ const (
 	Enabled
 	// Running 运行中状态（仅适用于服务类插件）
 	Running
-	// Paused 暂停状态（仅适用于服务类插件）
+	// Paused 暂停状态（仅适用于服务类插件），服务保留资源和已建立的连接但不接受新的连接
 	Paused
 )
 
@@ -68,21 +68,26 @@ type IServicePlugin interface {
 	Stop() error
 	// Restart 重启服务
 	Restart(ctx context.Context) error
-	// Pause 暂停服务
+	// Pause 暂停服务，不再接受新的连接但保留已建立的连接
 	Pause() error
-	// Resume 恢复服务
+	// Resume 恢复暂停的服务
 	Resume() error
 }
 
+// CommandHandler 定义命令接口，任何类型的插件都可以实现该接口对外提供命令
+type CommandHandler interface {
+	// Execute 执行命令
+	Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error
+	// GetCommands 获取支持的命令列表
+	GetCommands() []string
+}
+
 // ICommandPlugin 定义命令类插件接口
 type ICommandPlugin interface {
 	Plugin
+	CommandHandler
 	// CommandType 返回命令类型
 	CommandType() CommandType
-	// Execute 执行命令
-	Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error
-	// GetCommands 获取支持的命令列表
-	GetCommands() []string
 }
 
 // PluginMetadata 定义插件元数据
@@ -94,6 +99,24 @@ type PluginMetadata struct {
 	Description  string     `yaml:"description"`
 	Author       string     `yaml:"author"`
 	Dependencies []string   `yaml:"dependencies,omitempty"`
+	// Capabilities 插件需要的额外能力，如manage_plugins
+	Capabilities []string `yaml:"capabilities,omitempty"`
+	// APIVersion 插件编译时使用的插件接口版本（plugin.APIVersion）
+	APIVersion string `yaml:"api_version,omitempty"`
+	// MinServerVersion 插件要求的最低服务器版本
+	MinServerVersion string `yaml:"min_server_version,omitempty"`
+	// Runtime 运行方式：so（默认）、process或wasm
+	Runtime string `yaml:"runtime,omitempty"`
+	// Command 插件进程的可执行文件，默认为插件文件本身，含路径的相对路径基于插件文件所在目录
+	Command string `yaml:"command,omitempty"`
+	// Args 插件进程的命令行参数
+	Args []string `yaml:"args,omitempty"`
+	// Transport 插件进程的通信方式：stdio（默认）或unix
+	Transport string `yaml:"transport,omitempty"`
+	// Static 是否为静态编译到程序中的插件
+	Static bool `yaml:"-"`
+	// Path 插件文件路径，静态编译的插件为空
+	Path string `yaml:"-"`
 }
 
 // PluginFactory 定义插件工厂函数类型
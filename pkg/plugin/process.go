@@ -0,0 +1,737 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 插件运行方式
+const (
+	// RuntimeSharedObject 以.so动态库方式加载到服务器进程中（默认）
+	RuntimeSharedObject = "so"
+	// RuntimeProcess 以子进程方式运行
+	RuntimeProcess = "process"
+)
+
+// 插件进程的通信方式
+const (
+	// TransportStdio 通过子进程的标准输入输出通信（默认）
+	TransportStdio = "stdio"
+	// TransportUnix 通过unix socket通信，子进程的标准输出记录到日志
+	TransportUnix = "unix"
+)
+
+const (
+	// processCallTimeout 插件进程生命周期调用的超时时间
+	processCallTimeout = 30 * time.Second
+	// processStopTimeout 插件进程清理后等待退出的时间，超时后强制结束
+	processStopTimeout = 5 * time.Second
+	// processConnectTimeout 使用unix socket时等待插件进程连接的时间
+	processConnectTimeout = 10 * time.Second
+	// processMaxBackoff 插件进程崩溃后重启的最大间隔
+	processMaxBackoff = 30 * time.Second
+	// processStableTime 插件进程运行超过该时间后崩溃，重启间隔从头计算
+	processStableTime = time.Minute
+)
+
+// pluginProcess 一个插件子进程及其通信通道
+type pluginProcess struct {
+	cmd     *exec.Cmd
+	conn    *rpcConn
+	started time.Time
+	exited  chan struct{}
+
+	mu      sync.Mutex
+	outputs map[uint64]*streamBuffer
+}
+
+// processPlugin 以子进程方式运行的插件，进程崩溃后自动重启并恢复状态
+type processPlugin struct {
+	metadata PluginMetadata
+	command  string
+	ctx      context.Context
+
+	mu      sync.Mutex
+	desc    describeResult
+	state   PluginState
+	config  []byte
+	dataDir string
+	proc    *pluginProcess
+	closed  bool
+}
+
+// processServicePlugin 以子进程方式运行的服务类插件
+type processServicePlugin struct {
+	*processPlugin
+}
+
+// processCommandPlugin 以子进程方式运行的命令类插件
+type processCommandPlugin struct {
+	*processPlugin
+}
+
+// newProcessPlugin 创建子进程插件，path为插件文件路径，未配置command时作为可执行文件
+func newProcessPlugin(ctx context.Context, metadata PluginMetadata, path string) (Plugin, error) {
+	command := path
+	if metadata.Command != "" {
+		command = metadata.Command
+		// 含路径分隔符的相对路径基于插件文件所在目录，否则从PATH中查找
+		if !filepath.IsAbs(command) && strings.ContainsRune(command, filepath.Separator) {
+			command = filepath.Join(filepath.Dir(path), command)
+		}
+	}
+
+	switch metadata.Transport {
+	case "", TransportStdio, TransportUnix:
+	default:
+		return nil, fmt.Errorf("unknown plugin transport: %s", metadata.Transport)
+	}
+
+	p := &processPlugin{
+		metadata: metadata,
+		command:  command,
+		ctx:      ctx,
+		state:    Disabled,
+	}
+
+	switch metadata.Type {
+	case ServicePlugin:
+		return &processServicePlugin{p}, nil
+	case CommandPlugin:
+		return &processCommandPlugin{p}, nil
+	default:
+		return nil, fmt.Errorf("unknown plugin type: %d", metadata.Type)
+	}
+}
+
+// ID 返回插件唯一标识
+func (p *processPlugin) ID() string {
+	return p.metadata.ID
+}
+
+// Name 返回插件名称
+func (p *processPlugin) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.Name != "" {
+		return p.desc.Name
+	}
+	return p.metadata.Name
+}
+
+// Version 返回插件版本
+func (p *processPlugin) Version() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.Version != "" {
+		return p.desc.Version
+	}
+	return p.metadata.Version
+}
+
+// Type 返回插件类型
+func (p *processPlugin) Type() PluginType {
+	return p.metadata.Type
+}
+
+// State 返回插件当前状态
+func (p *processPlugin) State() PluginState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// SetState 设置插件状态，插件进程重启期间只记录状态，重启后恢复
+func (p *processPlugin) SetState(state PluginState) error {
+	proc := p.current()
+	if proc != nil {
+		var result stateParams
+		if err := p.call(proc, rpcSetState, stateParams{State: state}, &result); err != nil {
+			return err
+		}
+		state = result.State
+	}
+
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+	return nil
+}
+
+// Init 启动插件进程并初始化插件
+func (p *processPlugin) Init(ctx context.Context, config []byte) error {
+	p.mu.Lock()
+	p.config = config
+	if host, ok := HostFromContext(ctx); ok {
+		p.dataDir = host.DataDir()
+	}
+	p.mu.Unlock()
+
+	proc, err := p.launch()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.proc = proc
+	p.mu.Unlock()
+
+	go p.monitor(proc)
+	return nil
+}
+
+// Cleanup 清理插件并结束插件进程
+func (p *processPlugin) Cleanup() error {
+	p.mu.Lock()
+	p.closed = true
+	proc := p.proc
+	p.proc = nil
+	p.mu.Unlock()
+
+	if proc == nil {
+		return nil
+	}
+
+	err := p.call(proc, rpcCleanup, nil, nil)
+	proc.stop()
+	return err
+}
+
+// ConfigSchema 返回插件进程发布的配置结构
+func (p *processPlugin) ConfigSchema() ConfigSchema {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.ConfigSchema == nil {
+		return ConfigSchema{}
+	}
+	return *p.desc.ConfigSchema
+}
+
+// DescribeCommands 返回插件进程提供的命令描述
+func (p *processPlugin) DescribeCommands() []CommandDescriptor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.Descriptors
+}
+
+// Reconfigure 在插件进程中应用新配置，成功后插件进程重启时也使用新配置
+func (p *processPlugin) Reconfigure(ctx context.Context, config []byte) error {
+	p.mu.Lock()
+	reconfigurable := p.desc.Reconfigurable
+	p.mu.Unlock()
+
+	if !reconfigurable {
+		return ErrReconfigureNotSupported
+	}
+	proc := p.current()
+	if proc == nil {
+		return ErrPluginProcessExited
+	}
+	if err := p.call(proc, rpcReconfig, initParams{Config: config}, nil); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.config = config
+	p.mu.Unlock()
+	return nil
+}
+
+// CheckHealth 查询插件进程的健康状态，插件进程未运行时为unhealthy，插件未实现HealthChecker时返回空结果
+func (p *processPlugin) CheckHealth(ctx context.Context) Health {
+	proc := p.current()
+	if proc == nil {
+		return Health{Status: HealthUnhealthy, Message: "plugin process is not running"}
+	}
+
+	p.mu.Lock()
+	supported := p.desc.HealthCheck
+	p.mu.Unlock()
+	if !supported {
+		return Health{}
+	}
+
+	var health Health
+	if err := proc.conn.call(ctx, rpcHealth, nil, &health); err != nil {
+		return Health{Status: HealthUnhealthy, Message: err.Error()}
+	}
+	return health
+}
+
+// Start 启动服务
+func (p *processServicePlugin) Start(ctx context.Context) error {
+	return p.lifecycle(rpcStart)
+}
+
+// Stop 停止服务
+func (p *processServicePlugin) Stop() error {
+	return p.lifecycle(rpcStop)
+}
+
+// Restart 重启服务
+func (p *processServicePlugin) Restart(ctx context.Context) error {
+	return p.lifecycle(rpcRestart)
+}
+
+// Pause 暂停服务
+func (p *processServicePlugin) Pause() error {
+	return p.lifecycle(rpcPause)
+}
+
+// Resume 恢复服务
+func (p *processServicePlugin) Resume() error {
+	return p.lifecycle(rpcResume)
+}
+
+// Failed 查询插件进程中服务的运行故障。插件进程重启期间由进程监控负责恢复，返回nil
+func (p *processServicePlugin) Failed() error {
+	proc := p.current()
+	if proc == nil {
+		return nil
+	}
+
+	var result failedResult
+	if err := p.call(proc, rpcFailed, nil, &result); err != nil {
+		return err
+	}
+	if result.Error != "" {
+		return errors.New(result.Error)
+	}
+	return nil
+}
+
+// CommandType 返回命令类型
+func (p *processCommandPlugin) CommandType() CommandType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.CommandType
+}
+
+// GetCommands 获取支持的命令列表
+func (p *processPlugin) GetCommands() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.Commands
+}
+
+// Execute 在插件进程中执行命令，输入和输出以数据块的方式在通道上传输
+func (p *processPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
+	proc := p.current()
+	if proc == nil {
+		return fmt.Errorf("%w: %s", ErrPluginProcessExited, p.ID())
+	}
+
+	// 输出先放入缓冲，由单独的协程写给调用方，客户端读取缓慢时不会阻塞通道的读取协程。
+	// 积压的输出超过上限时取消命令
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	id := proc.conn.newID()
+	buf := newStreamBuffer(maxStreamPending)
+	proc.setOutput(id, buf)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		if _, err := io.Copy(output, buf); err != nil {
+			buf.CloseWithError(err)
+			if errors.Is(err, ErrStreamOverflow) {
+				cancel(err)
+			}
+		}
+	}()
+	defer func() {
+		proc.setOutput(id, nil)
+		buf.Close()
+		// 等待已收到的输出写完，命令被取消时不再等待
+		select {
+		case <-written:
+		case <-ctx.Done():
+		}
+	}()
+
+	params := executeParams{Args: args}
+	if host, ok := HostFromContext(ctx); ok {
+		if caller, ok := host.Caller(); ok {
+			params.Caller = &caller
+		}
+	}
+	ch, err := proc.conn.send(id, rpcExecute, params)
+	if err != nil {
+		return err
+	}
+
+	// 请求发出后再发送输入，保证插件进程已登记该命令
+	go forwardInput(proc.conn, id, input)
+
+	err = proc.conn.wait(ctx, id, ch, nil)
+	if ctx.Err() != nil {
+		proc.conn.notify(rpcCancel, streamParams{Call: id})
+		if cause := context.Cause(ctx); errors.Is(cause, ErrStreamOverflow) {
+			return fmt.Errorf("plugin %s: %w", p.ID(), cause)
+		}
+	}
+	return err
+}
+
+// lifecycle 调用服务生命周期方法并同步插件进程返回的状态
+func (p *processPlugin) lifecycle(method string) error {
+	proc := p.current()
+	if proc == nil {
+		return fmt.Errorf("%w: %s", ErrPluginProcessExited, p.ID())
+	}
+
+	var result stateParams
+	if err := p.call(proc, method, nil, &result); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.state = result.State
+	p.mu.Unlock()
+	return nil
+}
+
+// current 返回当前运行的插件进程，重启期间返回nil
+func (p *processPlugin) current() *pluginProcess {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proc
+}
+
+// call 调用插件进程，使用生命周期调用的超时时间
+func (p *processPlugin) call(proc *pluginProcess, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(p.ctx, processCallTimeout)
+	defer cancel()
+	return proc.conn.call(ctx, method, params, result)
+}
+
+// launch 启动插件进程，校验插件描述并初始化
+func (p *processPlugin) launch() (*pluginProcess, error) {
+	proc, err := p.spawn()
+	if err != nil {
+		return nil, err
+	}
+
+	var desc describeResult
+	if err := p.call(proc, rpcDescribe, nil, &desc); err != nil {
+		proc.stop()
+		return nil, fmt.Errorf("failed to describe plugin: %w", err)
+	}
+	if desc.ID != p.metadata.ID {
+		proc.stop()
+		return nil, fmt.Errorf("plugin process reported id %q, expected %q", desc.ID, p.metadata.ID)
+	}
+	if desc.Type != p.metadata.Type {
+		proc.stop()
+		return nil, ErrPluginTypeMismatch
+	}
+
+	p.mu.Lock()
+	params := initParams{Config: p.config, DataDir: p.dataDir}
+	p.mu.Unlock()
+
+	if err := p.call(proc, rpcInit, params, nil); err != nil {
+		proc.stop()
+		return nil, fmt.Errorf("failed to initialize plugin: %w", err)
+	}
+
+	p.mu.Lock()
+	p.desc = desc
+	p.mu.Unlock()
+
+	return proc, nil
+}
+
+// spawn 启动插件进程并建立通信通道
+func (p *processPlugin) spawn() (*pluginProcess, error) {
+	cmd := exec.Command(p.command, p.metadata.Args...)
+	cmd.Env = os.Environ()
+	cmd.Stderr = newLogWriter(p.metadata.ID)
+
+	proc := &pluginProcess{
+		cmd:     cmd,
+		exited:  make(chan struct{}),
+		outputs: make(map[uint64]*streamBuffer),
+	}
+
+	var rw io.ReadWriteCloser
+	if p.metadata.Transport == TransportUnix {
+		conn, err := p.spawnUnix(cmd)
+		if err != nil {
+			return nil, err
+		}
+		rw = conn
+	} else {
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start plugin process: %w", err)
+		}
+		rw = pipeConn{r: stdout, w: stdin}
+	}
+
+	proc.started = time.Now()
+	proc.conn = newRPCConn(rw, proc.handle)
+
+	// 通道关闭后回收进程
+	go func() {
+		<-proc.conn.done
+		cmd.Wait()
+		close(proc.exited)
+	}()
+
+	log.Printf("Plugin %s started as process %d", p.metadata.ID, cmd.Process.Pid)
+	return proc, nil
+}
+
+// spawnUnix 通过unix socket启动插件进程，等待插件进程连接
+func (p *processPlugin) spawnUnix(cmd *exec.Cmd) (net.Conn, error) {
+	dir, err := os.MkdirTemp("", "sgo-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "plugin.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on plugin socket: %w", err)
+	}
+	defer ln.Close()
+
+	cmd.Env = append(cmd.Env, PluginSocketEnv+"="+socketPath)
+	cmd.Stdout = cmd.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin process: %w", err)
+	}
+
+	ln.(*net.UnixListener).SetDeadline(time.Now().Add(processConnectTimeout))
+	conn, err := ln.Accept()
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("plugin process did not connect: %w", err)
+	}
+
+	return conn, nil
+}
+
+// monitor 等待插件进程退出，非正常退出时按退避间隔重启
+func (p *processPlugin) monitor(proc *pluginProcess) {
+	backoff := time.Second
+	for {
+		<-proc.exited
+
+		p.mu.Lock()
+		if p.closed || p.proc != proc {
+			p.mu.Unlock()
+			return
+		}
+		p.proc = nil
+		p.mu.Unlock()
+
+		log.Printf("Plugin %s process exited unexpectedly: %v", p.metadata.ID, proc.cmd.ProcessState)
+		if time.Since(proc.started) > processStableTime {
+			backoff = time.Second
+		}
+
+		for {
+			log.Printf("Restarting plugin %s in %s", p.metadata.ID, backoff)
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > processMaxBackoff {
+				backoff = processMaxBackoff
+			}
+
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return
+			}
+
+			next, err := p.launch()
+			if err != nil {
+				log.Printf("Failed to restart plugin %s: %v", p.metadata.ID, err)
+				continue
+			}
+
+			if err := p.restore(next); err != nil {
+				log.Printf("Failed to restore plugin %s state: %v", p.metadata.ID, err)
+			}
+
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				next.stop()
+				return
+			}
+			p.proc = next
+			p.mu.Unlock()
+
+			log.Printf("Plugin %s restarted", p.metadata.ID)
+			proc = next
+			break
+		}
+	}
+}
+
+// restore 将重启后的插件进程恢复到崩溃前的状态
+func (p *processPlugin) restore(proc *pluginProcess) error {
+	p.mu.Lock()
+	state := p.state
+	p.mu.Unlock()
+
+	if state == Disabled {
+		return nil
+	}
+	if err := p.call(proc, rpcSetState, stateParams{State: Enabled}, nil); err != nil {
+		return err
+	}
+	if state == Running || state == Paused {
+		if err := p.call(proc, rpcStart, nil, nil); err != nil {
+			p.mu.Lock()
+			p.state = Enabled
+			p.mu.Unlock()
+			return err
+		}
+	}
+	if state == Paused {
+		return p.call(proc, rpcPause, nil, nil)
+	}
+	return nil
+}
+
+// handle 处理插件进程的通知，输出只写入命令的缓冲，不阻塞读取协程
+func (proc *pluginProcess) handle(msg *rpcMessage) {
+	if msg.Method != rpcOutput {
+		return
+	}
+
+	var params streamParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+
+	proc.mu.Lock()
+	output := proc.outputs[params.Call]
+	proc.mu.Unlock()
+
+	if output != nil {
+		output.Write(params.Data)
+	}
+}
+
+// setOutput 登记命令的输出缓冲，output为nil时移除
+func (proc *pluginProcess) setOutput(id uint64, output *streamBuffer) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	if output == nil {
+		delete(proc.outputs, id)
+	} else {
+		proc.outputs[id] = output
+	}
+}
+
+// stop 关闭通道并等待插件进程退出，超时后强制结束
+func (proc *pluginProcess) stop() {
+	proc.conn.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(processStopTimeout):
+		proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+}
+
+// forwardInput 将命令输入发送给插件进程
+func forwardInput(conn *rpcConn, id uint64, input io.Reader) {
+	if input != nil {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := input.Read(buf)
+			if n > 0 {
+				if err := conn.notify(rpcInput, streamParams{Call: id, Data: buf[:n]}); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	conn.notify(rpcInput, streamParams{Call: id, EOF: true})
+}
+
+// pipeConn 将一对读写端组合为双向连接
+type pipeConn struct {
+	r io.ReadCloser
+	w io.WriteCloser
+}
+
+// Read 从读取端读取
+func (c pipeConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Write 写入写入端
+func (c pipeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// Close 关闭读写端
+func (c pipeConn) Close() error {
+	c.w.Close()
+	return c.r.Close()
+}
+
+// logWriter 将插件进程的输出按行记录到日志
+type logWriter struct {
+	id  string
+	mu  sync.Mutex
+	buf []byte
+}
+
+// newLogWriter 创建插件进程日志输出
+func newLogWriter(id string) *logWriter {
+	return &logWriter{id: id}
+}
+
+// Write 按行记录日志，不完整的行缓存到下次写入
+func (w *logWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, data...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		log.Printf("[plugin %s] %s", w.id, w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+	return len(data), nil
+}
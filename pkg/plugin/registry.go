@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// Registration 静态编译到程序中的插件
+type Registration struct {
+	Metadata PluginMetadata
+	Factory  PluginFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register 注册静态编译的插件，在插件包的init()中调用。
+// 插件包同时被编译为.so时，加载.so也会执行init()，因此重复注册只记录日志并保留先注册的插件
+func Register(metadata PluginMetadata, factory PluginFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[metadata.ID]; exists {
+		log.Printf("Plugin %s already registered, ignoring duplicate registration", metadata.ID)
+		return
+	}
+
+	registry[metadata.ID] = Registration{
+		Metadata: metadata,
+		Factory:  factory,
+	}
+}
+
+// Registered 返回所有静态注册的插件，按ID排序
+func Registered() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(registry))
+	for _, reg := range registry {
+		regs = append(regs, reg)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Metadata.ID < regs[j].Metadata.ID
+	})
+	return regs
+}
+
+// lookupRegistration 查找静态注册的插件
+func lookupRegistration(id string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, exists := registry[id]
+	return reg, exists
+}
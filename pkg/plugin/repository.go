@@ -0,0 +1,275 @@
+package plugin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 插件仓库是一个目录或HTTP地址，根目录下的索引文件列出每个插件的可用版本、插件包位置、校验和及兼容的插件接口版本。
+// 索引可以用pluginpack index根据目录中的插件包生成
+const (
+	// RepositoryIndexFile 插件仓库的索引文件名
+	RepositoryIndexFile = "index.json"
+
+	// maxMetadataSize 从插件包读取的清单和元数据文件大小上限
+	maxMetadataSize = 1 << 20
+)
+
+var (
+	ErrNotInRepository     = errors.New("plugin not found in repository")
+	ErrNoCompatibleVersion = errors.New("no compatible version in repository")
+)
+
+// RepositoryIndex 插件仓库索引
+type RepositoryIndex struct {
+	Plugins []RepositoryPlugin `json:"plugins"`
+}
+
+// RepositoryPlugin 插件仓库中的插件
+type RepositoryPlugin struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Versions 可用版本，按版本号从高到低排列
+	Versions []RepositoryRelease `json:"versions"`
+}
+
+// RepositoryRelease 插件仓库中插件的一个版本
+type RepositoryRelease struct {
+	Version string `json:"version"`
+	// Package 插件包位置，相对于索引文件或完整URL
+	Package string `json:"package"`
+	// SHA256 插件包的SHA-256校验和（十六进制）
+	SHA256 string `json:"sha256"`
+	// Size 插件包大小（字节）
+	Size int64 `json:"size"`
+	// APIVersion 插件编译时使用的插件接口版本
+	APIVersion string `json:"api_version,omitempty"`
+	// MinServerVersion 插件要求的最低服务器版本
+	MinServerVersion string `json:"min_server_version,omitempty"`
+}
+
+// Find 查找插件
+func (idx RepositoryIndex) Find(id string) (RepositoryPlugin, error) {
+	for _, p := range idx.Plugins {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return RepositoryPlugin{}, fmt.Errorf("%w: %s", ErrNotInRepository, id)
+}
+
+// CheckCompatibility 检查该版本是否兼容当前服务器
+func (r RepositoryRelease) CheckCompatibility(id string) error {
+	return CheckCompatibility(PluginMetadata{ID: id, APIVersion: r.APIVersion, MinServerVersion: r.MinServerVersion})
+}
+
+// Resolve 选择兼容当前服务器的版本：spec为空或latest时选择最新的正式版本，为版本号时精确匹配，否则作为版本约束选择满足约束的最新版本
+func (rp RepositoryPlugin) Resolve(spec string) (RepositoryRelease, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "latest" {
+		spec = ""
+	}
+
+	// 精确版本号
+	if spec != "" && strings.Trim(spec[:1], "<>=!^~") != "" {
+		if want, err := ParseVersion(spec); err == nil {
+			for _, r := range rp.Versions {
+				if v, err := ParseVersion(r.Version); err == nil && v.Compare(want) == 0 {
+					if err := r.CheckCompatibility(rp.ID); err != nil {
+						return RepositoryRelease{}, err
+					}
+					return r, nil
+				}
+			}
+			return RepositoryRelease{}, fmt.Errorf("%w: %s@%s", ErrNotInRepository, rp.ID, spec)
+		}
+	}
+
+	constraint, err := ParseConstraint(spec)
+	if err != nil {
+		return RepositoryRelease{}, err
+	}
+
+	var best RepositoryRelease
+	var bestVersion Version
+	var reason error
+	found := false
+	for _, r := range rp.Versions {
+		v, err := ParseVersion(r.Version)
+		if err != nil || !constraint.Check(v) || (spec == "" && v.Prerelease != "") {
+			continue
+		}
+		if err := r.CheckCompatibility(rp.ID); err != nil {
+			if reason == nil {
+				reason = err
+			}
+			continue
+		}
+		if !found || v.Compare(bestVersion) > 0 {
+			best, bestVersion, found = r, v, true
+		}
+	}
+
+	switch {
+	case found:
+		return best, nil
+	case reason != nil:
+		return RepositoryRelease{}, fmt.Errorf("%w: %s %s: %v", ErrNoCompatibleVersion, rp.ID, constraint, reason)
+	default:
+		return RepositoryRelease{}, fmt.Errorf("%w: %s %s", ErrNotInRepository, rp.ID, constraint)
+	}
+}
+
+// ReadPackageMetadata 读取插件包中的插件元数据，不校验签名
+func ReadPackageMetadata(pkgPath string) (PluginMetadata, error) {
+	f, err := os.Open(pkgPath)
+	if err != nil {
+		return PluginMetadata{}, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return PluginMetadata{}, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	defer gr.Close()
+
+	// 只读取清单和元数据文件，跳过插件文件
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for count := 0; ; count++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return PluginMetadata{}, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+		}
+		if count >= maxPackageEntries+2 {
+			return PluginMetadata{}, fmt.Errorf("%w: too many files", ErrInvalidPackage)
+		}
+		if hdr.Name != packageManifest && !strings.HasSuffix(hdr.Name, ".yml") {
+			continue
+		}
+		if hdr.Size > maxMetadataSize {
+			return PluginMetadata{}, fmt.Errorf("%w: %s is too large", ErrInvalidPackage, hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return PluginMetadata{}, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+		}
+		files[hdr.Name] = data
+	}
+
+	var manifest Manifest
+	data, ok := files[packageManifest]
+	if !ok {
+		return PluginMetadata{}, fmt.Errorf("%w: missing %s", ErrInvalidPackage, packageManifest)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return PluginMetadata{}, fmt.Errorf("%w: failed to parse manifest: %v", ErrInvalidPackage, err)
+	}
+
+	data, ok = files[manifest.Plugin+".yml"]
+	if !ok {
+		return PluginMetadata{}, fmt.Errorf("%w: missing %s.yml", ErrInvalidPackage, manifest.Plugin)
+	}
+	var metadata PluginMetadata
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return PluginMetadata{}, fmt.Errorf("%w: failed to parse plugin metadata: %v", ErrInvalidPackage, err)
+	}
+	if metadata.ID != manifest.ID || metadata.Version != manifest.Version {
+		return PluginMetadata{}, fmt.Errorf("%w: metadata %s %s does not match manifest %s %s",
+			ErrInvalidPackage, metadata.ID, metadata.Version, manifest.ID, manifest.Version)
+	}
+	metadata.Path = manifest.Plugin
+
+	return metadata, nil
+}
+
+// BuildRepositoryIndex 根据目录dir中的插件包（*.tar.gz、*.tgz）生成插件仓库索引，插件包位置为相对于dir的文件名
+func BuildRepositoryIndex(dir string) (RepositoryIndex, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return RepositoryIndex{}, err
+	}
+
+	plugins := make(map[string]*RepositoryPlugin)
+	latest := make(map[string]Version)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		metadata, err := ReadPackageMetadata(path)
+		if err != nil {
+			return RepositoryIndex{}, fmt.Errorf("%s: %w", name, err)
+		}
+		version, err := ParseVersion(metadata.Version)
+		if err != nil {
+			return RepositoryIndex{}, fmt.Errorf("%s: %w", name, err)
+		}
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return RepositoryIndex{}, err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return RepositoryIndex{}, err
+		}
+
+		rp, ok := plugins[metadata.ID]
+		if !ok {
+			rp = &RepositoryPlugin{ID: metadata.ID}
+			plugins[metadata.ID] = rp
+		}
+		for _, r := range rp.Versions {
+			if r.Version == metadata.Version {
+				return RepositoryIndex{}, fmt.Errorf("%s: duplicate package for %s %s (%s)", name, metadata.ID, metadata.Version, r.Package)
+			}
+		}
+		rp.Versions = append(rp.Versions, RepositoryRelease{
+			Version:          metadata.Version,
+			Package:          name,
+			SHA256:           sum,
+			Size:             info.Size(),
+			APIVersion:       metadata.APIVersion,
+			MinServerVersion: metadata.MinServerVersion,
+		})
+
+		// 名称和描述取最新版本的元数据
+		if !ok || version.Compare(latest[metadata.ID]) > 0 {
+			rp.Name = metadata.Name
+			rp.Description = metadata.Description
+			latest[metadata.ID] = version
+		}
+	}
+
+	idx := RepositoryIndex{Plugins: make([]RepositoryPlugin, 0, len(plugins))}
+	for _, rp := range plugins {
+		sort.Slice(rp.Versions, func(i, j int) bool {
+			a, _ := ParseVersion(rp.Versions[i].Version)
+			b, _ := ParseVersion(rp.Versions[j].Version)
+			return a.Compare(b) > 0
+		})
+		idx.Plugins = append(idx.Plugins, *rp)
+	}
+	sort.Slice(idx.Plugins, func(i, j int) bool {
+		return idx.Plugins[i].ID < idx.Plugins[j].ID
+	})
+
+	return idx, nil
+}
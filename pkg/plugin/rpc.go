@@ -0,0 +1,382 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	ErrPluginProcessExited = errors.New("plugin process exited")
+	ErrStreamOverflow      = errors.New("stream buffer overflow")
+)
+
+// maxStreamPending 命令输入或输出缓冲中未读取数据的上限，读取方跟不上时命令失败
+const maxStreamPending = 16 << 20
+
+// 插件进程通道的方法名
+const (
+	rpcDescribe = "describe"
+	rpcInit     = "init"
+	rpcSetState = "set_state"
+	rpcStart    = "start"
+	rpcStop     = "stop"
+	rpcRestart  = "restart"
+	rpcPause    = "pause"
+	rpcResume   = "resume"
+	rpcFailed   = "failed"
+	rpcHealth   = "health"
+	rpcReconfig = "reconfigure"
+	rpcExecute  = "execute"
+	rpcCleanup  = "cleanup"
+
+	// 以下为无需响应的通知
+	rpcInput  = "input"
+	rpcOutput = "output"
+	rpcCancel = "cancel"
+)
+
+// rpcMessage 插件进程通道上的消息，每条消息为一个JSON值。
+// Method非空且ID非0为请求，Method非空且ID为0为通知，Method为空为响应
+type rpcMessage struct {
+	ID       uint64          `json:"id,omitempty"`
+	Method   string          `json:"method,omitempty"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	ExitCode int             `json:"exit_code,omitempty"`
+}
+
+// describeResult 插件描述信息
+type describeResult struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Version     string      `json:"version"`
+	Type        PluginType  `json:"type"`
+	CommandType CommandType `json:"command_type"`
+	Commands    []string    `json:"commands,omitempty"`
+	// ConfigSchema 插件发布的配置结构
+	ConfigSchema *ConfigSchema `json:"config_schema,omitempty"`
+	// Reconfigurable 插件是否支持reconfigure调用
+	Reconfigurable bool `json:"reconfigurable,omitempty"`
+	// Descriptors 插件实现ICommandDescriber时提供的命令描述
+	Descriptors []CommandDescriptor `json:"descriptors,omitempty"`
+	// HealthCheck 插件是否实现了HealthChecker
+	HealthCheck bool `json:"health_check,omitempty"`
+}
+
+// initParams 初始化参数，也用作reconfigure调用的参数
+type initParams struct {
+	Config []byte `json:"config,omitempty"`
+	// DataDir 插件的数据目录，插件进程通过Host.DataDir获取
+	DataDir string `json:"data_dir,omitempty"`
+}
+
+// stateParams 状态参数，也用作生命周期调用的结果
+type stateParams struct {
+	State PluginState `json:"state"`
+}
+
+// failedResult 服务运行故障，正常时Error为空
+type failedResult struct {
+	Error string `json:"error,omitempty"`
+}
+
+// executeParams 执行命令参数
+type executeParams struct {
+	Args []string `json:"args"`
+	// Caller 执行命令的客户端，插件进程通过Host.Caller获取
+	Caller *Caller `json:"caller,omitempty"`
+}
+
+// streamParams 命令输入输出数据，Call为execute请求的ID
+type streamParams struct {
+	Call uint64 `json:"call"`
+	Data []byte `json:"data,omitempty"`
+	EOF  bool   `json:"eof,omitempty"`
+}
+
+// ExitError 带退出状态的错误，用于在插件进程与服务器之间传递命令的退出状态
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+// Error 返回错误信息
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+// ExitCode 返回退出状态
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// rpcConn 插件进程通道，服务器和插件进程两端共用
+type rpcConn struct {
+	rw      io.ReadWriteCloser
+	enc     *json.Encoder
+	encMu   sync.Mutex
+	handler func(msg *rpcMessage)
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *rpcMessage
+	done    chan struct{}
+	err     error
+}
+
+// newRPCConn 创建插件进程通道，handler处理对端的请求和通知，在读取协程中调用
+func newRPCConn(rw io.ReadWriteCloser, handler func(msg *rpcMessage)) *rpcConn {
+	c := &rpcConn{
+		rw:      rw,
+		enc:     json.NewEncoder(rw),
+		handler: handler,
+		pending: make(map[uint64]chan *rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// readLoop 读取对端消息，直到通道关闭
+func (c *rpcConn) readLoop() {
+	dec := json.NewDecoder(c.rw)
+	var err error
+	for {
+		var msg rpcMessage
+		if err = dec.Decode(&msg); err != nil {
+			break
+		}
+
+		if msg.Method != "" {
+			c.handler(&msg)
+			continue
+		}
+
+		c.mu.Lock()
+		ch, exists := c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		if exists {
+			ch <- &msg
+		}
+	}
+
+	if err == io.EOF {
+		err = ErrPluginProcessExited
+	}
+
+	c.mu.Lock()
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// write 发送消息
+func (c *rpcConn) write(msg *rpcMessage) error {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	return c.enc.Encode(msg)
+}
+
+// newID 分配请求ID
+func (c *rpcConn) newID() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return c.nextID
+}
+
+// send 发送请求，返回用于等待响应的通道
+func (c *rpcConn) send(id uint64, method string, params interface{}) (chan *rpcMessage, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+
+	ch := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.write(&rpcMessage{ID: id, Method: method, Params: raw}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	return ch, nil
+}
+
+// wait 等待响应并解析结果
+func (c *rpcConn) wait(ctx context.Context, id uint64, ch chan *rpcMessage, result interface{}) error {
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	case msg, ok := <-ch:
+		if !ok {
+			return c.closeErr()
+		}
+		if msg.Error != "" {
+			if msg.ExitCode != 0 {
+				return &ExitError{Code: msg.ExitCode, Message: msg.Error}
+			}
+			return errors.New(msg.Error)
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// call 发送请求并等待响应
+func (c *rpcConn) call(ctx context.Context, method string, params, result interface{}) error {
+	id := c.newID()
+	ch, err := c.send(id, method, params)
+	if err != nil {
+		return err
+	}
+	return c.wait(ctx, id, ch, result)
+}
+
+// notify 发送通知
+func (c *rpcConn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+	return c.write(&rpcMessage{Method: method, Params: raw})
+}
+
+// reply 发送响应
+func (c *rpcConn) reply(id uint64, result interface{}, callErr error) error {
+	msg := &rpcMessage{ID: id}
+	if callErr != nil {
+		msg.Error = callErr.Error()
+		var exitErr interface{ ExitCode() int }
+		if errors.As(callErr, &exitErr) {
+			msg.ExitCode = exitErr.ExitCode()
+		}
+	} else if result != nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = fmt.Sprintf("failed to encode result: %v", err)
+		} else {
+			msg.Result = raw
+		}
+	}
+	return c.write(msg)
+}
+
+// closeErr 返回通道关闭的原因
+func (c *rpcConn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return ErrPluginProcessExited
+}
+
+// Close 关闭通道
+func (c *rpcConn) Close() error {
+	return c.rw.Close()
+}
+
+// streamBuffer 输入输出缓冲，写入不会阻塞读取协程。未读取的数据超过上限时缓冲以ErrStreamOverflow关闭
+type streamBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	limit  int
+	closed bool
+	err    error
+}
+
+// newStreamBuffer 创建缓冲，limit为未读取数据的上限
+func newStreamBuffer(limit int) *streamBuffer {
+	b := &streamBuffer{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Write 写入数据，超过上限时丢弃未读取的数据并关闭缓冲，之后的读取返回ErrStreamOverflow
+func (b *streamBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if b.buf.Len()+len(data) > b.limit {
+		b.closed = true
+		b.err = fmt.Errorf("%w: more than %d bytes pending", ErrStreamOverflow, b.limit)
+		b.buf.Reset()
+		b.cond.Broadcast()
+		return 0, b.err
+	}
+	n, err := b.buf.Write(data)
+	b.cond.Broadcast()
+	return n, err
+}
+
+// Read 读取数据，无数据时阻塞直到写入或关闭
+func (b *streamBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	for b.buf.Len() == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		if b.closed {
+			return 0, io.EOF
+		}
+		b.cond.Wait()
+	}
+	return b.buf.Read(p)
+}
+
+// Close 关闭缓冲，已写入的数据仍可读取
+func (b *streamBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+	return nil
+}
+
+// CloseWithError 关闭缓冲并丢弃未读取的数据，之后的读取立即返回err
+func (b *streamBuffer) CloseWithError(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.err = err
+	b.buf.Reset()
+	b.cond.Broadcast()
+	return nil
+}
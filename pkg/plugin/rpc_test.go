@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStreamBufferLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		// ops 依次执行的操作：w:写入数据，r<n>:读取n字节
+		ops      []string
+		want     string
+		overflow bool
+	}{
+		{name: "within limit", ops: []string{"w:abc", "w:def"}, want: "abcdef"},
+		{name: "exactly limit", ops: []string{"w:abcdefgh"}, want: "abcdefgh"},
+		{name: "read frees space", ops: []string{"w:abcdefgh", "r4", "w:ijkl"}, want: "efghijkl"},
+		{name: "overflow", ops: []string{"w:abcde", "w:fghij"}, overflow: true},
+		{name: "single write over limit", ops: []string{"w:abcdefghi"}, overflow: true},
+		{name: "write after overflow", ops: []string{"w:abcdefghi", "w:a"}, overflow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newStreamBuffer(8)
+			var writeErr error
+			for _, op := range tt.ops {
+				if op[0] == 'r' {
+					n := int(op[1] - '0')
+					if _, err := io.ReadFull(b, make([]byte, n)); err != nil {
+						t.Fatalf("read %d: %v", n, err)
+					}
+					continue
+				}
+				if _, err := b.Write([]byte(op[2:])); err != nil {
+					writeErr = err
+				}
+			}
+			b.Close()
+
+			got, err := io.ReadAll(b)
+			if tt.overflow {
+				if !errors.Is(writeErr, ErrStreamOverflow) {
+					t.Errorf("write error = %v, want %v", writeErr, ErrStreamOverflow)
+				}
+				// 溢出后丢弃未读取的数据，读取返回溢出错误
+				if !errors.Is(err, ErrStreamOverflow) || len(got) != 0 {
+					t.Errorf("read = %q, %v; want overflow error", got, err)
+				}
+				return
+			}
+			if writeErr != nil || err != nil {
+				t.Fatalf("write error %v, read error %v", writeErr, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
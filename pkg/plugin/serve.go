@@ -0,0 +1,346 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// PluginSocketEnv 使用unix传输方式时，服务器通过该环境变量传递插件进程需要连接的socket路径
+const PluginSocketEnv = "SGO_PLUGIN_SOCKET"
+
+// pluginServer 插件进程端，将服务器的调用转发给插件实例
+type pluginServer struct {
+	p      Plugin
+	conn   *rpcConn
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// dataDir 服务器在初始化时传入的数据目录
+	dataDir string
+
+	mu     sync.Mutex
+	calls  map[uint64]*serverCall
+	closed chan struct{}
+	once   sync.Once
+}
+
+// serverCall 插件进程中正在执行的命令
+type serverCall struct {
+	input  *streamBuffer
+	cancel context.CancelFunc
+}
+
+// Serve 在插件进程中运行插件，通过标准输入输出或unix socket与服务器通信，直到服务器调用Cleanup或连接断开。
+// 使用标准输入输出通信时，插件写入os.Stdout的内容会被重定向到标准错误输出，由服务器记录到日志
+func Serve(p Plugin) error {
+	var rw io.ReadWriteCloser
+	if socketPath := os.Getenv(PluginSocketEnv); socketPath != "" {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			return fmt.Errorf("failed to connect to plugin host: %w", err)
+		}
+		rw = conn
+	} else {
+		rw = pipeConn{r: os.Stdin, w: os.Stdout}
+		os.Stdout = os.Stderr
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &pluginServer{
+		p:      p,
+		ctx:    ctx,
+		cancel: cancel,
+		calls:  make(map[uint64]*serverCall),
+		closed: make(chan struct{}),
+	}
+	s.conn = newRPCConn(rw, s.handle)
+
+	select {
+	case <-s.conn.done:
+		// 服务器退出或连接断开，清理插件资源
+		p.Cleanup()
+	case <-s.closed:
+	}
+
+	s.conn.Close()
+	return nil
+}
+
+// handle 处理服务器的请求和通知
+func (s *pluginServer) handle(msg *rpcMessage) {
+	switch msg.Method {
+	case rpcInput:
+		var params streamParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return
+		}
+		s.mu.Lock()
+		call, exists := s.calls[params.Call]
+		s.mu.Unlock()
+		if !exists {
+			return
+		}
+		if len(params.Data) > 0 {
+			call.input.Write(params.Data)
+		}
+		if params.EOF {
+			call.input.Close()
+		}
+	case rpcCancel:
+		var params streamParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return
+		}
+		s.mu.Lock()
+		call, exists := s.calls[params.Call]
+		s.mu.Unlock()
+		if exists {
+			call.cancel()
+		}
+	case rpcExecute:
+		// 在读取协程中登记，保证随后到达的输入能找到对应的命令
+		ctx, cancel := context.WithCancel(s.ctx)
+		call := &serverCall{input: newStreamBuffer(maxStreamPending), cancel: cancel}
+		s.mu.Lock()
+		s.calls[msg.ID] = call
+		s.mu.Unlock()
+
+		// 命令取消或结束时关闭输入，正在等待输入的读取立即返回
+		go func() {
+			<-ctx.Done()
+			call.input.CloseWithError(ctx.Err())
+		}()
+		go s.execute(ctx, msg, call)
+	default:
+		go s.dispatch(msg)
+	}
+}
+
+// dispatch 处理除命令执行外的请求
+func (s *pluginServer) dispatch(msg *rpcMessage) {
+	var result interface{}
+	var err error
+
+	switch msg.Method {
+	case rpcDescribe:
+		desc := describeResult{
+			ID:      s.p.ID(),
+			Name:    s.p.Name(),
+			Version: s.p.Version(),
+			Type:    s.p.Type(),
+		}
+		if cp, ok := s.p.(ICommandPlugin); ok {
+			desc.CommandType = cp.CommandType()
+		}
+		if handler, ok := s.p.(CommandHandler); ok {
+			desc.Commands = handler.GetCommands()
+		}
+		if describer, ok := s.p.(ICommandDescriber); ok {
+			desc.Descriptors = describer.DescribeCommands()
+		}
+		if configurable, ok := s.p.(IConfigurable); ok {
+			schema := configurable.ConfigSchema()
+			desc.ConfigSchema = &schema
+		}
+		_, desc.Reconfigurable = s.p.(IReconfigurable)
+		_, desc.HealthCheck = s.p.(HealthChecker)
+		result = desc
+	case rpcInit:
+		var params initParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.mu.Lock()
+			s.dataDir = params.DataDir
+			s.mu.Unlock()
+			err = s.p.Init(WithHost(s.ctx, s.host(nil)), params.Config)
+		}
+	case rpcReconfig:
+		var params initParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			if rp, ok := s.p.(IReconfigurable); ok {
+				err = rp.Reconfigure(s.ctx, params.Config)
+			} else {
+				err = ErrReconfigureNotSupported
+			}
+		}
+	case rpcSetState:
+		var params stateParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			err = s.p.SetState(params.State)
+		}
+		result = stateParams{State: s.p.State()}
+	case rpcStart, rpcStop, rpcRestart, rpcPause, rpcResume:
+		sp, ok := s.p.(IServicePlugin)
+		if !ok {
+			err = ErrPluginTypeMismatch
+			break
+		}
+		switch msg.Method {
+		case rpcStart:
+			err = sp.Start(s.ctx)
+		case rpcStop:
+			err = sp.Stop()
+		case rpcRestart:
+			err = sp.Restart(s.ctx)
+		case rpcPause:
+			err = sp.Pause()
+		case rpcResume:
+			err = sp.Resume()
+		}
+		result = stateParams{State: s.p.State()}
+	case rpcFailed:
+		var failed failedResult
+		if watcher, ok := s.p.(IServiceWatcher); ok {
+			if ferr := watcher.Failed(); ferr != nil {
+				failed.Error = ferr.Error()
+			}
+		}
+		result = failed
+	case rpcHealth:
+		if checker, ok := s.p.(HealthChecker); ok {
+			result = checker.CheckHealth(s.ctx)
+		} else {
+			result = Health{}
+		}
+	case rpcCleanup:
+		err = s.p.Cleanup()
+		s.conn.reply(msg.ID, nil, err)
+		s.once.Do(func() { close(s.closed) })
+		return
+	default:
+		err = fmt.Errorf("unknown method: %s", msg.Method)
+	}
+
+	s.conn.reply(msg.ID, result, err)
+}
+
+// execute 执行命令，输出以通知的方式发送给服务器
+func (s *pluginServer) execute(ctx context.Context, msg *rpcMessage, call *serverCall) {
+	defer func() {
+		call.cancel()
+		s.mu.Lock()
+		delete(s.calls, msg.ID)
+		s.mu.Unlock()
+	}()
+
+	handler, ok := s.p.(CommandHandler)
+	if !ok {
+		s.conn.reply(msg.ID, nil, ErrPluginNoCommands)
+		return
+	}
+
+	var params executeParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.conn.reply(msg.ID, nil, fmt.Errorf("invalid execute params: %w", err))
+		return
+	}
+
+	output := &outputWriter{conn: s.conn, call: msg.ID}
+	err := handler.Execute(WithHost(ctx, s.host(params.Caller)), params.Args, call.input, output)
+	s.conn.reply(msg.ID, nil, err)
+}
+
+// host 创建插件进程中的Host
+func (s *pluginServer) host(caller *Caller) *processHost {
+	s.mu.Lock()
+	dataDir := s.dataDir
+	s.mu.Unlock()
+
+	return &processHost{
+		id:      s.p.ID(),
+		logger:  log.New(os.Stderr, fmt.Sprintf("[%s] ", s.p.ID()), log.LstdFlags|log.Lmsgprefix),
+		dataDir: dataDir,
+		caller:  caller,
+	}
+}
+
+// processHost 插件进程中的Host，只提供日志、数据目录和客户端身份，
+// 指标、事件、其他插件、插件间调用和拦截器需要在服务器进程中访问，调用时返回ErrHostUnsupported
+type processHost struct {
+	id      string
+	logger  *log.Logger
+	dataDir string
+	caller  *Caller
+}
+
+// PluginID 返回插件ID
+func (h *processHost) PluginID() string {
+	return h.id
+}
+
+// Logger 返回写入标准错误输出的日志记录器，由服务器记录到日志
+func (h *processHost) Logger() *log.Logger {
+	return h.logger
+}
+
+// DataDir 返回插件的数据目录
+func (h *processHost) DataDir() string {
+	if h.dataDir != "" {
+		os.MkdirAll(h.dataDir, 0755)
+	}
+	return h.dataDir
+}
+
+// Caller 返回执行命令的客户端
+func (h *processHost) Caller() (Caller, bool) {
+	if h.caller == nil {
+		return Caller{}, false
+	}
+	return *h.caller, true
+}
+
+// RegisterMetric 插件进程不支持
+func (h *processHost) RegisterMetric(name, help string, collect func() float64) error {
+	return ErrHostUnsupported
+}
+
+// Publish 插件进程不支持
+func (h *processHost) Publish(topic string, data interface{}) error {
+	return ErrHostUnsupported
+}
+
+// Subscribe 插件进程不支持
+func (h *processHost) Subscribe(topic string, handler func(Event)) (func(), error) {
+	return nil, ErrHostUnsupported
+}
+
+// Plugin 插件进程不支持
+func (h *processHost) Plugin(id string) (Plugin, error) {
+	return nil, ErrHostUnsupported
+}
+
+// Manager 插件进程不支持
+func (h *processHost) Manager() (PluginManager, error) {
+	return nil, ErrHostUnsupported
+}
+
+// Invoke 插件进程不支持
+func (h *processHost) Invoke(ctx context.Context, pluginID string, args []string, input io.Reader, output io.Writer) error {
+	return ErrHostUnsupported
+}
+
+// Intercept 插件进程不支持
+func (h *processHost) Intercept(name string, interceptor Interceptor) error {
+	return ErrHostUnsupported
+}
+
+// outputWriter 将命令输出转发给服务器
+type outputWriter struct {
+	conn *rpcConn
+	call uint64
+}
+
+// Write 发送输出数据
+func (w *outputWriter) Write(data []byte) (int, error) {
+	if err := w.conn.notify(rpcOutput, streamParams{Call: w.call, Data: data}); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// stateFile 插件期望状态文件，位于配置目录下
+const stateFile = "plugin_state.json"
+
+// 插件期望状态，由管理命令设置，服务器启动时恢复
+const (
+	// DesiredDisabled 插件保持禁用
+	DesiredDisabled = "disabled"
+	// DesiredEnabled 插件启用，服务类插件不自动启动
+	DesiredEnabled = "enabled"
+	// DesiredRunning 插件启用并启动服务
+	DesiredRunning = "running"
+	// DesiredPaused 插件启用，服务启动后暂停
+	DesiredPaused = "paused"
+)
+
+// pluginStates 期望状态文件的内容
+type pluginStates struct {
+	Plugins map[string]string `json:"plugins"`
+}
+
+// loadStates 读取期望状态文件，文件不存在时返回空记录
+func loadStates(path string) (map[string]string, error) {
+	states := make(map[string]string)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return states, err
+	}
+
+	var file pluginStates
+	if err := json.Unmarshal(data, &file); err != nil {
+		return states, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for id, state := range file.Plugins {
+		switch state {
+		case DesiredDisabled, DesiredEnabled, DesiredRunning, DesiredPaused:
+			states[id] = state
+		default:
+			log.Printf("Warning: ignoring unknown state %q of plugin %s in %s", state, id, path)
+		}
+	}
+	return states, nil
+}
+
+// DesiredState 获取插件记录的期望状态，没有记录时返回false
+func (pm *DefaultPluginManager) DesiredState(id string) (string, bool) {
+	pm.stateMu.Lock()
+	defer pm.stateMu.Unlock()
+
+	state, exists := pm.states[id]
+	return state, exists
+}
+
+// setDesiredState 记录插件的期望状态并写入状态文件，state为空时删除记录
+func (pm *DefaultPluginManager) setDesiredState(id, state string) {
+	pm.stateMu.Lock()
+	defer pm.stateMu.Unlock()
+
+	current, exists := pm.states[id]
+	if (state == "" && !exists) || (state != "" && current == state) {
+		return
+	}
+	if state == "" {
+		delete(pm.states, id)
+	} else {
+		pm.states[id] = state
+	}
+
+	data, err := json.MarshalIndent(pluginStates{Plugins: pm.states}, "", "  ")
+	if err == nil {
+		err = writeConfig(filepath.Join(pm.configDir, stateFile), data)
+	}
+	if err != nil {
+		log.Printf("Failed to save state of plugin %s: %v", id, err)
+	}
+}
+
+// RestoreStates 使已加载的插件进入状态文件中记录的期望状态，按依赖关系依次处理。
+// 没有记录的插件启用，服务是否启动由autostart决定
+func (pm *DefaultPluginManager) RestoreStates() error {
+	pm.mu.RLock()
+	metadata := make([]PluginMetadata, 0, len(pm.metadata))
+	for _, m := range pm.metadata {
+		metadata = append(metadata, m)
+	}
+	pm.mu.RUnlock()
+
+	// 依赖缺失或循环依赖的插件排在其他插件之后仍尝试恢复，启用失败的原因和排序的错误一并返回，不会被忽略
+	var errs []error
+	ordered, err := SortByDependencies(metadata)
+	if err != nil {
+		errs = append(errs, err)
+		sorted := make(map[string]bool, len(ordered))
+		for _, m := range ordered {
+			sorted[m.ID] = true
+		}
+		for _, m := range metadata {
+			if !sorted[m.ID] {
+				ordered = append(ordered, m)
+			}
+		}
+	}
+
+	for _, m := range ordered {
+		id := m.ID
+		state, recorded := pm.DesiredState(id)
+		if state == DesiredDisabled {
+			log.Printf("Plugin %s is disabled", id)
+			continue
+		}
+
+		if err := pm.enablePlugin(id); err != nil && !errors.Is(err, ErrPluginEnabled) {
+			errs = append(errs, fmt.Errorf("failed to enable %s: %w", id, err))
+			continue
+		}
+		if !recorded || (state != DesiredRunning && state != DesiredPaused) {
+			continue
+		}
+
+		if err := pm.startService(id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to start %s: %w", id, err))
+			continue
+		}
+		log.Printf("Service %s started", id)
+
+		if state == DesiredPaused {
+			if err := pm.pauseService(id); err != nil {
+				errs = append(errs, fmt.Errorf("failed to pause %s: %w", id, err))
+				continue
+			}
+			log.Printf("Service %s paused", id)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreStates(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    map[string][]string
+		states  map[string]string
+		enabled map[string]bool
+		wantErr error
+		// failed 排序失败后仍尝试恢复、在错误中报告的插件
+		failed []string
+	}{
+		{
+			name:    "no records",
+			enabled: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name:    "recorded disabled",
+			states:  map[string]string{"a": DesiredDisabled},
+			enabled: map[string]bool{"a": false, "b": true},
+		},
+		{
+			name:    "missing dependency",
+			deps:    map[string][]string{"a": {"missing"}},
+			enabled: map[string]bool{"a": false, "b": true},
+			wantErr: ErrDependencyMissing,
+			failed:  []string{"a"},
+		},
+		{
+			name:    "dependency cycle",
+			deps:    map[string][]string{"a": {"b"}, "b": {"a"}},
+			enabled: map[string]bool{"a": false, "b": false},
+			wantErr: ErrDependencyCycle,
+			failed:  []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := newTestManager(t)
+			plugins := map[string]*testCommandPlugin{}
+			for _, id := range []string{"a", "b"} {
+				plugins[id] = registerTestCommands(t, pm, id)
+				m := pm.metadata[id]
+				m.Dependencies = tt.deps[id]
+				pm.metadata[id] = m
+			}
+			for id, state := range tt.states {
+				pm.setDesiredState(id, state)
+			}
+
+			err := pm.RestoreStates()
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("RestoreStates: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RestoreStates error = %v, want %v", err, tt.wantErr)
+			}
+
+			for _, id := range tt.failed {
+				if !strings.Contains(err.Error(), "failed to enable "+id) {
+					t.Errorf("error does not report plugin %s: %v", id, err)
+				}
+			}
+			for id, want := range tt.enabled {
+				if got := plugins[id].State() != Disabled; got != want {
+					t.Errorf("plugin %s enabled = %v, want %v", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnloadPluginRecordsDisabled(t *testing.T) {
+	pm := newTestManager(t)
+	registerTestCommands(t, pm, "a")
+	pm.setDesiredState("a", DesiredEnabled)
+
+	if err := pm.UnloadPlugin("a", false); err != nil {
+		t.Fatalf("UnloadPlugin: %v", err)
+	}
+	if state, _ := pm.DesiredState("a"); state != DesiredDisabled {
+		t.Errorf("desired state = %q, want %q", state, DesiredDisabled)
+	}
+
+	// 状态文件中同样记录为禁用，重启后插件不会被重新启用
+	states, err := loadStates(filepath.Join(pm.configDir, stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states["a"] != DesiredDisabled {
+		t.Errorf("persisted state = %q, want %q", states["a"], DesiredDisabled)
+	}
+}
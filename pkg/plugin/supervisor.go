@@ -0,0 +1,526 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 服务重启策略
+const (
+	// RestartNever 服务停止或故障后不重启（默认）
+	RestartNever = "never"
+	// RestartOnFailure 服务报告故障后重启
+	RestartOnFailure = "on-failure"
+	// RestartAlways 服务停止或故障后都重启
+	RestartAlways = "always"
+)
+
+const (
+	// superviseInterval 检查服务状态的间隔
+	superviseInterval = time.Second
+	// defaultBackoffInitial 默认首次重启间隔
+	defaultBackoffInitial = time.Second
+	// defaultBackoffMax 默认最大重启间隔
+	defaultBackoffMax = time.Minute
+)
+
+// PluginPolicy 插件运行策略
+type PluginPolicy struct {
+	// Autostart 服务器启动时自动启动服务
+	Autostart bool `json:"autostart,omitempty"`
+	// Restart 重启策略：never（默认）、on-failure或always
+	Restart string `json:"restart,omitempty"`
+	// BackoffInitial 首次重启间隔（秒），默认1秒，之后每次加倍
+	BackoffInitial int `json:"backoff_initial,omitempty"`
+	// BackoffMax 最大重启间隔（秒），默认60秒；服务稳定运行超过该时间后重启间隔从头计算
+	BackoffMax int `json:"backoff_max,omitempty"`
+	// Timeout 命令的最长执行时间（秒），0表示不限制
+	Timeout int `json:"timeout,omitempty"`
+	// CommandTimeouts 按命令名配置的最长执行时间（秒），覆盖Timeout，0表示该命令不限制
+	CommandTimeouts map[string]int `json:"command_timeouts,omitempty"`
+	// MaxPanics 一段时间内命令panic达到该次数后隔离插件，默认3次，负数表示不隔离
+	MaxPanics int `json:"max_panics,omitempty"`
+	// Sandbox 授予wasm插件的宿主资源
+	Sandbox WasmSandbox `json:"sandbox,omitempty"`
+}
+
+// Validate 校验运行策略
+func (p PluginPolicy) Validate() error {
+	if p.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %d", p.Timeout)
+	}
+	for command, timeout := range p.CommandTimeouts {
+		if timeout < 0 {
+			return fmt.Errorf("invalid timeout for command %s: %d", command, timeout)
+		}
+	}
+
+	if err := p.Sandbox.Validate(); err != nil {
+		return err
+	}
+
+	switch p.Restart {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+		return nil
+	default:
+		return fmt.Errorf("unknown restart policy: %s", p.Restart)
+	}
+}
+
+// RestartPolicy 返回重启策略名称，未配置时为never
+func (p PluginPolicy) RestartPolicy() string {
+	if p.Restart == "" {
+		return RestartNever
+	}
+	return p.Restart
+}
+
+// backoffInitial 返回首次重启间隔
+func (p PluginPolicy) backoffInitial() time.Duration {
+	if p.BackoffInitial > 0 {
+		return time.Duration(p.BackoffInitial) * time.Second
+	}
+	return defaultBackoffInitial
+}
+
+// backoffMax 返回最大重启间隔
+func (p PluginPolicy) backoffMax() time.Duration {
+	if p.BackoffMax > 0 {
+		return time.Duration(p.BackoffMax) * time.Second
+	}
+	return defaultBackoffMax
+}
+
+// IServiceWatcher 服务类插件可选实现的接口，用于向监管程序报告运行故障（如监听器意外关闭）
+type IServiceWatcher interface {
+	// Failed 返回服务的运行故障，正常时返回nil
+	Failed() error
+}
+
+// ServiceStatus 服务类插件的监管状态
+type ServiceStatus struct {
+	ID     string
+	State  PluginState
+	Policy PluginPolicy
+	// Supervised 服务是否期望运行，由StartService和StopService设置
+	Supervised  bool
+	Restarts    int
+	LastError   string
+	LastErrorAt time.Time
+	StartedAt   time.Time
+	// NextRestart 下次重启时间，未安排重启时为零值
+	NextRestart time.Time
+}
+
+// serviceRecord 服务的监管记录
+type serviceRecord struct {
+	// opMu 保证启动、停止和监管程序的重启互斥
+	opMu sync.Mutex
+
+	desired      bool
+	restarts     int
+	lastErr      error
+	lastErrAt    time.Time
+	startedAt    time.Time
+	backoff      time.Duration
+	nextRestart  time.Time
+	healthySince time.Time
+}
+
+// SetPolicies 设置插件运行策略
+func (pm *DefaultPluginManager) SetPolicies(policies map[string]PluginPolicy) error {
+	for id, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("plugin %s: %w", id, err)
+		}
+	}
+
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+
+	pm.policies = make(map[string]PluginPolicy, len(policies))
+	for id, policy := range policies {
+		pm.policies[id] = policy
+	}
+	return nil
+}
+
+// AutostartServices 启动所有配置了autostart的已启用服务，记录了期望状态的服务按记录处理
+func (pm *DefaultPluginManager) AutostartServices() error {
+	pm.supMu.Lock()
+	ids := make([]string, 0, len(pm.policies))
+	for id, policy := range pm.policies {
+		if policy.Autostart {
+			ids = append(ids, id)
+		}
+	}
+	pm.supMu.Unlock()
+	sort.Strings(ids)
+
+	var errs []error
+	for _, id := range ids {
+		if _, recorded := pm.DesiredState(id); recorded {
+			continue
+		}
+		if err := pm.startService(id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to autostart %s: %w", id, err))
+			continue
+		}
+		log.Printf("Service %s started automatically", id)
+	}
+	return errors.Join(errs...)
+}
+
+// StartService 启动服务并纳入监管
+func (pm *DefaultPluginManager) StartService(id string) error {
+	if err := pm.startService(id); err != nil {
+		return err
+	}
+	pm.setDesiredState(id, DesiredRunning)
+	return nil
+}
+
+// startService 启动服务并纳入监管，不修改记录的期望状态
+func (pm *DefaultPluginManager) startService(id string) error {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+	if sp.State() == Disabled {
+		return ErrPluginDisabled
+	}
+
+	rec := pm.serviceRecord(id)
+	rec.opMu.Lock()
+	defer rec.opMu.Unlock()
+
+	err = sp.Start(pm.ctx)
+
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+	rec.desired = true
+	rec.backoff = 0
+	rec.nextRestart = time.Time{}
+	if err != nil {
+		rec.setError(err)
+		return err
+	}
+	rec.startedAt = time.Now()
+	return nil
+}
+
+// StopService 停止服务并解除监管
+func (pm *DefaultPluginManager) StopService(id string) error {
+	if err := pm.stopService(id); err != nil {
+		return err
+	}
+	if state, _ := pm.DesiredState(id); state != DesiredDisabled {
+		pm.setDesiredState(id, DesiredEnabled)
+	}
+	return nil
+}
+
+// stopService 停止服务并解除监管，不修改记录的期望状态
+func (pm *DefaultPluginManager) stopService(id string) error {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+
+	rec := pm.serviceRecord(id)
+	pm.supMu.Lock()
+	rec.desired = false
+	rec.nextRestart = time.Time{}
+	pm.supMu.Unlock()
+
+	rec.opMu.Lock()
+	defer rec.opMu.Unlock()
+	return sp.Stop()
+}
+
+// RestartService 重启服务并纳入监管
+func (pm *DefaultPluginManager) RestartService(id string) error {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+	if sp.State() == Disabled {
+		return ErrPluginDisabled
+	}
+
+	rec := pm.serviceRecord(id)
+	rec.opMu.Lock()
+	defer rec.opMu.Unlock()
+
+	err = sp.Restart(pm.ctx)
+
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+	rec.desired = true
+	rec.nextRestart = time.Time{}
+	if err != nil {
+		rec.setError(err)
+		return err
+	}
+	rec.startedAt = time.Now()
+	pm.setDesiredState(id, DesiredRunning)
+	return nil
+}
+
+// PauseService 暂停服务，服务不再接受新的连接但保留已建立的连接，仍受监管
+func (pm *DefaultPluginManager) PauseService(id string) error {
+	if err := pm.pauseService(id); err != nil {
+		return err
+	}
+	pm.setDesiredState(id, DesiredPaused)
+	return nil
+}
+
+// pauseService 暂停服务，不修改记录的期望状态
+func (pm *DefaultPluginManager) pauseService(id string) error {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+	if sp.State() == Disabled {
+		return ErrPluginDisabled
+	}
+
+	rec := pm.serviceRecord(id)
+	rec.opMu.Lock()
+	defer rec.opMu.Unlock()
+	return sp.Pause()
+}
+
+// ResumeService 恢复暂停的服务
+func (pm *DefaultPluginManager) ResumeService(id string) error {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+	if sp.State() == Disabled {
+		return ErrPluginDisabled
+	}
+
+	rec := pm.serviceRecord(id)
+	rec.opMu.Lock()
+	err = sp.Resume()
+	rec.opMu.Unlock()
+	if err != nil {
+		return err
+	}
+	pm.setDesiredState(id, DesiredRunning)
+	return nil
+}
+
+// GetServiceStatus 获取服务的监管状态
+func (pm *DefaultPluginManager) GetServiceStatus(id string) (ServiceStatus, error) {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+
+	status := ServiceStatus{
+		ID:    id,
+		State: sp.State(),
+	}
+
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+
+	status.Policy = pm.policies[id]
+	if rec, exists := pm.services[id]; exists {
+		status.Supervised = rec.desired
+		status.Restarts = rec.restarts
+		status.LastErrorAt = rec.lastErrAt
+		status.StartedAt = rec.startedAt
+		status.NextRestart = rec.nextRestart
+		if rec.lastErr != nil {
+			status.LastError = rec.lastErr.Error()
+		}
+	}
+	return status, nil
+}
+
+// serviceRecord 获取或创建服务的监管记录
+func (pm *DefaultPluginManager) serviceRecord(id string) *serviceRecord {
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+
+	rec, exists := pm.services[id]
+	if !exists {
+		rec = &serviceRecord{}
+		pm.services[id] = rec
+	}
+	return rec
+}
+
+// unsupervise 解除服务监管，插件禁用或卸载时调用
+func (pm *DefaultPluginManager) unsupervise(id string, remove bool) {
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+
+	if remove {
+		delete(pm.services, id)
+		return
+	}
+	if rec, exists := pm.services[id]; exists {
+		rec.desired = false
+		rec.nextRestart = time.Time{}
+	}
+}
+
+// supervise 定时检查受监管的服务，按重启策略重启停止或故障的服务
+func (pm *DefaultPluginManager) supervise() {
+	ticker := time.NewTicker(superviseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pm.supMu.Lock()
+		ids := make([]string, 0, len(pm.services))
+		for id, rec := range pm.services {
+			if rec.desired {
+				ids = append(ids, id)
+			}
+		}
+		pm.supMu.Unlock()
+
+		for _, id := range ids {
+			pm.checkService(id)
+		}
+	}
+}
+
+// checkService 检查服务状态，必要时安排或执行重启
+func (pm *DefaultPluginManager) checkService(id string) {
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return
+	}
+
+	rec := pm.serviceRecord(id)
+	rec.opMu.Lock()
+	defer rec.opMu.Unlock()
+
+	state := sp.State()
+	var failure error
+	if watcher, ok := sp.(IServiceWatcher); ok {
+		failure = watcher.Failed()
+	}
+
+	now := time.Now()
+	pm.supMu.Lock()
+	policy := pm.policies[id]
+	if !rec.desired {
+		pm.supMu.Unlock()
+		return
+	}
+
+	// 服务正常运行，稳定运行足够长时间后重置重启间隔
+	if (state == Running || state == Paused) && failure == nil {
+		if rec.healthySince.IsZero() {
+			rec.healthySince = now
+		} else if now.Sub(rec.healthySince) > policy.backoffMax() {
+			rec.backoff = 0
+		}
+		pm.supMu.Unlock()
+		return
+	}
+	rec.healthySince = time.Time{}
+
+	// 插件被禁用，不再监管
+	if state == Disabled {
+		rec.desired = false
+		pm.supMu.Unlock()
+		return
+	}
+
+	restart := policy.Restart == RestartAlways || (policy.Restart == RestartOnFailure && failure != nil)
+	if !restart {
+		rec.desired = false
+		if failure != nil {
+			rec.setError(failure)
+			log.Printf("Service %s failed: %v (restart policy: %s)", id, failure, policy.RestartPolicy())
+		} else {
+			log.Printf("Service %s stopped unexpectedly (restart policy: %s)", id, policy.RestartPolicy())
+		}
+		pm.supMu.Unlock()
+		return
+	}
+
+	// 安排重启
+	if rec.nextRestart.IsZero() {
+		if failure != nil {
+			rec.setError(failure)
+		}
+		if rec.backoff == 0 {
+			rec.backoff = policy.backoffInitial()
+		} else {
+			rec.backoff *= 2
+			if rec.backoff > policy.backoffMax() {
+				rec.backoff = policy.backoffMax()
+			}
+		}
+		rec.nextRestart = now.Add(rec.backoff)
+		log.Printf("Service %s is down (%s), restarting in %s", id, describeFailure(state, failure), rec.backoff)
+		pm.supMu.Unlock()
+		return
+	}
+	if now.Before(rec.nextRestart) {
+		pm.supMu.Unlock()
+		return
+	}
+	rec.nextRestart = time.Time{}
+	rec.restarts++
+	attempt := rec.restarts
+	pm.supMu.Unlock()
+
+	// 执行重启，故障但仍处于运行状态的服务先停止
+	log.Printf("Restarting service %s (restart #%d)", id, attempt)
+	if state == Running || state == Paused {
+		if err := sp.Stop(); err != nil {
+			log.Printf("Failed to stop service %s before restart: %v", id, err)
+		}
+	}
+	err = sp.Start(pm.ctx)
+	// 暂停的服务重启后保持暂停
+	if desired, _ := pm.DesiredState(id); err == nil && desired == DesiredPaused {
+		if perr := sp.Pause(); perr != nil {
+			log.Printf("Failed to pause restarted service %s: %v", id, perr)
+		}
+	}
+
+	pm.supMu.Lock()
+	defer pm.supMu.Unlock()
+	if err != nil {
+		rec.setError(err)
+		log.Printf("Failed to restart service %s: %v", id, err)
+		return
+	}
+	rec.startedAt = time.Now()
+	log.Printf("Service %s restarted", id)
+}
+
+// setError 记录最近一次错误
+func (rec *serviceRecord) setError(err error) {
+	rec.lastErr = err
+	rec.lastErrAt = time.Now()
+}
+
+// describeFailure 描述服务停止的原因
+func describeFailure(state PluginState, failure error) string {
+	if failure != nil {
+		return failure.Error()
+	}
+	return fmt.Sprintf("state %d", state)
+}
@@ -0,0 +1,374 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// backupDir 升级期间保存旧版本插件文件的目录，位于插件目录下
+	backupDir = ".backup"
+	// historyFile 升级历史文件，位于配置目录下，每行一条JSON记录
+	historyFile = "upgrade_history.jsonl"
+	// healthCheckInterval 升级后健康检查的检查间隔
+	healthCheckInterval = 200 * time.Millisecond
+)
+
+// 升级结果
+const (
+	UpgradeSucceeded  = "succeeded"
+	UpgradeRolledBack = "rolled_back"
+	UpgradeFailed     = "failed"
+)
+
+// UpgradeOptions 升级选项
+type UpgradeOptions struct {
+	// HealthCheck 新版本启动后观察的时间，期间服务必须保持运行且未报告故障，0表示不检查
+	HealthCheck time.Duration
+}
+
+// UpgradeRecord 升级历史记录
+type UpgradeRecord struct {
+	Time        time.Time `json:"time"`
+	ID          string    `json:"id"`
+	FromVersion string    `json:"from_version"`
+	ToVersion   string    `json:"to_version"`
+	// Result 升级结果：succeeded、rolled_back或failed（回滚也失败）
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// lockUpgrade 获取插件的升级锁，覆盖快照、替换和回滚的整个过程，返回释放函数
+func (pm *DefaultPluginManager) lockUpgrade(id string) func() {
+	pm.upgradeMu.Lock()
+	lock, exists := pm.upgradeLocks[id]
+	if !exists {
+		lock = &sync.Mutex{}
+		pm.upgradeLocks[id] = lock
+	}
+	pm.upgradeMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// pluginSnapshot 升级前插件的状态，用于恢复
+type pluginSnapshot struct {
+	metadata   PluginMetadata
+	state      PluginState
+	supervised bool
+}
+
+// UpgradePlugin 升级插件。path为暂存的新版本插件文件，校验通过后替换插件目录中的旧版本，
+// 新版本恢复旧版本的启用或运行状态，记录的期望状态不变；加载、启动或健康检查失败时自动恢复旧版本。
+// 同一插件的升级依次执行，后一次升级在前一次完成（包括回滚）后才开始校验
+func (pm *DefaultPluginManager) UpgradePlugin(id string, path string, opts UpgradeOptions) error {
+	unlock := pm.lockUpgrade(id)
+	defer unlock()
+
+	// 暂存并校验新版本，此时旧版本不受影响
+	metadata, err := ReadPluginMetadata(path)
+	if err != nil {
+		return err
+	}
+	if metadata.ID != id {
+		return fmt.Errorf("plugin ID mismatch: expected %s, got %s", id, metadata.ID)
+	}
+	if err := CheckCompatibility(metadata); err != nil {
+		return err
+	}
+	pm.mu.RLock()
+	err = pm.verifyPlugin(path, metadata)
+	if err == nil {
+		err = pm.checkDependencies(metadata)
+	}
+	pm.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := pm.checkDependents(id, metadata.Version); err != nil {
+		return err
+	}
+
+	old, err := pm.snapshot(id)
+	if err != nil {
+		return err
+	}
+
+	record := UpgradeRecord{
+		Time:        time.Now(),
+		ID:          id,
+		FromVersion: old.metadata.Version,
+		ToVersion:   metadata.Version,
+	}
+
+	err = pm.replacePlugin(old, path, opts)
+	switch {
+	case err == nil:
+		record.Result = UpgradeSucceeded
+	case errors.Is(err, errRollbackFailed):
+		record.Result = UpgradeFailed
+		record.Error = err.Error()
+	default:
+		record.Result = UpgradeRolledBack
+		record.Error = err.Error()
+	}
+	if herr := pm.recordUpgrade(record); herr != nil {
+		log.Printf("Failed to record upgrade history: %v", herr)
+	}
+
+	return err
+}
+
+// errRollbackFailed 新版本失败且旧版本也无法恢复
+var errRollbackFailed = errors.New("rollback failed")
+
+// replacePlugin 停止并卸载旧版本，安装并启动新版本，失败时恢复旧版本
+func (pm *DefaultPluginManager) replacePlugin(old pluginSnapshot, staged string, opts UpgradeOptions) error {
+	id := old.metadata.ID
+	backup := filepath.Join(pm.pluginsDir, backupDir, id)
+
+	// 停止并卸载旧版本
+	if old.state == Running || old.state == Paused {
+		if err := pm.stopService(id); err != nil {
+			return fmt.Errorf("failed to stop old plugin: %w", err)
+		}
+	}
+	if err := pm.unloadPlugin(id, true); err != nil {
+		pm.restore(old, backup)
+		return fmt.Errorf("failed to unload old plugin: %w", err)
+	}
+
+	// 旧版本文件移到备份目录，新版本文件移入插件目录
+	if old.metadata.Path != "" {
+		if err := movePluginFiles(old.metadata.Path, backup); err != nil {
+			return pm.rollback(old, "", backup, fmt.Errorf("failed to back up old plugin: %w", err))
+		}
+	}
+	path := filepath.Join(pm.pluginsDir, filepath.Base(staged))
+	if err := movePluginFiles(staged, pm.pluginsDir); err != nil {
+		return pm.rollback(old, path, backup, fmt.Errorf("failed to install new plugin: %w", err))
+	}
+
+	// 加载新版本并恢复旧版本的状态
+	if _, err := pm.LoadPlugin(path); err != nil {
+		return pm.rollback(old, path, backup, fmt.Errorf("failed to load new plugin: %w", err))
+	}
+	if err := pm.applyState(id, old); err != nil {
+		return pm.rollback(old, path, backup, err)
+	}
+	if err := pm.healthCheck(id, old.state, opts.HealthCheck); err != nil {
+		return pm.rollback(old, path, backup, fmt.Errorf("health check failed: %w", err))
+	}
+
+	os.RemoveAll(backup)
+	return nil
+}
+
+// rollback 卸载新版本并恢复旧版本，返回包含升级失败原因的错误
+func (pm *DefaultPluginManager) rollback(old pluginSnapshot, path, backup string, cause error) error {
+	id := old.metadata.ID
+	log.Printf("Upgrade of plugin %s failed, rolling back: %v", id, cause)
+
+	if _, err := pm.GetPlugin(id); err == nil {
+		pm.stopService(id)
+		if err := pm.unloadPlugin(id, true); err != nil {
+			log.Printf("Failed to unload new version of plugin %s: %v", id, err)
+		}
+	}
+	if path != "" {
+		removeFiles(path)
+	}
+
+	if err := pm.restore(old, backup); err != nil {
+		return fmt.Errorf("%w: %v (upgrade error: %v)", errRollbackFailed, err, cause)
+	}
+	return fmt.Errorf("%w; restored version %s", cause, old.metadata.Version)
+}
+
+// restore 从备份恢复旧版本插件文件，重新加载并恢复状态
+func (pm *DefaultPluginManager) restore(old pluginSnapshot, backup string) error {
+	if old.metadata.Path != "" {
+		backupPath := filepath.Join(backup, filepath.Base(old.metadata.Path))
+		if _, err := os.Stat(backupPath + ".yml"); err == nil {
+			if err := movePluginFiles(backupPath, filepath.Dir(old.metadata.Path)); err != nil {
+				return fmt.Errorf("failed to restore plugin files: %w", err)
+			}
+		}
+		os.RemoveAll(backup)
+	}
+
+	var err error
+	if old.metadata.Static {
+		_, err = pm.LoadStaticPlugin(old.metadata.ID)
+	} else {
+		_, err = pm.LoadPlugin(old.metadata.Path)
+	}
+	if err != nil && !errors.Is(err, ErrPluginAlreadyExists) {
+		return fmt.Errorf("failed to reload old plugin: %w", err)
+	}
+
+	return pm.applyState(old.metadata.ID, old)
+}
+
+// snapshot 记录插件当前的元数据和状态
+func (pm *DefaultPluginManager) snapshot(id string) (pluginSnapshot, error) {
+	p, err := pm.GetPlugin(id)
+	if err != nil {
+		return pluginSnapshot{}, err
+	}
+	metadata, err := pm.GetPluginMetadata(id)
+	if err != nil {
+		return pluginSnapshot{}, err
+	}
+
+	snap := pluginSnapshot{metadata: metadata, state: p.State()}
+	pm.supMu.Lock()
+	if rec, exists := pm.services[id]; exists {
+		snap.supervised = rec.desired
+	}
+	pm.supMu.Unlock()
+	return snap, nil
+}
+
+// applyState 使新加载的插件进入快照中的状态
+func (pm *DefaultPluginManager) applyState(id string, snap pluginSnapshot) error {
+	if snap.state == Disabled {
+		return nil
+	}
+	if err := pm.enablePlugin(id); err != nil && !errors.Is(err, ErrPluginEnabled) {
+		return fmt.Errorf("failed to enable plugin: %w", err)
+	}
+	if snap.state != Running && snap.state != Paused {
+		return nil
+	}
+
+	if err := pm.startService(id); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	if !snap.supervised {
+		pm.unsupervise(id, false)
+	}
+	if snap.state == Paused {
+		sp, err := pm.GetServicePlugin(id)
+		if err != nil {
+			return err
+		}
+		if err := sp.Pause(); err != nil {
+			return fmt.Errorf("failed to pause service: %w", err)
+		}
+	}
+	return nil
+}
+
+// healthCheck 在指定时间内观察服务，服务停止或报告故障时返回错误
+func (pm *DefaultPluginManager) healthCheck(id string, state PluginState, d time.Duration) error {
+	if d <= 0 || (state != Running && state != Paused) {
+		return nil
+	}
+
+	sp, err := pm.GetServicePlugin(id)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(d)
+	for {
+		if s := sp.State(); s != Running && s != Paused {
+			return fmt.Errorf("service is no longer running")
+		}
+		if watcher, ok := sp.(IServiceWatcher); ok {
+			if err := watcher.Failed(); err != nil {
+				return err
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(healthCheckInterval)
+	}
+}
+
+// UpgradeHistory 获取升级历史，id为空时返回所有插件的记录
+func (pm *DefaultPluginManager) UpgradeHistory(id string) ([]UpgradeRecord, error) {
+	f, err := os.Open(filepath.Join(pm.configDir, historyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []UpgradeRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record UpgradeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if id == "" || record.ID == id {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// recordUpgrade 追加升级历史记录
+func (pm *DefaultPluginManager) recordUpgrade(record UpgradeRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(pm.configDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(pm.configDir, historyFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// pluginFiles 返回插件文件及其元数据、清单和签名文件
+func pluginFiles(path string) []string {
+	return []string{path, path + ".yml", path + manifestSuffix, path + signatureSuffix}
+}
+
+// movePluginFiles 将插件文件及其附属文件移动到目录dir，不存在的文件跳过
+func movePluginFiles(path, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, src := range pluginFiles(path) {
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.Base(src))
+		if dst == src {
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFiles 删除插件文件及其附属文件
+func removeFiles(path string) {
+	for _, f := range pluginFiles(path) {
+		os.Remove(f)
+	}
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProcessArg 测试二进制以此参数启动时作为进程插件运行，其后为插件版本和行为
+const testProcessArg = "test-plugin-process"
+
+func TestMain(m *testing.M) {
+	if len(os.Args) == 4 && os.Args[1] == testProcessArg {
+		if os.Args[3] == "crash" {
+			os.Exit(1)
+		}
+		Serve(&testService{BaseServicePlugin: NewBaseServicePlugin("svc", "svc", os.Args[2]), mode: os.Args[3]})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testService 测试用的服务插件，mode为start-fails时启动失败，为unhealthy时报告运行故障
+type testService struct {
+	*BaseServicePlugin
+	mode string
+}
+
+func (s *testService) Start(ctx context.Context) error {
+	if s.mode == "start-fails" {
+		return errors.New("start refused")
+	}
+	return s.BaseServicePlugin.Start(ctx)
+}
+
+func (s *testService) Failed() error {
+	if s.mode == "unhealthy" {
+		return errors.New("service unhealthy")
+	}
+	return nil
+}
+
+// writeProcessPlugin 在dir中写入以测试二进制运行的进程插件，返回插件文件路径
+func writeProcessPlugin(t *testing.T, dir, version, mode string) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "svc")
+	metadata := fmt.Sprintf("id: svc\nname: svc\nversion: %s\ntype: 0\nruntime: process\ncommand: %s\nargs: [%s, %s, %s]\n",
+		version, exe, testProcessArg, version, mode)
+	if err := os.WriteFile(path+".yml", []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpgradePluginRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldState    PluginState
+		newMode     string
+		wantErr     string
+		wantVersion string
+		wantResult  string
+	}{
+		{name: "running upgraded", oldState: Running, newMode: "ok", wantVersion: "2.0.0", wantResult: UpgradeSucceeded},
+		{name: "enabled upgraded", oldState: Enabled, newMode: "ok", wantVersion: "2.0.0", wantResult: UpgradeSucceeded},
+		{name: "load failure", oldState: Running, newMode: "crash", wantErr: "failed to load new plugin", wantVersion: "1.0.0", wantResult: UpgradeRolledBack},
+		{name: "load failure keeps enabled", oldState: Enabled, newMode: "crash", wantErr: "failed to load new plugin", wantVersion: "1.0.0", wantResult: UpgradeRolledBack},
+		{name: "start failure", oldState: Running, newMode: "start-fails", wantErr: "failed to start service", wantVersion: "1.0.0", wantResult: UpgradeRolledBack},
+		{name: "health check failure", oldState: Running, newMode: "unhealthy", wantErr: "health check failed", wantVersion: "1.0.0", wantResult: UpgradeRolledBack},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := newTestManager(t)
+			if err := pm.SetTrustConfig(TrustConfig{AllowUnsigned: true}); err != nil {
+				t.Fatal(err)
+			}
+			oldPath := writeProcessPlugin(t, pm.pluginsDir, "1.0.0", "ok")
+			if _, err := pm.LoadPlugin(oldPath); err != nil {
+				t.Fatalf("LoadPlugin: %v", err)
+			}
+			if err := pm.EnablePlugin("svc"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.oldState == Running {
+				if err := pm.StartService("svc"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			staged := writeProcessPlugin(t, t.TempDir(), "2.0.0", tt.newMode)
+			err := pm.UpgradePlugin("svc", staged, UpgradeOptions{HealthCheck: 300 * time.Millisecond})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("UpgradePlugin: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+
+			// 升级或回滚后插件为期望的版本并恢复原来的状态
+			p, err := pm.GetPlugin("svc")
+			if err != nil {
+				t.Fatalf("plugin missing after upgrade: %v", err)
+			}
+			if p.Version() != tt.wantVersion || p.State() != tt.oldState {
+				t.Errorf("plugin version %s, state %v; want %s, %v", p.Version(), p.State(), tt.wantVersion, tt.oldState)
+			}
+			metadata, err := ReadPluginMetadata(oldPath)
+			if err != nil || metadata.Version != tt.wantVersion {
+				t.Errorf("installed metadata version = %q, %v; want %s", metadata.Version, err, tt.wantVersion)
+			}
+			if _, err := os.Stat(filepath.Join(pm.pluginsDir, backupDir, "svc")); !os.IsNotExist(err) {
+				t.Errorf("backup was not removed: %v", err)
+			}
+
+			history, err := pm.UpgradeHistory("svc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 1 {
+				t.Fatalf("history = %+v, want one record", history)
+			}
+			record := history[0]
+			if record.FromVersion != "1.0.0" || record.ToVersion != "2.0.0" || record.Result != tt.wantResult {
+				t.Errorf("record = %+v, want 1.0.0 -> 2.0.0 %s", record, tt.wantResult)
+			}
+			if (record.Error != "") != (tt.wantErr != "") {
+				t.Errorf("record error = %q", record.Error)
+			}
+		})
+	}
+}
+
+func TestUpgradeHistory(t *testing.T) {
+	pm := newTestManager(t)
+
+	if history, err := pm.UpgradeHistory(""); err != nil || history != nil {
+		t.Fatalf("history without file = %v, %v; want none", history, err)
+	}
+
+	records := []UpgradeRecord{
+		{ID: "a", FromVersion: "1.0.0", ToVersion: "1.1.0", Result: UpgradeSucceeded},
+		{ID: "b", FromVersion: "2.0.0", ToVersion: "3.0.0", Result: UpgradeRolledBack, Error: "health check failed"},
+		{ID: "a", FromVersion: "1.1.0", ToVersion: "1.2.0", Result: UpgradeFailed, Error: "rollback failed"},
+	}
+	for _, r := range records {
+		if err := pm.recordUpgrade(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{id: "", want: []string{"a 1.1.0 succeeded", "b 3.0.0 rolled_back", "a 1.2.0 failed"}},
+		{id: "a", want: []string{"a 1.1.0 succeeded", "a 1.2.0 failed"}},
+		{id: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run("id="+tt.id, func(t *testing.T) {
+			history, err := pm.UpgradeHistory(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(history))
+			for _, r := range history {
+				got = append(got, fmt.Sprintf("%s %s %s", r.ID, r.ToVersion, r.Result))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("history = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version 语义化版本号（major.minor.patch[-prerelease][+build]）
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion 解析版本号，允许前缀v，省略的minor和patch视为0，忽略构建信息
+func ParseVersion(s string) (Version, error) {
+	var v Version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.Prerelease = str[i+1:]
+		str = str[:i]
+		if v.Prerelease == "" {
+			return Version{}, fmt.Errorf("invalid version: %q", s)
+		}
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version: %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version: %q", s)
+		}
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// Compare 比较版本号，返回-1、0或1。预发布版本低于对应的正式版本
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// String 返回版本号字符串
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// compareInt 比较整数
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePrerelease 按语义化版本规则比较预发布标识
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInt(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+// versionClause 单个版本约束条件
+type versionClause struct {
+	op      string
+	version Version
+}
+
+// Constraint 版本约束，由多个条件组成，所有条件都满足时版本才满足约束。
+// 支持的运算符：=、!=、>、>=、<、<=、^（兼容版本）、~（补丁版本），多个条件以逗号或空格分隔
+type Constraint struct {
+	raw     string
+	clauses []versionClause
+}
+
+// ParseConstraint 解析版本约束，空字符串或*表示任意版本
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	if c.raw == "" || c.raw == "*" {
+		return c, nil
+	}
+
+	fields := strings.FieldsFunc(c.raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Trim(field, "<>=!^~") == "" {
+			// 运算符与版本号以空格分隔
+			if i+1 >= len(fields) {
+				return Constraint{}, fmt.Errorf("invalid version constraint: %q", s)
+			}
+			i++
+			field += fields[i]
+		}
+
+		clause, err := parseClause(field)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		c.clauses = append(c.clauses, clause)
+	}
+
+	return c, nil
+}
+
+// parseClause 解析单个约束条件
+func parseClause(s string) (versionClause, error) {
+	var clause versionClause
+	for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, op) {
+			clause.op = op
+			s = s[len(op):]
+			break
+		}
+	}
+	if clause.op == "" || clause.op == "==" {
+		clause.op = "="
+	}
+
+	v, err := ParseVersion(s)
+	if err != nil {
+		return versionClause{}, err
+	}
+	clause.version = v
+	return clause, nil
+}
+
+// Check 检查版本是否满足约束
+func (c Constraint) Check(v Version) bool {
+	for _, clause := range c.clauses {
+		if !clause.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String 返回约束的原始字符串
+func (c Constraint) String() string {
+	if c.raw == "" {
+		return "*"
+	}
+	return c.raw
+}
+
+// check 检查版本是否满足单个条件
+func (vc versionClause) check(v Version) bool {
+	cmp := v.Compare(vc.version)
+	switch vc.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "^":
+		// 兼容版本：主版本号相同；主版本号为0时次版本号也必须相同
+		if cmp < 0 || v.Major != vc.version.Major {
+			return false
+		}
+		return vc.version.Major != 0 || v.Minor == vc.version.Minor
+	case "~":
+		// 补丁版本：主版本号和次版本号相同
+		return cmp >= 0 && v.Major == vc.version.Major && v.Minor == vc.version.Minor
+	default:
+		return false
+	}
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: " 1.2.3 ", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "1", want: Version{Major: 1}},
+		{input: "1.2", want: Version{Major: 1, Minor: 2}},
+		{input: "0.0.0", want: Version{}},
+		{input: "1.2.3-beta.1", want: Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "beta.1"}},
+		{input: "1.2.3+build.5", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{input: "1.2.3-rc.1+build.5", want: Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1"}},
+		{input: "", wantErr: true},
+		{input: "v", wantErr: true},
+		{input: "1.2.3.4", wantErr: true},
+		{input: "1..3", wantErr: true},
+		{input: "1.x.3", wantErr: true},
+		{input: "1.2.-3", wantErr: true},
+		{input: "1.2.3-", wantErr: true},
+		{input: "latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseVersion(%q) = %v, expected error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVersion(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.0.0+a", "1.0.0+b", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"1.2.0", "1.10.0", -1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0-rc.1", "1.0.0-rc.1", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, err := ParseVersion(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseVersion(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := b.Compare(a); got != -tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{input: ""},
+		{input: "*"},
+		{input: "1.2.3"},
+		{input: "=1.2.3"},
+		{input: "==1.2.3"},
+		{input: ">=1.0.0, <2.0.0"},
+		{input: ">=1.0.0 <2.0.0"},
+		{input: ">= 1.0.0"},
+		{input: "^1.2"},
+		{input: "~1.2.0"},
+		{input: "!=1.5.0"},
+		{input: ">=", wantErr: true},
+		{input: ">=1.0.0, <", wantErr: true},
+		{input: ">=x", wantErr: true},
+		{input: "^", wantErr: true},
+		{input: "1.2.3.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, err := ParseConstraint(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseConstraint(%q) = %v, expected error", tt.input, c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q): %v", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "0.0.1", true},
+		{"*", "99.0.0", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"=1.2", "1.2.0", true},
+		{"!=1.5.0", "1.5.0", false},
+		{"!=1.5.0", "1.5.1", true},
+		{">1.0.0", "1.0.0", false},
+		{">1.0.0", "1.0.1", true},
+		{">=1.0.0", "1.0.0", true},
+		{">=1.0.0", "1.0.0-rc.1", false},
+		{"<2.0.0", "2.0.0", false},
+		{"<2.0.0", "1.99.99", true},
+		{"<2.0.0", "2.0.0-alpha", true},
+		{"<=2.0.0", "2.0.0", true},
+		{">=1.0.0, <2.0.0", "1.5.0", true},
+		{">=1.0.0, <2.0.0", "2.0.0", false},
+		{">=1.0.0 <2.0.0", "0.9.0", false},
+		{">= 1.0.0", "1.0.0", true},
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "1.2.2", false},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2.3", "1.2.2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+			}
+			v, err := ParseVersion(tt.version)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q): %v", tt.version, err)
+			}
+			if got := c.Check(v); got != tt.want {
+				t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// RuntimeWasm 以WebAssembly（WASI）模块方式在服务器进程内的沙箱中运行
+const RuntimeWasm = "wasm"
+
+const (
+	// WasmDescribeArg 服务器获取wasm插件描述时传给模块的参数，模块将describe结果以JSON写入标准输出
+	WasmDescribeArg = "--sgo-describe"
+	// WasmConfigEnv 服务器通过该环境变量向wasm模块传递插件配置
+	WasmConfigEnv = "SGO_PLUGIN_CONFIG"
+	// WasmDataDir 插件数据目录在wasm模块中的挂载路径
+	WasmDataDir = "/data"
+	// wasmCacheDir wasm模块的编译缓存目录，位于插件目录下，重新加载或重启服务器时不需要重新编译
+	wasmCacheDir = ".wasm-cache"
+)
+
+// WasmSandbox 服务器授予wasm插件的宿主资源，未授予的资源插件无法访问
+type WasmSandbox struct {
+	// Mounts 挂载到模块文件系统中的宿主目录
+	Mounts []WasmMount `json:"mounts,omitempty"`
+	// Env 传给模块的环境变量
+	Env map[string]string `json:"env,omitempty"`
+	// MemoryLimit 模块可以使用的最大内存（MB），0表示使用运行时的默认上限
+	MemoryLimit int `json:"memory_limit,omitempty"`
+}
+
+// WasmMount 挂载到wasm模块中的宿主目录
+type WasmMount struct {
+	// Host 宿主目录
+	Host string `json:"host"`
+	// Guest 模块中的绝对路径
+	Guest string `json:"guest"`
+	// ReadOnly 以只读方式挂载
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// Validate 校验沙箱配置
+func (s WasmSandbox) Validate() error {
+	if s.MemoryLimit < 0 {
+		return fmt.Errorf("invalid memory_limit: %d", s.MemoryLimit)
+	}
+	for _, m := range s.Mounts {
+		if m.Host == "" || !path.IsAbs(m.Guest) {
+			return fmt.Errorf("invalid mount %q -> %q: host directory and absolute guest path are required", m.Host, m.Guest)
+		}
+		if path.Clean(m.Guest) == WasmDataDir {
+			return fmt.Errorf("invalid mount %q: %s is reserved for the plugin data directory", m.Host, WasmDataDir)
+		}
+	}
+	return nil
+}
+
+// wasmCompilationCache 返回wasm模块的编译缓存，缓存按模块内容区分，升级后的模块会重新编译
+func (pm *DefaultPluginManager) wasmCompilationCache() wazero.CompilationCache {
+	pm.wasmCacheOnce.Do(func() {
+		cache, err := wazero.NewCompilationCacheWithDir(filepath.Join(pm.pluginsDir, wasmCacheDir))
+		if err != nil {
+			log.Printf("Warning: failed to use wasm compilation cache directory, caching in memory: %v", err)
+			cache = wazero.NewCompilationCache()
+		}
+		pm.wasmCache = cache
+	})
+	return pm.wasmCache
+}
+
+// wasmPlugin 以WASI模块方式运行的命令类插件。模块编译后常驻内存，每次执行命令创建新的实例，
+// 命令参数作为模块的命令行参数，输入输出对应模块的标准输入输出，退出状态即命令的退出状态。
+// 插件卸载时释放编译的模块，可以在运行中卸载和升级
+type wasmPlugin struct {
+	metadata PluginMetadata
+	wasm     []byte
+	cache    wazero.CompilationCache
+	sandbox  func() WasmSandbox
+
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	desc     describeResult
+	state    PluginState
+	config   []byte
+	dataDir  string
+}
+
+// newWasmPlugin 读取wasm模块，sandbox返回服务器当前授予插件的资源
+func newWasmPlugin(metadata PluginMetadata, path string, cache wazero.CompilationCache, sandbox func() WasmSandbox) (Plugin, error) {
+	if metadata.Type != CommandPlugin {
+		return nil, fmt.Errorf("%w: wasm plugins must be command plugins", ErrPluginTypeMismatch)
+	}
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wasm module: %w", err)
+	}
+	return &wasmPlugin{
+		metadata: metadata,
+		wasm:     wasm,
+		cache:    cache,
+		sandbox:  sandbox,
+		state:    Disabled,
+	}, nil
+}
+
+// ID 返回插件唯一标识
+func (p *wasmPlugin) ID() string {
+	return p.metadata.ID
+}
+
+// Name 返回插件名称
+func (p *wasmPlugin) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.Name != "" {
+		return p.desc.Name
+	}
+	return p.metadata.Name
+}
+
+// Version 返回插件版本
+func (p *wasmPlugin) Version() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.Version != "" {
+		return p.desc.Version
+	}
+	return p.metadata.Version
+}
+
+// Type 返回插件类型
+func (p *wasmPlugin) Type() PluginType {
+	return CommandPlugin
+}
+
+// State 返回插件当前状态
+func (p *wasmPlugin) State() PluginState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// SetState 设置插件状态
+func (p *wasmPlugin) SetState(state PluginState) error {
+	if state == Running || state == Paused {
+		return ErrPluginTypeMismatch
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = state
+	return nil
+}
+
+// Init 编译模块并获取插件描述
+func (p *wasmPlugin) Init(ctx context.Context, config []byte) error {
+	var dataDir string
+	if host, ok := HostFromContext(ctx); ok {
+		dataDir = host.DataDir()
+	}
+
+	// 运行时只在Init时创建，内存上限的修改在重新加载插件后生效
+	runtimeConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithCompilationCache(p.cache)
+	if limit := p.sandbox().MemoryLimit; limit > 0 {
+		// 每页64KB
+		runtimeConfig = runtimeConfig.WithMemoryLimitPages(uint32(limit) * 16)
+	}
+	runtime := wazero.NewRuntimeWithConfig(context.Background(), runtimeConfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(context.Background(), runtime); err != nil {
+		runtime.Close(context.Background())
+		return fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	compiled, err := runtime.CompileModule(context.Background(), p.wasm)
+	if err != nil {
+		runtime.Close(context.Background())
+		return fmt.Errorf("failed to compile wasm module: %w", err)
+	}
+
+	p.mu.Lock()
+	p.runtime = runtime
+	p.compiled = compiled
+	p.config = config
+	p.dataDir = dataDir
+	p.mu.Unlock()
+
+	var out bytes.Buffer
+	describeCtx, cancel := context.WithTimeout(ctx, processCallTimeout)
+	defer cancel()
+	if err := p.run(describeCtx, []string{WasmDescribeArg}, nil, &out); err != nil {
+		p.Cleanup()
+		return fmt.Errorf("failed to describe wasm plugin: %w", err)
+	}
+	var desc describeResult
+	if err := json.Unmarshal(out.Bytes(), &desc); err != nil {
+		p.Cleanup()
+		return fmt.Errorf("invalid describe output of wasm plugin: %w", err)
+	}
+	if desc.ID != "" && desc.ID != p.metadata.ID {
+		p.Cleanup()
+		return fmt.Errorf("wasm plugin id %s does not match metadata id %s", desc.ID, p.metadata.ID)
+	}
+
+	p.mu.Lock()
+	p.desc = desc
+	p.mu.Unlock()
+	return nil
+}
+
+// Cleanup 释放运行时和编译的模块
+func (p *wasmPlugin) Cleanup() error {
+	p.mu.Lock()
+	runtime := p.runtime
+	p.runtime = nil
+	p.compiled = nil
+	p.mu.Unlock()
+
+	if runtime == nil {
+		return nil
+	}
+	return runtime.Close(context.Background())
+}
+
+// CommandType 返回模块描述的命令类型
+func (p *wasmPlugin) CommandType() CommandType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.CommandType
+}
+
+// GetCommands 返回模块描述的命令列表
+func (p *wasmPlugin) GetCommands() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.Commands
+}
+
+// DescribeCommands 返回模块提供的命令描述
+func (p *wasmPlugin) DescribeCommands() []CommandDescriptor {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.desc.Descriptors
+}
+
+// ConfigSchema 返回模块发布的配置结构
+func (p *wasmPlugin) ConfigSchema() ConfigSchema {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.desc.ConfigSchema == nil {
+		return ConfigSchema{}
+	}
+	return *p.desc.ConfigSchema
+}
+
+// Reconfigure 保存新配置，每次执行命令都创建新的实例，新配置从下一条命令开始生效
+func (p *wasmPlugin) Reconfigure(ctx context.Context, config []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+	return nil
+}
+
+// Execute 在新的模块实例中执行命令
+func (p *wasmPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+	return p.run(ctx, args, input, output)
+}
+
+// run 以args为命令行参数运行模块，ctx取消时模块随之终止
+func (p *wasmPlugin) run(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
+	p.mu.Lock()
+	runtime, compiled := p.runtime, p.compiled
+	config, dataDir := p.config, p.dataDir
+	p.mu.Unlock()
+
+	if runtime == nil {
+		return fmt.Errorf("%w: %s", ErrPluginDisabled, p.metadata.ID)
+	}
+	if input == nil {
+		input = bytes.NewReader(nil)
+	}
+	if output == nil {
+		output = io.Discard
+	}
+
+	sandbox := p.sandbox()
+	fsConfig := wazero.NewFSConfig()
+	if dataDir != "" {
+		fsConfig = fsConfig.WithDirMount(dataDir, WasmDataDir)
+	}
+	for _, m := range sandbox.Mounts {
+		if m.ReadOnly {
+			fsConfig = fsConfig.WithReadOnlyDirMount(m.Host, m.Guest)
+		} else {
+			fsConfig = fsConfig.WithDirMount(m.Host, m.Guest)
+		}
+	}
+
+	// 标准错误输出记录到日志，最后一行作为失败时的错误信息
+	stderr := &lastLineWriter{w: newLogWriter(p.metadata.ID)}
+
+	// 模块名为空，同一模块可以同时运行多个实例
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{p.metadata.ID}, args...)...).
+		WithStdin(input).
+		WithStdout(output).
+		WithStderr(stderr).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	for k, v := range sandbox.Env {
+		moduleConfig = moduleConfig.WithEnv(k, v)
+	}
+	if len(config) > 0 {
+		moduleConfig = moduleConfig.WithEnv(WasmConfigEnv, string(config))
+	}
+
+	mod, err := runtime.InstantiateModule(ctx, compiled, moduleConfig)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case sys.ExitCodeContextCanceled, sys.ExitCodeDeadlineExceeded:
+			return ctx.Err()
+		default:
+			message := stderr.last()
+			if message == "" {
+				message = fmt.Sprintf("exit status %d", exitErr.ExitCode())
+			}
+			return &ExitError{Code: int(exitErr.ExitCode()), Message: message}
+		}
+	}
+	return err
+}
+
+// lastLineWriter 转发写入的数据并保留最后一个非空行
+type lastLineWriter struct {
+	w    io.Writer
+	mu   sync.Mutex
+	line []byte
+	buf  []byte
+}
+
+// Write 转发数据并记录最后一行
+func (l *lastLineWriter) Write(data []byte) (int, error) {
+	l.mu.Lock()
+	l.buf = append(l.buf, data...)
+	for {
+		idx := bytes.IndexByte(l.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(l.buf[:idx]); len(line) > 0 {
+			l.line = append(l.line[:0], line...)
+		}
+		l.buf = l.buf[idx+1:]
+	}
+	l.mu.Unlock()
+	return l.w.Write(data)
+}
+
+// last 返回最后一个非空行，未以换行结束的内容也计算在内
+func (l *lastLineWriter) last() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line := bytes.TrimSpace(l.buf); len(line) > 0 {
+		return string(line)
+	}
+	return string(l.line)
+}
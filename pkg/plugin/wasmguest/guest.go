@@ -0,0 +1,83 @@
+// Package wasmguest 用于编写以wasm方式运行的命令插件，只依赖标准库。
+// 插件使用GOOS=wasip1 GOARCH=wasm（Go 1.21及以上）编译为WASI模块，元数据中声明runtime: wasm
+package wasmguest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// describeArg 服务器获取插件描述时传入的参数，与plugin.WasmDescribeArg相同
+	describeArg = "--sgo-describe"
+	// configEnv 服务器传递插件配置的环境变量，与plugin.WasmConfigEnv相同
+	configEnv = "SGO_PLUGIN_CONFIG"
+)
+
+// DataDir 插件数据目录在模块中的路径
+const DataDir = "/data"
+
+// Info 插件描述，对应服务器中插件的GetCommands、DescribeCommands和ConfigSchema
+type Info struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// CommandType 命令类型：0为一次性命令，1为交互式命令
+	CommandType int      `json:"command_type"`
+	Commands    []string `json:"commands"`
+	// Descriptors 命令描述，格式与plugin.CommandDescriptor的JSON相同
+	Descriptors interface{} `json:"descriptors,omitempty"`
+	// ConfigSchema 配置结构，格式与plugin.ConfigSchema的JSON相同
+	ConfigSchema interface{} `json:"config_schema,omitempty"`
+}
+
+// Handler 执行命令，args[0]为命令名，输入输出由服务器与客户端连接
+type Handler func(args []string, stdin io.Reader, stdout io.Writer) error
+
+// ExitError 带退出状态的错误，作为命令的退出状态返回给客户端
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+// Error 返回错误信息
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+// Run 运行插件：服务器获取描述时输出info，否则执行命令。返回错误时错误信息写入标准错误输出，
+// 服务器将其记录到日志并作为命令的错误返回给客户端，退出状态为ExitError的Code或1
+func Run(info Info, handler Handler) {
+	args := os.Args[1:]
+	if len(args) == 1 && args[0] == describeArg {
+		if err := json.NewEncoder(os.Stdout).Encode(info); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode plugin info: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "no command specified")
+		os.Exit(1)
+	}
+
+	if err := handler(args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) && exitErr.Code != 0 {
+			os.Exit(exitErr.Code)
+		}
+		os.Exit(1)
+	}
+}
+
+// Config 返回服务器传入的插件配置，未配置时返回nil
+func Config() []byte {
+	if config := os.Getenv(configEnv); config != "" {
+		return []byte(config)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"io"
+
+	"github.com/sorc/tcpserver/pkg/schema"
 )
 
 // MessageType 定义消息类型
@@ -18,7 +20,7 @@ const (
 	CommandRequest
 	// CommandResponse 命令响应
 	CommandResponse
-	// DataStream 数据流
+	// DataStream 数据流，服务器发送命令输出；客户端在Input为true的命令请求之后发送命令输入，空消息表示输入结束
 	DataStream
 	// ErrorResponse 错误响应
 	ErrorResponse
@@ -26,6 +28,19 @@ const (
 	HeartbeatRequest
 	// HeartbeatResponse 心跳响应
 	HeartbeatResponse
+	// AgentListRequest 代理端列表请求
+	AgentListRequest
+	// AgentListResponse 代理端列表响应
+	AgentListResponse
+	// DescribeRequest 命令描述请求
+	DescribeRequest
+	// DescribeResponse 命令描述响应
+	DescribeResponse
+)
+
+const (
+	// RoleAgent 代理端角色，代理端主动连接中心节点并接收其下发的命令
+	RoleAgent = "agent"
 )
 
 // Header 消息头
@@ -48,6 +63,7 @@ type AuthRequestBody struct {
 	Nonce     string `json:"nonce"`
 	Timestamp int64  `json:"timestamp"`
 	Signature string `json:"signature"`
+	Role      string `json:"role,omitempty"`
 }
 
 // AuthResponseBody 认证响应体
@@ -63,6 +79,10 @@ type CommandRequestBody struct {
 	Command     string   `json:"command"`
 	Args        []string `json:"args,omitempty"`
 	Interactive bool     `json:"interactive"`
+	// Agent 目标代理端ID，为空时在当前服务器执行
+	Agent string `json:"agent,omitempty"`
+	// Input 客户端随后以数据流消息发送命令输入，以空的数据流消息结束
+	Input bool `json:"input,omitempty"`
 }
 
 // CommandResponseBody 命令响应体
@@ -70,6 +90,8 @@ type CommandResponseBody struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
 	Data    []byte `json:"data,omitempty"`
+	// ExitCode 命令的退出状态，仅在插件执行外部程序失败时设置
+	ExitCode int `json:"exit_code,omitempty"`
 }
 
 // ErrorResponseBody 错误响应体
@@ -89,6 +111,39 @@ type HeartbeatResponseBody struct {
 	ServerLoad float64 `json:"server_load"`
 }
 
+// AgentInfo 代理端信息
+type AgentInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	RemoteAddr  string `json:"remote_addr"`
+	ConnectedAt int64  `json:"connected_at"`
+	LastSeen    int64  `json:"last_seen"`
+}
+
+// AgentListResponseBody 代理端列表响应体
+type AgentListResponseBody struct {
+	Agents []AgentInfo `json:"agents"`
+}
+
+// DescribeRequestBody 命令描述请求体
+type DescribeRequestBody struct {
+	// Plugin 插件ID，为空时返回客户端有权使用的所有插件
+	Plugin string `json:"plugin,omitempty"`
+}
+
+// PluginDescription 插件及其命令的描述
+type PluginDescription struct {
+	ID       string                     `json:"id"`
+	Name     string                     `json:"name"`
+	Version  string                     `json:"version"`
+	Commands []schema.CommandDescriptor `json:"commands"`
+}
+
+// DescribeResponseBody 命令描述响应体
+type DescribeResponseBody struct {
+	Plugins []PluginDescription `json:"plugins"`
+}
+
 // ReadMessage 从连接中读取消息
 func ReadMessage(r io.Reader) (*Message, error) {
 	// 读取消息头长度
@@ -177,6 +232,24 @@ func NewAuthRequestMessage(requestID string, clientID, nonce string, timestamp i
 	return NewMessage(AuthRequest, requestID, bodyBytes, encrypted), nil
 }
 
+// NewAgentAuthRequestMessage 创建代理端认证请求消息
+func NewAgentAuthRequestMessage(requestID string, clientID, nonce string, timestamp int64, signature string) (*Message, error) {
+	body := AuthRequestBody{
+		ClientID:  clientID,
+		Nonce:     nonce,
+		Timestamp: timestamp,
+		Signature: signature,
+		Role:      RoleAgent,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(AuthRequest, requestID, bodyBytes, false), nil
+}
+
 // NewAuthResponseMessage 创建认证响应消息
 func NewAuthResponseMessage(requestID string, success bool, sessionID, message string, encrypted bool) (*Message, error) {
 	body := AuthResponseBody{
@@ -210,6 +283,42 @@ func NewCommandRequestMessage(requestID string, plugin, command string, args []s
 	return NewMessage(CommandRequest, requestID, bodyBytes, encrypted), nil
 }
 
+// NewAgentCommandRequestMessage 创建路由到代理端的命令请求消息
+func NewAgentCommandRequestMessage(requestID string, agent, plugin, command string, args []string, interactive bool, encrypted bool) (*Message, error) {
+	body := CommandRequestBody{
+		Plugin:      plugin,
+		Command:     command,
+		Args:        args,
+		Interactive: interactive,
+		Agent:       agent,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(CommandRequest, requestID, bodyBytes, encrypted), nil
+}
+
+// NewInputCommandRequestMessage 创建带输入的命令请求消息，发送后以数据流消息发送命令输入，以空消息结束
+func NewInputCommandRequestMessage(requestID string, agent, plugin, command string, args []string, encrypted bool) (*Message, error) {
+	body := CommandRequestBody{
+		Plugin:  plugin,
+		Command: command,
+		Args:    args,
+		Agent:   agent,
+		Input:   true,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(CommandRequest, requestID, bodyBytes, encrypted), nil
+}
+
 // NewCommandResponseMessage 创建命令响应消息
 func NewCommandResponseMessage(requestID string, success bool, message string, data []byte, encrypted bool) (*Message, error) {
 	body := CommandResponseBody{
@@ -226,6 +335,22 @@ func NewCommandResponseMessage(requestID string, success bool, message string, d
 	return NewMessage(CommandResponse, requestID, bodyBytes, encrypted), nil
 }
 
+// NewCommandExitResponseMessage 创建带退出状态的命令响应消息
+func NewCommandExitResponseMessage(requestID string, success bool, message string, exitCode int, encrypted bool) (*Message, error) {
+	body := CommandResponseBody{
+		Success:  success,
+		Message:  message,
+		ExitCode: exitCode,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(CommandResponse, requestID, bodyBytes, encrypted), nil
+}
+
 // NewErrorResponseMessage 创建错误响应消息
 func NewErrorResponseMessage(requestID string, code int, message string, encrypted bool) (*Message, error) {
 	body := ErrorResponseBody{
@@ -274,3 +399,46 @@ func NewHeartbeatResponseMessage(requestID string, timestamp int64, serverLoad f
 
 	return NewMessage(HeartbeatResponse, requestID, bodyBytes, encrypted), nil
 }
+
+// NewAgentListRequestMessage 创建代理端列表请求消息
+func NewAgentListRequestMessage(requestID string, encrypted bool) *Message {
+	return NewMessage(AgentListRequest, requestID, []byte("{}"), encrypted)
+}
+
+// NewAgentListResponseMessage 创建代理端列表响应消息
+func NewAgentListResponseMessage(requestID string, agents []AgentInfo, encrypted bool) (*Message, error) {
+	body := AgentListResponseBody{
+		Agents: agents,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(AgentListResponse, requestID, bodyBytes, encrypted), nil
+}
+
+// NewDescribeRequestMessage 创建命令描述请求消息，plugin为空时请求所有插件
+func NewDescribeRequestMessage(requestID string, pluginID string, encrypted bool) (*Message, error) {
+	bodyBytes, err := json.Marshal(DescribeRequestBody{Plugin: pluginID})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(DescribeRequest, requestID, bodyBytes, encrypted), nil
+}
+
+// NewDescribeResponseMessage 创建命令描述响应消息
+func NewDescribeResponseMessage(requestID string, plugins []PluginDescription, encrypted bool) (*Message, error) {
+	body := DescribeResponseBody{
+		Plugins: plugins,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMessage(DescribeResponse, requestID, bodyBytes, encrypted), nil
+}
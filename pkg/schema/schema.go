@@ -0,0 +1,105 @@
+package schema
+
+import "strings"
+
+// 本包只包含插件配置字段和命令的结构描述，供插件运行时和通信协议共同使用，不依赖其他包
+
+// 配置字段类型
+const (
+	FieldString   = "string"
+	FieldInt      = "int"
+	FieldFloat    = "float"
+	FieldBool     = "bool"
+	FieldDuration = "duration" // Go时间格式字符串，如30s、5m
+	FieldAddr     = "addr"     // host:port格式的监听或连接地址
+	FieldList     = "list"
+	FieldMap      = "map"
+)
+
+// 命令输出格式
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// ConfigField 配置字段
+type ConfigField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// Enum 字符串字段允许的取值，为空时不限制
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// CommandArg 命令的位置参数
+type CommandArg struct {
+	Name string `json:"name"`
+	// Type 参数类型，取值同配置字段类型（FieldString、FieldInt等）
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	Default  string `json:"default,omitempty"`
+	// Enum 允许的取值，为空时不限制
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+	// Variadic 最后一个参数可以重复，接收剩余的所有参数
+	Variadic bool `json:"variadic,omitempty"`
+}
+
+// CommandFlag 命令的选项，如--force
+type CommandFlag struct {
+	// Name 选项名称，不含前缀--
+	Name string `json:"name"`
+	// Short 单字母简写，不含前缀-
+	Short string `json:"short,omitempty"`
+	// Type 选项值的类型，FieldBool表示不带值的开关
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CommandOutput 命令输出的结构
+type CommandOutput struct {
+	// Format 输出格式：text或json
+	Format string `json:"format"`
+	// Fields json输出的字段
+	Fields      []ConfigField `json:"fields,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// CommandDescriptor 命令的结构化描述
+type CommandDescriptor struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Args        []CommandArg  `json:"args,omitempty"`
+	Flags       []CommandFlag `json:"flags,omitempty"`
+	Output      CommandOutput `json:"output"`
+	// Interactive 交互式命令，执行期间持续读取客户端输入
+	Interactive bool `json:"interactive,omitempty"`
+	// Permissions 执行命令需要的权限，如plugin:manage，服务器在执行命令前检查
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Usage 返回命令的用法，如install <plugin_path> [--force]
+func (d CommandDescriptor) Usage() string {
+	parts := []string{d.Name}
+	for _, arg := range d.Args {
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		if arg.Required {
+			parts = append(parts, "<"+name+">")
+		} else {
+			parts = append(parts, "["+name+"]")
+		}
+	}
+	for _, flag := range d.Flags {
+		if flag.Type == FieldBool {
+			parts = append(parts, "[--"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[--"+flag.Name+" <"+flag.Type+">]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,124 @@
+// Package checksum 计算文件和输入数据校验和的wasm插件示例，
+// 只能访问服务器在sandbox中授予的目录和插件自己的数据目录
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/sorc/tcpserver/pkg/plugin/wasmguest"
+)
+
+// Info 插件描述
+var Info = wasmguest.Info{
+	ID:       "checksum",
+	Name:     "Checksum",
+	Version:  "1.0.0",
+	Commands: []string{"sum", "file"},
+	Descriptors: []map[string]interface{}{
+		{
+			"name":        "sum",
+			"description": "Checksum the command input",
+			"args":        []map[string]interface{}{algorithmArg},
+			"output":      map[string]interface{}{"format": "text"},
+		},
+		{
+			"name":        "file",
+			"description": "Checksum files in directories granted to the plugin",
+			"args": []map[string]interface{}{
+				{"name": "path", "type": "string", "required": true, "variadic": true},
+			},
+			"flags": []map[string]interface{}{
+				{"name": "algorithm", "short": "a", "type": "string", "default": "sha256"},
+			},
+			"output": map[string]interface{}{"format": "text"},
+		},
+	},
+}
+
+// algorithmArg 校验和算法参数
+var algorithmArg = map[string]interface{}{
+	"name":    "algorithm",
+	"type":    "string",
+	"default": "sha256",
+	"enum":    []string{"md5", "sha1", "sha256"},
+}
+
+// Handle 执行命令
+func Handle(args []string, stdin io.Reader, stdout io.Writer) error {
+	switch args[0] {
+	case "sum":
+		algorithm := "sha256"
+		if len(args) > 1 {
+			algorithm = args[1]
+		}
+		h, err := newHash(algorithm)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(h, stdin); err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		fmt.Fprintln(stdout, hex.EncodeToString(h.Sum(nil)))
+		return nil
+	case "file":
+		return sumFiles(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// sumFiles 计算文件的校验和，输出格式与sha256sum相同
+func sumFiles(args []string, stdout io.Writer) error {
+	algorithm := "sha256"
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "--algorithm" || args[i] == "-a") && i+1 < len(args) {
+			algorithm = args[i+1]
+			i++
+			continue
+		}
+		paths = append(paths, args[i])
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("usage: file [--algorithm md5|sha1|sha256] <path>...")
+	}
+
+	for _, path := range paths {
+		h, err := newHash(algorithm)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		fmt.Fprintf(stdout, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), path)
+	}
+	return nil
+}
+
+// newHash 创建校验和算法
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown algorithm: %s", algorithm)
+	}
+}
@@ -0,0 +1,13 @@
+// checksum插件的构建入口，编译为wasm插件（需要Go 1.21及以上）：
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o plugins/checksum.wasm ./plugins/checksum/cmd
+package main
+
+import (
+	"github.com/sorc/tcpserver/pkg/plugin/wasmguest"
+	"github.com/sorc/tcpserver/plugins/checksum"
+)
+
+func main() {
+	wasmguest.Run(checksum.Info, checksum.Handle)
+}
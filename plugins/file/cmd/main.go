@@ -0,0 +1,28 @@
+// file插件的独立构建入口：
+//   - 动态插件：go build -buildmode=plugin -o plugins/file.so ./plugins/file/cmd
+//   - 进程插件：go build -o plugins/file ./plugins/file/cmd
+package main
+
+import (
+	"log"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+	"github.com/sorc/tcpserver/plugins/file"
+)
+
+// CreateCommandPlugin 创建命令类插件实例
+func CreateCommandPlugin() plugin.ICommandPlugin {
+	return file.CreateCommandPlugin()
+}
+
+// CreatePlugin 创建插件实例
+func CreatePlugin() plugin.Plugin {
+	return file.CreatePlugin()
+}
+
+// main 作为进程插件运行，编译为.so时不会执行
+func main() {
+	if err := plugin.Serve(CreatePlugin()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package file
 
 import (
 	"context"
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/sorc/tcpserver/pkg/plugin"
 	"gopkg.in/yaml.v3"
 )
 
@@ -51,6 +52,78 @@ func (p *FileTransferPlugin) GetCommands() []string {
 	}
 }
 
+// DescribeCommands 返回命令的参数、选项和输出描述
+func (p *FileTransferPlugin) DescribeCommands() []plugin.CommandDescriptor {
+	fileInfo := plugin.CommandOutput{
+		Format: plugin.OutputJSON,
+		Fields: []plugin.ConfigField{
+			{Name: "path", Type: plugin.FieldString},
+			{Name: "size", Type: plugin.FieldInt},
+			{Name: "mode", Type: plugin.FieldInt},
+			{Name: "mod_time", Type: plugin.FieldString},
+			{Name: "is_dir", Type: plugin.FieldBool},
+			{Name: "md5", Type: plugin.FieldString},
+		},
+		Description: "List of file entries",
+	}
+	return []plugin.CommandDescriptor{
+		{
+			Name:        "upload",
+			Description: "Upload a file or directory",
+			Args: []plugin.CommandArg{
+				{Name: "local_path", Type: plugin.FieldString, Required: true},
+				{Name: "remote_path", Type: plugin.FieldString, Required: true},
+			},
+			Flags: []plugin.CommandFlag{
+				{Name: "compress", Type: plugin.FieldBool, Description: "Compress data in transit"},
+				{Name: "decompress", Type: plugin.FieldBool, Description: "Extract the uploaded archive"},
+				{Name: "resume", Type: plugin.FieldBool, Description: "Resume an interrupted upload"},
+				{Name: "overwrite", Type: plugin.FieldBool, Description: "Overwrite an existing file (default behaviour)"},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputText, Description: "Progress messages followed by a JSON result"},
+		},
+		{
+			Name:        "download",
+			Description: "Download a file or directory",
+			Args: []plugin.CommandArg{
+				{Name: "remote_path", Type: plugin.FieldString, Required: true},
+				{Name: "local_path", Type: plugin.FieldString, Required: true},
+			},
+			Flags: []plugin.CommandFlag{
+				{Name: "compress", Type: plugin.FieldBool, Description: "Compress data in transit"},
+				{Name: "decompress", Type: plugin.FieldBool, Description: "Decompress the downloaded data"},
+				{Name: "recursive", Type: plugin.FieldBool, Description: "Download a directory recursively"},
+				{Name: "offset", Type: plugin.FieldInt, Default: "0", Description: "Byte offset to resume from"},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputText, Description: "File data stream"},
+		},
+		{
+			Name:        "list",
+			Description: "List files",
+			Args: []plugin.CommandArg{
+				{Name: "path", Type: plugin.FieldString, Default: "."},
+			},
+			Output: fileInfo,
+		},
+		{
+			Name:        "delete",
+			Description: "Delete a file or directory",
+			Args: []plugin.CommandArg{
+				{Name: "path", Type: plugin.FieldString, Required: true},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+		{
+			Name:        "mkdir",
+			Description: "Create a directory",
+			Args: []plugin.CommandArg{
+				{Name: "path", Type: plugin.FieldString, Required: true},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+	}
+}
+
 // Execute 执行命令
 func (p *FileTransferPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if len(args) == 0 {
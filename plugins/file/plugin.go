@@ -1,9 +1,19 @@
-package main
+package file
 
 import (
 	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
+func init() {
+	plugin.Register(plugin.PluginMetadata{
+		ID:          "file",
+		Name:        "File Transfer",
+		Version:     "1.0.0",
+		Type:        plugin.CommandPlugin,
+		Description: "文件上传下载、断点续传和压缩传输",
+	}, CreatePlugin)
+}
+
 // CreateCommandPlugin 创建命令类插件实例
 func CreateCommandPlugin() plugin.ICommandPlugin {
 	return &FileTransferPlugin{
@@ -15,5 +25,3 @@ func CreateCommandPlugin() plugin.ICommandPlugin {
 func CreatePlugin() plugin.Plugin {
 	return CreateCommandPlugin()
 }
-
-func main() {}
@@ -0,0 +1,20 @@
+// 将manager插件编译为.so动态插件：go build -buildmode=plugin -o plugins/manager.so ./plugins/manager/cmd
+package main
+
+import (
+	"github.com/sorc/tcpserver/pkg/plugin"
+	"github.com/sorc/tcpserver/plugins/manager"
+)
+
+// CreateCommandPlugin 创建命令类插件实例
+func CreateCommandPlugin() plugin.ICommandPlugin {
+	return manager.CreateCommandPlugin()
+}
+
+// CreatePlugin 创建插件实例
+func CreatePlugin() plugin.Plugin {
+	return manager.CreatePlugin()
+}
+
+// main 插件管理插件需要访问服务器进程中的插件管理器，只能编译为.so或静态编译，不支持进程插件
+func main() {}
@@ -1,9 +1,11 @@
-package main
+package manager
 
 import (
 	"context"
 	"fmt"
 	"io"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
 // GetCommands 获取支持的命令列表
@@ -15,12 +17,128 @@ func (p *PluginManagerPlugin) GetCommands() []string {
 		"enable",
 		"disable",
 		"upgrade",
+		"search",
+		"outdated",
+		"history",
+		"audit",
+		"interceptors",
 		"info",
 		"start",
 		"stop",
 		"restart",
+		"pause",
+		"resume",
 		"status",
 		"config",
+		"metrics",
+		"health",
+	}
+}
+
+// 命令需要的权限，与服务器的权限名称一致
+const (
+	permPluginManage  = "plugin:manage"
+	permServiceManage = "service:manage"
+)
+
+// DescribeCommands 返回命令的参数、选项和权限描述
+func (p *PluginManagerPlugin) DescribeCommands() []plugin.CommandDescriptor {
+	pluginID := plugin.CommandArg{Name: "plugin_id", Type: plugin.FieldString, Required: true}
+	optionalID := plugin.CommandArg{Name: "plugin_id", Type: plugin.FieldString, Description: "All plugins when omitted"}
+	force := plugin.CommandFlag{Name: "force", Short: "f", Type: plugin.FieldBool, Description: "Proceed even if other plugins depend on it"}
+	pluginPath := plugin.CommandArg{Name: "plugin_path", Type: plugin.FieldString, Description: "Package or plugin file on the server, or <plugin_id>[@<version>] from the repository, omitted with --upload"}
+	upgradePath := plugin.CommandArg{Name: "plugin_path", Type: plugin.FieldString, Description: "Package or plugin file on the server, omitted with --upload or to upgrade from the repository"}
+	upload := []plugin.CommandFlag{
+		{Name: "upload", Type: plugin.FieldString, Description: "Package (.tar.gz) streamed from the client as command input"},
+		{Name: "size", Type: plugin.FieldInt, Description: "Size of the uploaded package in bytes"},
+		{Name: "sha256", Type: plugin.FieldString, Description: "SHA-256 checksum of the uploaded package"},
+	}
+	pluginManage := []string{permPluginManage}
+	serviceManage := []string{permServiceManage}
+	text := plugin.CommandOutput{Format: plugin.OutputText}
+
+	return []plugin.CommandDescriptor{
+		{Name: "list", Description: "List installed plugins", Output: text},
+		{
+			Name:        "install",
+			Description: "Install a plugin package (.tar.gz), plugin file or plugin from the repository",
+			Args:        []plugin.CommandArg{pluginPath},
+			Flags:       upload,
+			Output:      text,
+			Permissions: pluginManage,
+		},
+		{
+			Name:        "uninstall",
+			Description: "Uninstall a plugin",
+			Args:        []plugin.CommandArg{pluginID},
+			Flags:       []plugin.CommandFlag{force},
+			Output:      text,
+			Permissions: pluginManage,
+		},
+		{Name: "enable", Description: "Enable a plugin", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: pluginManage},
+		{
+			Name:        "disable",
+			Description: "Disable a plugin",
+			Args:        []plugin.CommandArg{pluginID},
+			Flags:       []plugin.CommandFlag{force},
+			Output:      text,
+			Permissions: pluginManage,
+		},
+		{
+			Name:        "upgrade",
+			Description: "Upgrade a plugin, rolling back on failure",
+			Args:        []plugin.CommandArg{{Name: "plugin_id", Type: plugin.FieldString, Required: true, Description: "<plugin_id>[@<version>] when upgrading from the repository"}, upgradePath},
+			Flags: append([]plugin.CommandFlag{
+				{Name: "health-check", Type: plugin.FieldInt, Default: "0", Description: "Seconds the new version must keep running"},
+			}, upload...),
+			Output:      text,
+			Permissions: pluginManage,
+		},
+		{
+			Name:        "search",
+			Description: "Search the plugin repository",
+			Args:        []plugin.CommandArg{{Name: "query", Type: plugin.FieldString, Description: "All plugins when omitted"}},
+			Output:      text,
+		},
+		{Name: "outdated", Description: "List installed plugins with newer compatible versions in the repository", Output: text},
+		{Name: "history", Description: "Show upgrade history", Args: []plugin.CommandArg{optionalID}, Output: text},
+		{
+			Name:        "audit",
+			Description: "Show audited command executions, including plugin-to-plugin invocations",
+			Args:        []plugin.CommandArg{optionalID},
+			Flags:       []plugin.CommandFlag{{Name: "limit", Type: plugin.FieldInt, Default: "50", Description: "Number of most recent records, 0 for all"}},
+			Output:      text,
+			Permissions: pluginManage,
+		},
+		{Name: "interceptors", Description: "List command interceptors in execution order", Output: text},
+		{Name: "info", Description: "Show plugin information", Args: []plugin.CommandArg{pluginID}, Output: text},
+		{Name: "start", Description: "Start a service", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: serviceManage},
+		{Name: "stop", Description: "Stop a service", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: serviceManage},
+		{Name: "restart", Description: "Restart a service", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: serviceManage},
+		{Name: "pause", Description: "Pause a service, refusing new connections but keeping existing ones", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: serviceManage},
+		{Name: "resume", Description: "Resume a paused service", Args: []plugin.CommandArg{pluginID}, Output: text, Permissions: serviceManage},
+		{Name: "status", Description: "Show service status", Args: []plugin.CommandArg{optionalID}, Output: text},
+		{
+			Name:        "config",
+			Description: "Show or update plugin configuration",
+			Args:        []plugin.CommandArg{pluginID, {Name: "config_file", Type: plugin.FieldString, Description: "Show current configuration when omitted"}},
+			Flags:       []plugin.CommandFlag{{Name: "schema", Type: plugin.FieldBool, Description: "Show the configuration schema"}},
+			Output:      text,
+			Permissions: serviceManage,
+		},
+		{
+			Name:        "metrics",
+			Description: "Show metrics registered by plugins",
+			Args:        []plugin.CommandArg{optionalID},
+			Output:      text,
+		},
+		{
+			Name:        "health",
+			Description: "Show plugin health, as reported by health checks or derived from plugin state",
+			Args:        []plugin.CommandArg{{Name: "plugin_id", Type: plugin.FieldString, Description: "All enabled plugins when omitted"}},
+			Flags:       []plugin.CommandFlag{{Name: "json", Type: plugin.FieldBool, Description: "Print the full report as JSON"}},
+			Output:      text,
+		},
 	}
 }
 
@@ -37,7 +155,7 @@ func (p *PluginManagerPlugin) Execute(ctx context.Context, args []string, input
 	case "list":
 		return p.listPlugins(ctx, cmdArgs, output)
 	case "install":
-		return p.installPlugin(ctx, cmdArgs, output)
+		return p.installPlugin(ctx, cmdArgs, input, output)
 	case "uninstall":
 		return p.uninstallPlugin(ctx, cmdArgs, output)
 	case "enable":
@@ -45,7 +163,17 @@ func (p *PluginManagerPlugin) Execute(ctx context.Context, args []string, input
 	case "disable":
 		return p.disablePlugin(ctx, cmdArgs, output)
 	case "upgrade":
-		return p.upgradePlugin(ctx, cmdArgs, output)
+		return p.upgradePlugin(ctx, cmdArgs, input, output)
+	case "search":
+		return p.searchPlugins(ctx, cmdArgs, output)
+	case "outdated":
+		return p.outdatedPlugins(ctx, cmdArgs, output)
+	case "history":
+		return p.upgradeHistory(ctx, cmdArgs, output)
+	case "audit":
+		return p.auditLog(ctx, cmdArgs, output)
+	case "interceptors":
+		return p.listInterceptors(ctx, cmdArgs, output)
 	case "info":
 		return p.pluginInfo(ctx, cmdArgs, output)
 	case "start":
@@ -54,10 +182,18 @@ func (p *PluginManagerPlugin) Execute(ctx context.Context, args []string, input
 		return p.stopService(ctx, cmdArgs, output)
 	case "restart":
 		return p.restartService(ctx, cmdArgs, output)
+	case "pause":
+		return p.pauseService(ctx, cmdArgs, output)
+	case "resume":
+		return p.resumeService(ctx, cmdArgs, output)
 	case "status":
 		return p.serviceStatus(ctx, cmdArgs, output)
 	case "config":
 		return p.configService(ctx, cmdArgs, output)
+	case "metrics":
+		return p.showMetrics(ctx, cmdArgs, output)
+	case "health":
+		return p.showHealth(ctx, cmdArgs, output)
 	default:
 		return fmt.Errorf("unknown command: %s", command)
 	}
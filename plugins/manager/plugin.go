@@ -1,4 +1,4 @@
-package main
+package manager
 
 import (
 	"context"
@@ -9,6 +9,17 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+func init() {
+	plugin.Register(plugin.PluginMetadata{
+		ID:           "manager",
+		Name:         "Plugin Manager",
+		Version:      "1.0.0",
+		Type:         plugin.CommandPlugin,
+		Description:  "插件安装、卸载、启用、禁用、升级和服务管理",
+		Capabilities: []string{plugin.CapabilityManagePlugins},
+	}, CreatePlugin)
+}
+
 // CreateCommandPlugin 创建命令类插件实例
 func CreateCommandPlugin() plugin.ICommandPlugin {
 	return &PluginManagerPlugin{
@@ -45,8 +56,16 @@ func (p *PluginManagerPlugin) Init(ctx context.Context, configBytes []byte) erro
 
 	p.pluginsDir = config.PluginsDir
 	p.configDir = config.ConfigDir
+	p.repository = config.Repository
 
-	// 插件管理器将在服务启动时自动设置
+	// 通过Host获取插件管理器，元数据需要声明manage_plugins能力
+	if host, ok := plugin.HostFromContext(ctx); ok {
+		pm, err := host.Manager()
+		if err != nil {
+			return err
+		}
+		p.pluginManager = pm
+	}
 
 	// 创建配置目录
 	if err := os.MkdirAll(p.configDir, 0755); err != nil {
@@ -55,5 +74,3 @@ func (p *PluginManagerPlugin) Init(ctx context.Context, configBytes []byte) erro
 
 	return nil
 }
-
-func main() {}
@@ -1,4 +1,4 @@
-package main
+package manager
 
 import (
 	"context"
@@ -6,9 +6,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
 // listPlugins 列出所有插件
@@ -52,48 +55,81 @@ func (p *PluginManagerPlugin) listPlugins(ctx context.Context, args []string, ou
 }
 
 // installPlugin 安装插件
-func (p *PluginManagerPlugin) installPlugin(ctx context.Context, args []string, output io.Writer) error {
+func (p *PluginManagerPlugin) installPlugin(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if p.pluginManager == nil {
 		return fmt.Errorf("plugin manager not initialized")
 	}
-
-	if len(args) < 1 {
-		return fmt.Errorf("usage: install <plugin_path>")
+	// 命令描述中声明的权限由服务器检查，这里再次检查，避免未经拦截器的调用接收或删除插件文件
+	if err := requirePermission(ctx, permPluginManage); err != nil {
+		return err
 	}
 
-	pluginPath := args[0]
+	args, upload, err := parseUpload(args)
+	if err != nil {
+		return err
+	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(pluginPath); os.IsNotExist(err) {
-		return fmt.Errorf("plugin file not found: %s", pluginPath)
+	var pluginPath string
+	if upload == nil {
+		if len(args) < 1 {
+			return fmt.Errorf("usage: install <plugin_package|plugin_path> | <plugin_id>[@<version>] | --upload <package> --size <bytes> --sha256 <checksum>")
+		}
+		pluginPath = args[0]
 	}
+	fromRepository := upload == nil && p.isRepositoryRef(pluginPath)
 
-	// 复制插件文件到插件目录
-	pluginFileName := filepath.Base(pluginPath)
-	destPath := filepath.Join(p.pluginsDir, pluginFileName)
+	if upload != nil || fromRepository {
+		// 客户端上传或从插件仓库下载的插件包先放入暂存目录，校验通过后再解压到插件目录
+		stagingDir, err := os.MkdirTemp(p.pluginsDir, ".staging-")
+		if err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		defer os.RemoveAll(stagingDir)
 
-	// 复制插件文件
-	if err := copyFile(pluginPath, destPath); err != nil {
-		return fmt.Errorf("failed to copy plugin file: %w", err)
+		if upload != nil {
+			if pluginPath, err = upload.receive(input, stagingDir); err != nil {
+				return err
+			}
+			fmt.Fprintf(output, "Received %s (%d bytes, sha256 verified)\n", upload.name, upload.size)
+		} else {
+			pluginID, version := parseRef(pluginPath)
+			if _, err := p.pluginManager.GetPlugin(pluginID); err == nil {
+				return fmt.Errorf("plugin %s is already installed, use upgrade to change its version", pluginID)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, repositoryTimeout)
+			defer cancel()
+
+			r, err := p.resolveRelease(ctx, pluginID, version)
+			if err != nil {
+				return err
+			}
+			if pluginPath, err = r.download(ctx, stagingDir); err != nil {
+				return err
+			}
+			fmt.Fprintf(output, "Downloaded %s %s (%d bytes, sha256 verified)\n", pluginID, r.Version, r.Size)
+		}
+	} else if _, err := os.Stat(pluginPath); os.IsNotExist(err) {
+		// 检查文件是否存在
+		return fmt.Errorf("plugin file not found: %s", pluginPath)
+	} else if !isPackage(pluginPath) {
+		// 插件包由UnpackPlugin在解压前检查，插件文件在复制前检查，避免覆盖已安装插件的文件
+		if err := p.checkNotInstalled(pluginPath); err != nil {
+			return err
+		}
 	}
 
-	// 复制配置文件（如果存在）
-	metadataPath := pluginPath + ".yml"
-	if _, err := os.Stat(metadataPath); err == nil {
-		destMetadataPath := destPath + ".yml"
-		if err := copyFile(metadataPath, destMetadataPath); err != nil {
-			return fmt.Errorf("failed to copy plugin metadata: %w", err)
-		}
+	// 校验并解压插件包，或复制插件文件到插件目录
+	destPath, err := p.stagePlugin(pluginPath, p.pluginsDir)
+	if err != nil {
+		return err
 	}
 
-	// 加载插件
+	// 加载插件，未签名的插件文件在未允许时会被拒绝
 	plugin, err := p.pluginManager.LoadPlugin(destPath)
 	if err != nil {
 		// 清理文件
-		os.Remove(destPath)
-		if _, err := os.Stat(destPath + ".yml"); err == nil {
-			os.Remove(destPath + ".yml")
-		}
+		removePluginFiles(destPath)
 		return fmt.Errorf("failed to load plugin: %w", err)
 	}
 
@@ -106,9 +142,14 @@ func (p *PluginManagerPlugin) uninstallPlugin(ctx context.Context, args []string
 	if p.pluginManager == nil {
 		return fmt.Errorf("plugin manager not initialized")
 	}
+	// 命令描述中声明的权限由服务器检查，这里再次检查，避免未经拦截器的调用接收或删除插件文件
+	if err := requirePermission(ctx, permPluginManage); err != nil {
+		return err
+	}
 
+	args, force := parseForce(args)
 	if len(args) < 1 {
-		return fmt.Errorf("usage: uninstall <plugin_id>")
+		return fmt.Errorf("usage: uninstall <plugin_id> [--force]")
 	}
 
 	pluginID := args[0]
@@ -119,21 +160,27 @@ func (p *PluginManagerPlugin) uninstallPlugin(ctx context.Context, args []string
 		return fmt.Errorf("failed to get plugin: %w", err)
 	}
 
+	metadata, err := p.pluginManager.GetPluginMetadata(pluginID)
+	if err != nil {
+		return fmt.Errorf("failed to get plugin metadata: %w", err)
+	}
+
 	// 卸载插件
-	if err := p.pluginManager.UnloadPlugin(pluginID); err != nil {
+	if err := p.pluginManager.UnloadPlugin(pluginID, force); err != nil {
 		return fmt.Errorf("failed to unload plugin: %w", err)
 	}
 
-	// 删除插件文件
-	pluginPath := filepath.Join(p.pluginsDir, pluginID+".so")
-	if err := os.Remove(pluginPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove plugin file: %w", err)
-	}
+	// 删除插件文件和元数据文件，静态编译的插件没有插件文件
+	if metadata.Path != "" {
+		if err := os.Remove(metadata.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove plugin file: %w", err)
+		}
 
-	// 删除元数据文件
-	metadataPath := pluginPath + ".yml"
-	if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove plugin metadata: %w", err)
+		metadataPath := metadata.Path + ".yml"
+		if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove plugin metadata: %w", err)
+		}
+		removeSignature(metadata.Path)
 	}
 
 	// 删除配置文件
@@ -179,8 +226,9 @@ func (p *PluginManagerPlugin) disablePlugin(ctx context.Context, args []string,
 		return fmt.Errorf("plugin manager not initialized")
 	}
 
+	args, force := parseForce(args)
 	if len(args) < 1 {
-		return fmt.Errorf("usage: disable <plugin_id>")
+		return fmt.Errorf("usage: disable <plugin_id> [--force]")
 	}
 
 	pluginID := args[0]
@@ -192,7 +240,7 @@ func (p *PluginManagerPlugin) disablePlugin(ctx context.Context, args []string,
 	}
 
 	// 禁用插件
-	if err := p.pluginManager.DisablePlugin(pluginID); err != nil {
+	if err := p.pluginManager.DisablePlugin(pluginID, force); err != nil {
 		return fmt.Errorf("failed to disable plugin: %w", err)
 	}
 
@@ -201,21 +249,57 @@ func (p *PluginManagerPlugin) disablePlugin(ctx context.Context, args []string,
 }
 
 // upgradePlugin 升级插件
-func (p *PluginManagerPlugin) upgradePlugin(ctx context.Context, args []string, output io.Writer) error {
+func (p *PluginManagerPlugin) upgradePlugin(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if p.pluginManager == nil {
 		return fmt.Errorf("plugin manager not initialized")
 	}
-
-	if len(args) < 2 {
-		return fmt.Errorf("usage: upgrade <plugin_id> <plugin_path>")
+	// 命令描述中声明的权限由服务器检查，这里再次检查，避免未经拦截器的调用接收或删除插件文件
+	if err := requirePermission(ctx, permPluginManage); err != nil {
+		return err
 	}
 
-	pluginID := args[0]
-	pluginPath := args[1]
+	args, upload, err := parseUpload(args)
+	if err != nil {
+		return err
+	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(pluginPath); os.IsNotExist(err) {
-		return fmt.Errorf("plugin file not found: %s", pluginPath)
+	usage := fmt.Errorf("usage: upgrade <plugin_id> <plugin_package|plugin_path> | <plugin_id>[@<version>] | <plugin_id> --upload <package> --size <bytes> --sha256 <checksum> [--health-check <seconds>]")
+	var opts plugin.UpgradeOptions
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--health-check" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return usage
+		}
+		seconds, err := strconv.Atoi(args[i+1])
+		if err != nil || seconds < 0 {
+			return usage
+		}
+		opts.HealthCheck = time.Duration(seconds) * time.Second
+		i++
+	}
+	// 只指定插件ID时从插件仓库升级
+	fromRepository := upload == nil && len(rest) == 1 && p.repository != ""
+	if len(rest) < 1 || (upload == nil && len(rest) < 2 && !fromRepository) {
+		return usage
+	}
+
+	pluginID := rest[0]
+	var version string
+	var pluginPath string
+	switch {
+	case fromRepository:
+		pluginID, version = parseRef(pluginID)
+	case upload == nil:
+		pluginPath = rest[1]
+
+		// 检查文件是否存在
+		if _, err := os.Stat(pluginPath); os.IsNotExist(err) {
+			return fmt.Errorf("plugin file not found: %s", pluginPath)
+		}
 	}
 
 	// 获取旧插件信息
@@ -224,31 +308,55 @@ func (p *PluginManagerPlugin) upgradePlugin(ctx context.Context, args []string,
 		return fmt.Errorf("failed to get plugin: %w", err)
 	}
 
-	// 复制新插件文件到插件目录
-	pluginFileName := filepath.Base(pluginPath)
-	destPath := filepath.Join(p.pluginsDir, pluginFileName)
+	// 从插件仓库选择版本，未指定版本且没有更新的兼容版本时不升级
+	var r release
+	if fromRepository {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, repositoryTimeout)
+		defer cancel()
 
-	// 复制插件文件
-	if err := copyFile(pluginPath, destPath); err != nil {
-		return fmt.Errorf("failed to copy plugin file: %w", err)
+		if r, err = p.resolveRelease(ctx, pluginID, version); err != nil {
+			return err
+		}
+		available, err := plugin.ParseVersion(r.Version)
+		installed, ok := p.installedVersion(pluginID)
+		if err == nil && ok {
+			if cmp := available.Compare(installed); cmp == 0 || (version == "" && cmp < 0) {
+				fmt.Fprintf(output, "Plugin %s is up to date (%s)\n", pluginID, oldPlugin.Version())
+				return nil
+			}
+		}
 	}
 
-	// 复制配置文件（如果存在）
-	metadataPath := pluginPath + ".yml"
-	if _, err := os.Stat(metadataPath); err == nil {
-		destMetadataPath := destPath + ".yml"
-		if err := copyFile(metadataPath, destMetadataPath); err != nil {
-			return fmt.Errorf("failed to copy plugin metadata: %w", err)
-		}
+	// 新版本先放入暂存目录，升级成功后才替换插件目录中的旧版本
+	stagingDir, err := os.MkdirTemp(p.pluginsDir, ".staging-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
 	}
+	defer os.RemoveAll(stagingDir)
 
-	// 升级插件
-	if err := p.pluginManager.UpgradePlugin(pluginID, destPath); err != nil {
-		// 清理文件
-		os.Remove(destPath)
-		if _, err := os.Stat(destPath + ".yml"); err == nil {
-			os.Remove(destPath + ".yml")
+	// 接收客户端上传的插件包或从插件仓库下载
+	switch {
+	case upload != nil:
+		if pluginPath, err = upload.receive(input, stagingDir); err != nil {
+			return err
 		}
+		fmt.Fprintf(output, "Received %s (%d bytes, sha256 verified)\n", upload.name, upload.size)
+	case fromRepository:
+		if pluginPath, err = r.download(ctx, stagingDir); err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Downloaded %s %s (%d bytes, sha256 verified)\n", pluginID, r.Version, r.Size)
+	}
+
+	// 校验并解压插件包，或复制新插件文件到暂存目录
+	stagedPath, err := p.stagePlugin(pluginPath, stagingDir)
+	if err != nil {
+		return err
+	}
+
+	// 升级插件，失败时自动恢复旧版本
+	if err := p.pluginManager.UpgradePlugin(pluginID, stagedPath, opts); err != nil {
 		return fmt.Errorf("failed to upgrade plugin: %w", err)
 	}
 
@@ -262,6 +370,107 @@ func (p *PluginManagerPlugin) upgradePlugin(ctx context.Context, args []string,
 	return nil
 }
 
+// upgradeHistory 显示升级历史
+func (p *PluginManagerPlugin) upgradeHistory(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	var pluginID string
+	if len(args) > 0 {
+		pluginID = args[0]
+	}
+
+	records, err := p.pluginManager.UpgradeHistory(pluginID)
+	if err != nil {
+		return fmt.Errorf("failed to read upgrade history: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(output, "No upgrade history")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Time\tID\tFrom\tTo\tResult\tError")
+	for _, record := range records {
+		errStr := record.Error
+		if errStr == "" {
+			errStr = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", record.Time.Format(time.RFC3339), record.ID,
+			record.FromVersion, record.ToVersion, record.Result, errStr)
+	}
+	return w.Flush()
+}
+
+// auditLog 显示命令执行审计记录
+func (p *PluginManagerPlugin) auditLog(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	usage := fmt.Errorf("usage: audit [plugin_id] [--limit <n>]")
+	limit := 50
+	var pluginID string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--limit" {
+			pluginID = args[i]
+			continue
+		}
+		if i+1 >= len(args) {
+			return usage
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n < 0 {
+			return usage
+		}
+		limit = n
+		i++
+	}
+
+	records, err := p.pluginManager.AuditLog(pluginID, limit)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(output, "No audit records")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Time\tClient\tVia\tPlugin\tCommand\tDuration\tError")
+	for _, record := range records {
+		via := strings.Join(record.Via, ">")
+		if via == "" {
+			via = "-"
+		}
+		errStr := record.Error
+		if errStr == "" {
+			errStr = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%dms\t%s\n", record.Time.Format(time.RFC3339), record.ClientID,
+			via, record.Plugin, strings.Join(record.Args, " "), record.Duration, errStr)
+	}
+	return w.Flush()
+}
+
+// listInterceptors 按执行顺序列出命令拦截器
+func (p *PluginManagerPlugin) listInterceptors(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	names := p.pluginManager.Interceptors()
+	if len(names) == 0 {
+		fmt.Fprintln(output, "No interceptors registered")
+		return nil
+	}
+	for i, name := range names {
+		fmt.Fprintf(output, "%d. %s\n", i+1, name)
+	}
+	return nil
+}
+
 // pluginInfo 获取插件信息
 func (p *PluginManagerPlugin) pluginInfo(ctx context.Context, args []string, output io.Writer) error {
 	if p.pluginManager == nil {
@@ -275,44 +484,27 @@ func (p *PluginManagerPlugin) pluginInfo(ctx context.Context, args []string, out
 	pluginID := args[0]
 
 	// 获取插件
-	plugin, err := p.pluginManager.GetPlugin(pluginID)
+	plug, err := p.pluginManager.GetPlugin(pluginID)
 	if err != nil {
 		return fmt.Errorf("failed to get plugin: %w", err)
 	}
 
-	// 读取插件元数据
-	metadataPath := filepath.Join(p.pluginsDir, pluginID+".so.yml")
-	var metadata struct {
-		ID           string   `yaml:"id"`
-		Name         string   `yaml:"name"`
-		Version      string   `yaml:"version"`
-		Type         int      `yaml:"type"`
-		Description  string   `yaml:"description"`
-		Author       string   `yaml:"author"`
-		Dependencies []string `yaml:"dependencies,omitempty"`
-	}
-
-	if _, err := os.Stat(metadataPath); err == nil {
-		metadataBytes, err := os.ReadFile(metadataPath)
-		if err != nil {
-			return fmt.Errorf("failed to read plugin metadata: %w", err)
-		}
-
-		if err := yaml.Unmarshal(metadataBytes, &metadata); err != nil {
-			return fmt.Errorf("failed to parse plugin metadata: %w", err)
-		}
+	// 获取插件元数据
+	metadata, err := p.pluginManager.GetPluginMetadata(pluginID)
+	if err != nil {
+		return fmt.Errorf("failed to get plugin metadata: %w", err)
 	}
 
 	// 输出插件信息
 	fmt.Fprintf(output, "Plugin Information:\n")
-	fmt.Fprintf(output, "ID: %s\n", plugin.ID())
-	fmt.Fprintf(output, "Name: %s\n", plugin.Name())
-	fmt.Fprintf(output, "Version: %s\n", plugin.Version())
+	fmt.Fprintf(output, "ID: %s\n", plug.ID())
+	fmt.Fprintf(output, "Name: %s\n", plug.Name())
+	fmt.Fprintf(output, "Version: %s\n", plug.Version())
 
 	var typeStr string
-	if plugin.Type() == 0 {
+	if plug.Type() == 0 {
 		typeStr = "Service"
-	} else if plugin.Type() == 1 {
+	} else if plug.Type() == 1 {
 		typeStr = "Command"
 	} else {
 		typeStr = "Unknown"
@@ -320,32 +512,158 @@ func (p *PluginManagerPlugin) pluginInfo(ctx context.Context, args []string, out
 	fmt.Fprintf(output, "Type: %s\n", typeStr)
 
 	var stateStr string
-	if plugin.State() == 0 {
+	if plug.State() == 0 {
 		stateStr = "Disabled"
-	} else if plugin.State() == 1 {
+	} else if plug.State() == 1 {
 		stateStr = "Enabled"
-	} else if plugin.State() == 2 {
+	} else if plug.State() == 2 {
 		stateStr = "Running"
-	} else if plugin.State() == 3 {
+	} else if plug.State() == 3 {
 		stateStr = "Paused"
 	} else {
 		stateStr = "Unknown"
 	}
 	fmt.Fprintf(output, "State: %s\n", stateStr)
 
+	switch {
+	case metadata.Static:
+		fmt.Fprintf(output, "Source: static\n")
+	case metadata.Runtime == plugin.RuntimeProcess:
+		fmt.Fprintf(output, "Source: process (%s)\n", metadata.Path)
+	default:
+		fmt.Fprintf(output, "Source: shared object (%s)\n", metadata.Path)
+	}
+
 	if metadata.Description != "" {
 		fmt.Fprintf(output, "Description: %s\n", metadata.Description)
 	}
 	if metadata.Author != "" {
 		fmt.Fprintf(output, "Author: %s\n", metadata.Author)
 	}
+	if metadata.APIVersion != "" {
+		fmt.Fprintf(output, "Plugin API: %s (server provides %s)\n", metadata.APIVersion, plugin.APIVersion)
+	}
+	if metadata.MinServerVersion != "" {
+		fmt.Fprintf(output, "Min Server Version: %s (running %s)\n", metadata.MinServerVersion, plugin.ServerVersion)
+	}
 	if len(metadata.Dependencies) > 0 {
 		fmt.Fprintf(output, "Dependencies: %s\n", strings.Join(metadata.Dependencies, ", "))
 	}
+	if dependents, err := p.pluginManager.GetDependents(pluginID); err == nil && len(dependents) > 0 {
+		fmt.Fprintf(output, "Required By: %s\n", strings.Join(dependents, ", "))
+	}
+	if state, ok := p.pluginManager.DesiredState(pluginID); ok {
+		fmt.Fprintf(output, "Saved State: %s\n", state)
+	}
+	if record, ok := p.pluginManager.Quarantined(pluginID); ok {
+		fmt.Fprintf(output, "Quarantined: %s (%s)\n", record.Time.Format(time.RFC3339), record.Reason)
+	}
+
+	return nil
+}
+
+// isPackage 判断文件是否为插件包（tar.gz）
+func isPackage(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// stagePlugin 将插件放入目录dir：插件包校验签名后解压，插件文件连同元数据直接复制，返回插件文件路径
+func (p *PluginManagerPlugin) stagePlugin(pluginPath, dir string) (string, error) {
+	if isPackage(pluginPath) {
+		destPath, err := p.pluginManager.UnpackPlugin(pluginPath, dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to unpack plugin package: %w", err)
+		}
+		return destPath, nil
+	}
+
+	destPath := filepath.Join(dir, filepath.Base(pluginPath))
 
+	// 复制插件文件
+	if err := copyFile(pluginPath, destPath); err != nil {
+		return "", fmt.Errorf("failed to copy plugin file: %w", err)
+	}
+
+	// 复制配置文件（如果存在）
+	metadataPath := pluginPath + ".yml"
+	if _, err := os.Stat(metadataPath); err == nil {
+		if err := copyFile(metadataPath, destPath+".yml"); err != nil {
+			return "", fmt.Errorf("failed to copy plugin metadata: %w", err)
+		}
+	}
+
+	// 复制插件旁的清单和签名（如果存在），否则移除目标位置旧的清单和签名
+	removeSignature(destPath)
+	for _, suffix := range []string{".manifest", ".sig"} {
+		if _, err := os.Stat(pluginPath + suffix); err == nil {
+			if err := copyFile(pluginPath+suffix, destPath+suffix); err != nil {
+				return "", fmt.Errorf("failed to copy plugin signature: %w", err)
+			}
+		}
+	}
+
+	return destPath, nil
+}
+
+// checkNotInstalled 检查插件文件pluginPath的插件未安装，且复制到插件目录时不会覆盖已有文件
+func (p *PluginManagerPlugin) checkNotInstalled(pluginPath string) error {
+	metadata, err := plugin.ReadPluginMetadata(pluginPath)
+	if err != nil {
+		return err
+	}
+	if _, err := p.pluginManager.GetPlugin(metadata.ID); err == nil {
+		return fmt.Errorf("%w: %s, use upgrade to change its version", plugin.ErrPluginAlreadyExists, metadata.ID)
+	}
+
+	destPath := filepath.Join(p.pluginsDir, filepath.Base(pluginPath))
+	for _, path := range []string{destPath, destPath + ".yml", destPath + ".manifest", destPath + ".sig"} {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%w: %s already exists", plugin.ErrPluginAlreadyExists, path)
+		}
+	}
 	return nil
 }
 
+// removePluginFiles 删除插件文件、元数据、清单和签名
+func removePluginFiles(path string) {
+	os.Remove(path)
+	os.Remove(path + ".yml")
+	removeSignature(path)
+}
+
+// removeSignature 删除插件的清单和签名文件
+func removeSignature(path string) {
+	os.Remove(path + ".manifest")
+	os.Remove(path + ".sig")
+}
+
+// requirePermission 检查执行命令的客户端是否有权限perm，服务器内部执行的命令不检查
+func requirePermission(ctx context.Context, perm string) error {
+	host, ok := plugin.HostFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	caller, ok := host.Caller()
+	if !ok || caller.HasPermission(perm) {
+		return nil
+	}
+	return fmt.Errorf("%w: client %s lacks %s", plugin.ErrAccessDenied, caller.ClientID, perm)
+}
+
+// parseForce 从参数中移除--force，返回剩余参数和是否强制执行
+func parseForce(args []string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	force := false
+	for _, arg := range args {
+		if arg == "--force" || arg == "-f" {
+			force = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, force
+}
+
 // copyFile 复制文件
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
@@ -354,7 +672,13 @@ func copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	// 保留文件权限，进程插件需要可执行权限
+	info, err := sourceFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	destFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
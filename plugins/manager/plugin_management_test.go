@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+)
+
+// newTestManager 创建使用临时目录的插件管理器并加载manager插件，返回插件管理器和插件目录
+func newTestManager(t *testing.T) (plugin.PluginManager, string) {
+	t.Helper()
+	pluginsDir, configDir := t.TempDir(), t.TempDir()
+	config := fmt.Sprintf("plugins_dir: %s\nconfig_dir: %s\n", pluginsDir, configDir)
+	if err := os.WriteFile(filepath.Join(configDir, "manager.yml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := plugin.NewPluginManager(pluginsDir, configDir)
+	pm.SetDataDir(t.TempDir())
+	t.Cleanup(func() { pm.Shutdown() })
+	if _, err := pm.LoadStaticPlugin("manager"); err != nil {
+		t.Fatalf("LoadStaticPlugin(manager): %v", err)
+	}
+	return pm, pluginsDir
+}
+
+func TestManageCommandsRequirePluginManage(t *testing.T) {
+	data := []byte("not really a package")
+	upload := []string{"--upload", "p.tar.gz", "--size", fmt.Sprint(len(data)), "--sha256", sha256Hex(data)}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "install upload", args: append([]string{"install"}, upload...)},
+		{name: "install path", args: []string{"install", "/tmp/p.tar.gz"}},
+		{name: "upgrade upload", args: append([]string{"upgrade", "manager"}, upload...)},
+		{name: "uninstall", args: []string{"uninstall", "manager"}},
+	}
+
+	// 有权限拦截器时由拦截器拒绝，没有时由命令自身拒绝
+	for _, interceptor := range []bool{true, false} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s interceptor=%v", tt.name, interceptor), func(t *testing.T) {
+				pm, pluginsDir := newTestManager(t)
+				if interceptor {
+					if err := pm.Use("permission", plugin.PermissionInterceptor(pm)); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				err := pm.ExecuteCommand(context.Background(), &plugin.CommandInvocation{
+					Plugin: "manager",
+					Args:   tt.args,
+					Caller: &plugin.Caller{ClientID: "c", Permissions: []string{"plugin:use"}},
+					Input:  bytes.NewReader(data),
+					Output: io.Discard,
+				})
+				if !errors.Is(err, plugin.ErrAccessDenied) {
+					t.Fatalf("error = %v, want %v", err, plugin.ErrAccessDenied)
+				}
+				if _, err := pm.GetPlugin("manager"); err != nil {
+					t.Errorf("manager plugin was removed: %v", err)
+				}
+				if entries, _ := os.ReadDir(pluginsDir); len(entries) != 0 {
+					t.Errorf("plugins dir is not empty: %v", entries)
+				}
+			})
+		}
+	}
+}
+
+func TestManageCommandsAllowPluginManage(t *testing.T) {
+	pm, _ := newTestManager(t)
+
+	// 有plugin:manage权限时通过权限检查，因插件不存在而失败
+	err := pm.ExecuteCommand(context.Background(), &plugin.CommandInvocation{
+		Plugin: "manager",
+		Args:   []string{"uninstall", "missing"},
+		Caller: &plugin.Caller{ClientID: "c", Permissions: []string{"plugin:use", "plugin:manage"}},
+		Output: io.Discard,
+	})
+	if err == nil || errors.Is(err, plugin.ErrAccessDenied) {
+		t.Fatalf("error = %v, want plugin not found", err)
+	}
+	if !errors.Is(err, plugin.ErrPluginNotFound) {
+		t.Errorf("error = %v, want %v", err, plugin.ErrPluginNotFound)
+	}
+}
@@ -0,0 +1,295 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+)
+
+const (
+	// maxIndexSize 插件仓库索引文件大小上限
+	maxIndexSize = 16 << 20
+
+	// repositoryTimeout 读取索引和下载插件包的超时时间
+	repositoryTimeout = 5 * time.Minute
+)
+
+// release 从插件仓库解析出的插件版本
+type release struct {
+	id string
+	plugin.RepositoryRelease
+	// location 插件包的本地路径或URL
+	location string
+}
+
+// isRemote 判断仓库位置是否为HTTP地址
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// indexLocation 返回索引文件的位置，仓库位置以.json结尾时视为索引文件本身
+func (p *PluginManagerPlugin) indexLocation() (string, error) {
+	if p.repository == "" {
+		return "", fmt.Errorf("no plugin repository configured, set repository in manager.yml")
+	}
+	if strings.HasSuffix(p.repository, ".json") {
+		return p.repository, nil
+	}
+	if !isRemote(p.repository) {
+		return filepath.Join(p.repository, plugin.RepositoryIndexFile), nil
+	}
+
+	u, err := url.Parse(p.repository)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.JoinPath(plugin.RepositoryIndexFile).String(), nil
+}
+
+// openLocation 打开本地文件或通过HTTP GET下载
+func openLocation(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !isRemote(location) {
+		return os.Open(location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// loadIndex 读取插件仓库索引，返回索引和索引文件位置
+func (p *PluginManagerPlugin) loadIndex(ctx context.Context) (plugin.RepositoryIndex, string, error) {
+	location, err := p.indexLocation()
+	if err != nil {
+		return plugin.RepositoryIndex{}, "", err
+	}
+
+	r, err := openLocation(ctx, location)
+	if err != nil {
+		return plugin.RepositoryIndex{}, "", fmt.Errorf("failed to read repository index: %w", err)
+	}
+	defer r.Close()
+
+	var idx plugin.RepositoryIndex
+	if err := json.NewDecoder(io.LimitReader(r, maxIndexSize)).Decode(&idx); err != nil {
+		return plugin.RepositoryIndex{}, "", fmt.Errorf("failed to parse repository index %s: %w", location, err)
+	}
+	return idx, location, nil
+}
+
+// packageLocation 返回插件包的位置，相对位置基于索引文件所在目录
+func packageLocation(indexLocation, pkg string) (string, error) {
+	if isRemote(pkg) {
+		return pkg, nil
+	}
+	if isRemote(indexLocation) {
+		base, err := url.Parse(indexLocation)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(pkg)
+		if err != nil {
+			return "", fmt.Errorf("invalid package location %q: %w", pkg, err)
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	if filepath.IsAbs(pkg) {
+		return pkg, nil
+	}
+	return filepath.Join(filepath.Dir(indexLocation), filepath.FromSlash(pkg)), nil
+}
+
+// parseRef 解析<plugin_id>[@<version>]
+func parseRef(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "@"); i > 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// isRepositoryRef 判断install的参数是否指向插件仓库：已配置仓库，且参数不是插件包、不含路径且不是已存在的文件
+func (p *PluginManagerPlugin) isRepositoryRef(arg string) bool {
+	if p.repository == "" || isPackage(arg) || strings.ContainsAny(arg, `/\`) {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return os.IsNotExist(err)
+}
+
+// resolveRelease 从插件仓库选择兼容当前服务器的版本，version为空时选择最新版本
+func (p *PluginManagerPlugin) resolveRelease(ctx context.Context, id, version string) (release, error) {
+	idx, location, err := p.loadIndex(ctx)
+	if err != nil {
+		return release{}, err
+	}
+	rp, err := idx.Find(id)
+	if err != nil {
+		return release{}, err
+	}
+	r, err := rp.Resolve(version)
+	if err != nil {
+		return release{}, err
+	}
+	pkgLocation, err := packageLocation(location, r.Package)
+	if err != nil {
+		return release{}, err
+	}
+	return release{id: id, RepositoryRelease: r, location: pkgLocation}, nil
+}
+
+// download 将插件包下载到目录dir，校验大小、SHA-256以及包中的插件ID和版本后返回文件路径
+func (r release) download(ctx context.Context, dir string) (string, error) {
+	if sum, err := hex.DecodeString(r.SHA256); err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("repository index has no valid sha256 for %s %s", r.id, r.Version)
+	}
+	if r.Size <= 0 || r.Size > maxUploadSize {
+		return "", fmt.Errorf("repository index has no valid size for %s %s", r.id, r.Version)
+	}
+
+	body, err := openLocation(ctx, r.location)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s %s: %w", r.id, r.Version, err)
+	}
+	defer body.Close()
+
+	// 与客户端上传的插件包使用相同的接收和校验流程
+	pkg := &upload{name: r.id + "-" + r.Version + ".tar.gz", size: r.Size, sha256: strings.ToLower(r.SHA256)}
+	path, err := pkg.receive(body, dir)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := plugin.ReadPackageMetadata(path)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if metadata.ID != r.id || metadata.Version != r.Version {
+		os.Remove(path)
+		return "", fmt.Errorf("package %s contains %s %s, expected %s %s", r.location, metadata.ID, metadata.Version, r.id, r.Version)
+	}
+	return path, nil
+}
+
+// installedVersion 返回已安装插件的版本
+func (p *PluginManagerPlugin) installedVersion(id string) (plugin.Version, bool) {
+	plug, err := p.pluginManager.GetPlugin(id)
+	if err != nil {
+		return plugin.Version{}, false
+	}
+	v, err := plugin.ParseVersion(plug.Version())
+	return v, err == nil
+}
+
+// searchPlugins 搜索插件仓库
+func (p *PluginManagerPlugin) searchPlugins(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, repositoryTimeout)
+	defer cancel()
+
+	idx, _, err := p.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := strings.ToLower(strings.Join(args, " "))
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLatest\tInstalled\tDescription")
+	matched := 0
+	for _, rp := range idx.Plugins {
+		if query != "" && !strings.Contains(strings.ToLower(rp.ID+" "+rp.Name+" "+rp.Description), query) {
+			continue
+		}
+		matched++
+
+		latest := "-"
+		if r, err := rp.Resolve(""); err == nil {
+			latest = r.Version
+		} else if len(rp.Versions) > 0 {
+			latest = "(incompatible)"
+		}
+		installed := "-"
+		if plug, err := p.pluginManager.GetPlugin(rp.ID); err == nil {
+			installed = plug.Version()
+		}
+		description := rp.Description
+		if description == "" {
+			description = rp.Name
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rp.ID, latest, installed, description)
+	}
+	if matched == 0 {
+		fmt.Fprintln(output, "No plugins found")
+		return nil
+	}
+	return w.Flush()
+}
+
+// outdatedPlugins 列出插件仓库中有更新的兼容版本的已安装插件
+func (p *PluginManagerPlugin) outdatedPlugins(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, repositoryTimeout)
+	defer cancel()
+
+	idx, _, err := p.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tInstalled\tAvailable")
+	outdated := 0
+	for _, plug := range p.pluginManager.ListPlugins() {
+		rp, err := idx.Find(plug.ID())
+		if err != nil {
+			continue
+		}
+		r, err := rp.Resolve("")
+		if err != nil {
+			continue
+		}
+		installed, ok := p.installedVersion(plug.ID())
+		available, err := plugin.ParseVersion(r.Version)
+		if err != nil || (ok && available.Compare(installed) <= 0) {
+			continue
+		}
+		outdated++
+		fmt.Fprintf(w, "%s\t%s\t%s\n", plug.ID(), plug.Version(), r.Version)
+	}
+	if outdated == 0 {
+		fmt.Fprintln(output, "All plugins are up to date")
+		return nil
+	}
+	return w.Flush()
+}
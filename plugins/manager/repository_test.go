@@ -0,0 +1,302 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+)
+
+// buildTestPackage 在dir中生成插件id版本version的签名插件包，返回插件包对应的仓库版本记录
+func buildTestPackage(t *testing.T, dir, id, version string) plugin.RepositoryRelease {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	path := filepath.Join(src, id+".so")
+	if err := os.WriteFile(path, []byte("plugin binary "+version), 0755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := fmt.Sprintf("id: %s\nname: %s\nversion: %s\ntype: 1\napi_version: %s\n", id, id, version, plugin.APIVersion)
+	if err := os.WriteFile(path+".yml", []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	name := id + "-" + version + ".tar.gz"
+	if err := plugin.BuildPackage(path, filepath.Join(dir, name), key); err != nil {
+		t.Fatalf("BuildPackage: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plugin.RepositoryRelease{Version: version, Package: name, SHA256: sha256Hex(data), Size: int64(len(data))}
+}
+
+// writeIndex 在dir中写入插件仓库索引
+func writeIndex(t *testing.T, dir string, idx plugin.RepositoryIndex) {
+	t.Helper()
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, plugin.RepositoryIndexFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackageLocation(t *testing.T) {
+	tests := []struct {
+		name  string
+		index string
+		pkg   string
+		want  string
+	}{
+		{name: "relative to local index", index: "/srv/repo/index.json", pkg: "echo-1.0.0.tar.gz", want: "/srv/repo/echo-1.0.0.tar.gz"},
+		{name: "relative subdirectory", index: "/srv/repo/index.json", pkg: "echo/echo-1.0.0.tar.gz", want: "/srv/repo/echo/echo-1.0.0.tar.gz"},
+		{name: "absolute local path", index: "/srv/repo/index.json", pkg: "/opt/echo-1.0.0.tar.gz", want: "/opt/echo-1.0.0.tar.gz"},
+		{name: "url with local index", index: "/srv/repo/index.json", pkg: "https://cdn.example.com/echo.tar.gz", want: "https://cdn.example.com/echo.tar.gz"},
+		{name: "relative to remote index", index: "https://example.com/repo/index.json", pkg: "echo-1.0.0.tar.gz", want: "https://example.com/repo/echo-1.0.0.tar.gz"},
+		{name: "parent of remote index", index: "https://example.com/repo/index.json", pkg: "../pkgs/echo.tar.gz", want: "https://example.com/pkgs/echo.tar.gz"},
+		{name: "absolute path on remote host", index: "https://example.com/repo/index.json", pkg: "/pkgs/echo.tar.gz", want: "https://example.com/pkgs/echo.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := packageLocation(tt.index, tt.pkg)
+			if err != nil {
+				t.Fatalf("packageLocation(%s, %s): %v", tt.index, tt.pkg, err)
+			}
+			if got != tt.want {
+				t.Errorf("packageLocation(%s, %s) = %s, want %s", tt.index, tt.pkg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRelease(t *testing.T) {
+	repo := t.TempDir()
+	elsewhere := t.TempDir()
+	absolute := filepath.Join(elsewhere, "echo-0.9.0.tar.gz")
+	writeIndex(t, repo, plugin.RepositoryIndex{Plugins: []plugin.RepositoryPlugin{{
+		ID: "echo",
+		Versions: []plugin.RepositoryRelease{
+			{Version: "3.0.0", Package: "echo-3.0.0.tar.gz", APIVersion: "99.0.0"},
+			{Version: "2.0.0-beta", Package: "echo-2.0.0-beta.tar.gz"},
+			{Version: "1.2.0", Package: "pkgs/echo-1.2.0.tar.gz"},
+			{Version: "1.0.0", Package: "echo-1.0.0.tar.gz"},
+			{Version: "0.9.0", Package: absolute},
+		},
+	}}})
+
+	tests := []struct {
+		name         string
+		repository   string
+		id           string
+		version      string
+		wantVersion  string
+		wantLocation string
+		wantErr      error
+	}{
+		{name: "newest compatible", repository: repo, id: "echo", wantVersion: "1.2.0", wantLocation: filepath.Join(repo, "pkgs", "echo-1.2.0.tar.gz")},
+		{name: "repository is the index file", repository: filepath.Join(repo, plugin.RepositoryIndexFile), id: "echo", wantVersion: "1.2.0", wantLocation: filepath.Join(repo, "pkgs", "echo-1.2.0.tar.gz")},
+		{name: "exact version", repository: repo, id: "echo", version: "1.0.0", wantVersion: "1.0.0", wantLocation: filepath.Join(repo, "echo-1.0.0.tar.gz")},
+		{name: "constraint", repository: repo, id: "echo", version: "<1.2.0", wantVersion: "1.0.0", wantLocation: filepath.Join(repo, "echo-1.0.0.tar.gz")},
+		{name: "prerelease by version", repository: repo, id: "echo", version: "2.0.0-beta", wantVersion: "2.0.0-beta", wantLocation: filepath.Join(repo, "echo-2.0.0-beta.tar.gz")},
+		{name: "absolute package path", repository: repo, id: "echo", version: "0.9.0", wantVersion: "0.9.0", wantLocation: absolute},
+		{name: "incompatible version", repository: repo, id: "echo", version: "3.0.0", wantErr: plugin.ErrIncompatibleAPI},
+		{name: "only incompatible versions match", repository: repo, id: "echo", version: ">=3.0.0", wantErr: plugin.ErrNoCompatibleVersion},
+		{name: "unknown version", repository: repo, id: "echo", version: "5.0.0", wantErr: plugin.ErrNotInRepository},
+		{name: "unknown plugin", repository: repo, id: "missing", wantErr: plugin.ErrNotInRepository},
+		{name: "no index", repository: elsewhere, id: "echo", wantErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PluginManagerPlugin{repository: tt.repository}
+			r, err := p.resolveRelease(context.Background(), tt.id, tt.version)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveRelease: %v", err)
+			}
+			if r.id != tt.id || r.Version != tt.wantVersion || r.location != tt.wantLocation {
+				t.Errorf("release = %s %s at %s, want %s %s at %s", r.id, r.Version, r.location, tt.id, tt.wantVersion, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestReleaseDownload(t *testing.T) {
+	repo := t.TempDir()
+	good := buildTestPackage(t, repo, "echo", "1.0.0")
+	location := filepath.Join(repo, good.Package)
+
+	tests := []struct {
+		name    string
+		release release
+		wantErr string
+	}{
+		{name: "verified", release: release{id: "echo", RepositoryRelease: good, location: location}},
+		{
+			name:    "uppercase checksum",
+			release: release{id: "echo", RepositoryRelease: withSHA256(good, strings.ToUpper(good.SHA256)), location: location},
+		},
+		{
+			name:    "checksum mismatch",
+			release: release{id: "echo", RepositoryRelease: withSHA256(good, sha256Hex([]byte("other"))), location: location},
+			wantErr: "sha256 checksum mismatch",
+		},
+		{
+			name:    "invalid checksum",
+			release: release{id: "echo", RepositoryRelease: withSHA256(good, "abc"), location: location},
+			wantErr: "no valid sha256",
+		},
+		{
+			name:    "size mismatch",
+			release: release{id: "echo", RepositoryRelease: withSize(good, good.Size-1), location: location},
+			wantErr: fmt.Sprintf("expected %d", good.Size-1),
+		},
+		{
+			name:    "no size",
+			release: release{id: "echo", RepositoryRelease: withSize(good, 0), location: location},
+			wantErr: "no valid size",
+		},
+		{
+			name:    "plugin id mismatch",
+			release: release{id: "other", RepositoryRelease: good, location: location},
+			wantErr: "contains echo 1.0.0, expected other 1.0.0",
+		},
+		{
+			name:    "version mismatch",
+			release: release{id: "echo", RepositoryRelease: withVersion(good, "2.0.0"), location: location},
+			wantErr: "contains echo 1.0.0, expected echo 2.0.0",
+		},
+		{
+			name:    "missing package",
+			release: release{id: "echo", RepositoryRelease: good, location: filepath.Join(repo, "missing.tar.gz")},
+			wantErr: "failed to download",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path, err := tt.release.download(context.Background(), dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("download: %v", err)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if err != nil {
+				// 校验失败时不保留下载的插件包
+				if len(entries) != 0 {
+					t.Errorf("rejected package left behind: %v", entries)
+				}
+				return
+			}
+			metadata, err := plugin.ReadPackageMetadata(path)
+			if err != nil || metadata.ID != "echo" || metadata.Version != "1.0.0" {
+				t.Errorf("downloaded package metadata = %+v, %v", metadata, err)
+			}
+		})
+	}
+}
+
+// withSHA256 返回校验和替换为sum的版本记录
+func withSHA256(r plugin.RepositoryRelease, sum string) plugin.RepositoryRelease {
+	r.SHA256 = sum
+	return r
+}
+
+// withSize 返回大小替换为size的版本记录
+func withSize(r plugin.RepositoryRelease, size int64) plugin.RepositoryRelease {
+	r.Size = size
+	return r
+}
+
+// withVersion 返回版本号替换为version的版本记录
+func withVersion(r plugin.RepositoryRelease, version string) plugin.RepositoryRelease {
+	r.Version = version
+	return r
+}
+
+func TestOutdatedPlugins(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed map[string]string
+		wantRows  []string
+	}{
+		{
+			name:      "newer compatible versions",
+			installed: map[string]string{"echo": "1.0.0", "shell": "2.0.0", "local": "1.0.0"},
+			wantRows:  []string{"echo 1.0.0 1.2.0"},
+		},
+		{
+			name:      "up to date",
+			installed: map[string]string{"echo": "1.2.0", "shell": "3.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// echo的3.0.0不兼容，shell的最新版本为2.0.0
+			repo := t.TempDir()
+			writeIndex(t, repo, plugin.RepositoryIndex{Plugins: []plugin.RepositoryPlugin{
+				{ID: "echo", Versions: []plugin.RepositoryRelease{
+					{Version: "3.0.0", Package: "echo-3.0.0.tar.gz", APIVersion: "99.0.0"},
+					{Version: "1.2.0", Package: "echo-1.2.0.tar.gz"},
+				}},
+				{ID: "shell", Versions: []plugin.RepositoryRelease{{Version: "2.0.0", Package: "shell-2.0.0.tar.gz"}}},
+			}})
+
+			pm := plugin.NewPluginManager(t.TempDir(), t.TempDir())
+			t.Cleanup(func() { pm.Shutdown() })
+			for id, version := range tt.installed {
+				if err := pm.RegisterPlugin(plugin.NewBaseCommandPlugin(id, id, version, plugin.OneTimeCommand)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p := &PluginManagerPlugin{pluginManager: pm, repository: repo}
+			var output bytes.Buffer
+			if err := p.outdatedPlugins(context.Background(), nil, &output); err != nil {
+				t.Fatalf("outdatedPlugins: %v", err)
+			}
+
+			if len(tt.wantRows) == 0 {
+				if got := output.String(); got != "All plugins are up to date\n" {
+					t.Errorf("output = %q", got)
+				}
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			var rows []string
+			for _, line := range lines[1:] {
+				rows = append(rows, strings.Join(strings.Fields(line), " "))
+			}
+			if strings.Join(rows, "|") != strings.Join(tt.wantRows, "|") {
+				t.Errorf("rows = %q, want %q", rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
-package main
+package manager
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/sorc/tcpserver/pkg/plugin"
 )
@@ -33,14 +38,8 @@ func (p *PluginManagerPlugin) startService(ctx context.Context, args []string, o
 		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
 	}
 
-	// 获取服务插件
-	servicePlugin, err := p.pluginManager.GetServicePlugin(pluginID)
-	if err != nil {
-		return fmt.Errorf("failed to get service plugin: %w", err)
-	}
-
-	// 启动服务
-	if err := servicePlugin.Start(ctx); err != nil {
+	// 启动服务并纳入监管
+	if err := p.pluginManager.StartService(pluginID); err != nil {
 		return fmt.Errorf("failed to start service: %w", err)
 	}
 
@@ -71,14 +70,8 @@ func (p *PluginManagerPlugin) stopService(ctx context.Context, args []string, ou
 		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
 	}
 
-	// 获取服务插件
-	servicePlugin, err := p.pluginManager.GetServicePlugin(pluginID)
-	if err != nil {
-		return fmt.Errorf("failed to get service plugin: %w", err)
-	}
-
-	// 停止服务
-	if err := servicePlugin.Stop(); err != nil {
+	// 停止服务并解除监管
+	if err := p.pluginManager.StopService(pluginID); err != nil {
 		return fmt.Errorf("failed to stop service: %w", err)
 	}
 
@@ -109,22 +102,80 @@ func (p *PluginManagerPlugin) restartService(ctx context.Context, args []string,
 		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
 	}
 
-	// 获取服务插件
-	servicePlugin, err := p.pluginManager.GetServicePlugin(pluginID)
+	// 重启服务并纳入监管
+	if err := p.pluginManager.RestartService(pluginID); err != nil {
+		return fmt.Errorf("failed to restart service: %w", err)
+	}
+
+	fmt.Fprintf(output, "Service %s restarted successfully\n", pluginID)
+	return nil
+}
+
+// pauseService 暂停服务
+func (p *PluginManagerPlugin) pauseService(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: pause <plugin_id>")
+	}
+
+	pluginID := args[0]
+
+	// 获取插件
+	plug, err := p.pluginManager.GetPlugin(pluginID)
 	if err != nil {
-		return fmt.Errorf("failed to get service plugin: %w", err)
+		return fmt.Errorf("failed to get plugin: %w", err)
 	}
 
-	// 重启服务
-	if err := servicePlugin.Restart(ctx); err != nil {
-		return fmt.Errorf("failed to restart service: %w", err)
+	// 检查插件类型
+	if plug.Type() != plugin.ServicePlugin {
+		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
 	}
 
-	fmt.Fprintf(output, "Service %s restarted successfully\n", pluginID)
+	// 暂停服务，已建立的连接不受影响
+	if err := p.pluginManager.PauseService(pluginID); err != nil {
+		return fmt.Errorf("failed to pause service: %w", err)
+	}
+
+	fmt.Fprintf(output, "Service %s paused successfully\n", pluginID)
+	return nil
+}
+
+// resumeService 恢复暂停的服务
+func (p *PluginManagerPlugin) resumeService(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: resume <plugin_id>")
+	}
+
+	pluginID := args[0]
+
+	// 获取插件
+	plug, err := p.pluginManager.GetPlugin(pluginID)
+	if err != nil {
+		return fmt.Errorf("failed to get plugin: %w", err)
+	}
+
+	// 检查插件类型
+	if plug.Type() != plugin.ServicePlugin {
+		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
+	}
+
+	// 恢复服务
+	if err := p.pluginManager.ResumeService(pluginID); err != nil {
+		return fmt.Errorf("failed to resume service: %w", err)
+	}
+
+	fmt.Fprintf(output, "Service %s resumed successfully\n", pluginID)
 	return nil
 }
 
-// serviceStatus 获取服务状态
+// serviceStatus 获取服务状态，包括重启策略、重启次数和最近一次错误
 func (p *PluginManagerPlugin) serviceStatus(ctx context.Context, args []string, output io.Writer) error {
 	if p.pluginManager == nil {
 		return fmt.Errorf("plugin manager not initialized")
@@ -135,29 +186,26 @@ func (p *PluginManagerPlugin) serviceStatus(ctx context.Context, args []string,
 		plugins := p.pluginManager.ListPlugins()
 
 		fmt.Fprintln(output, "Service Plugins Status:")
-		fmt.Fprintln(output, "ID\tName\tVersion\tState")
-		fmt.Fprintln(output, "----------------------------------------------------")
+		w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tName\tVersion\tState\tAutostart\tRestart\tRestarts\tLast Error")
 
 		for _, plug := range plugins {
-			if plug.Type() == plugin.ServicePlugin {
-				var stateStr string
-				if plug.State() == 0 {
-					stateStr = "Disabled"
-				} else if plug.State() == 1 {
-					stateStr = "Enabled"
-				} else if plug.State() == 2 {
-					stateStr = "Running"
-				} else if plug.State() == 3 {
-					stateStr = "Paused"
-				} else {
-					stateStr = "Unknown"
-				}
-
-				fmt.Fprintf(output, "%s\t%s\t%s\t%s\n", plug.ID(), plug.Name(), plug.Version(), stateStr)
+			if plug.Type() != plugin.ServicePlugin {
+				continue
 			}
+			status, err := p.pluginManager.GetServiceStatus(plug.ID())
+			if err != nil {
+				continue
+			}
+			lastError := status.LastError
+			if lastError == "" {
+				lastError = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%d\t%s\n", plug.ID(), plug.Name(), plug.Version(),
+				stateName(status.State), status.Policy.Autostart, status.Policy.RestartPolicy(), status.Restarts, lastError)
 		}
 
-		return nil
+		return w.Flush()
 	}
 
 	// 获取指定插件的状态
@@ -174,56 +222,91 @@ func (p *PluginManagerPlugin) serviceStatus(ctx context.Context, args []string,
 		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
 	}
 
-	// 获取服务插件
-	servicePlugin, err := p.pluginManager.GetServicePlugin(pluginID)
+	status, err := p.pluginManager.GetServiceStatus(pluginID)
 	if err != nil {
-		return fmt.Errorf("failed to get service plugin: %w", err)
+		return fmt.Errorf("failed to get service status: %w", err)
 	}
 
-	// 获取状态
-	var stateStr string
-	switch servicePlugin.State() {
+	// 输出状态信息
+	fmt.Fprintf(output, "Service Plugin: %s (%s)\n", plug.Name(), plug.ID())
+	fmt.Fprintf(output, "Version: %s\n", plug.Version())
+	fmt.Fprintf(output, "State: %s\n", stateName(status.State))
+	fmt.Fprintf(output, "Supervised: %t\n", status.Supervised)
+	fmt.Fprintf(output, "Autostart: %t\n", status.Policy.Autostart)
+	fmt.Fprintf(output, "Restart Policy: %s\n", status.Policy.RestartPolicy())
+	if !status.StartedAt.IsZero() {
+		fmt.Fprintf(output, "Started At: %s\n", status.StartedAt.Format(time.RFC3339))
+	}
+	fmt.Fprintf(output, "Restarts: %d\n", status.Restarts)
+	if status.LastError != "" {
+		fmt.Fprintf(output, "Last Error: %s (%s)\n", status.LastError, status.LastErrorAt.Format(time.RFC3339))
+	}
+	if !status.NextRestart.IsZero() {
+		fmt.Fprintf(output, "Next Restart: %s\n", status.NextRestart.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// stateName 返回插件状态名称
+func stateName(state plugin.PluginState) string {
+	switch state {
 	case plugin.Disabled:
-		stateStr = "Disabled"
+		return "Disabled"
 	case plugin.Enabled:
-		stateStr = "Enabled"
+		return "Enabled"
 	case plugin.Running:
-		stateStr = "Running"
+		return "Running"
 	case plugin.Paused:
-		stateStr = "Paused"
+		return "Paused"
 	default:
-		stateStr = "Unknown"
+		return "Unknown"
 	}
-
-	// 输出状态信息
-	fmt.Fprintf(output, "Service Plugin: %s (%s)\n", servicePlugin.Name(), servicePlugin.ID())
-	fmt.Fprintf(output, "Version: %s\n", servicePlugin.Version())
-	fmt.Fprintf(output, "State: %s\n", stateStr)
-
-	return nil
 }
 
-// configService 配置服务
+// configService 查看或更新插件配置
 func (p *PluginManagerPlugin) configService(ctx context.Context, args []string, output io.Writer) error {
 	if p.pluginManager == nil {
 		return fmt.Errorf("plugin manager not initialized")
 	}
 
 	if len(args) < 1 {
-		return fmt.Errorf("usage: config <plugin_id> [config_file]")
+		return fmt.Errorf("usage: config <plugin_id> [config_file | --schema]")
 	}
 
 	pluginID := args[0]
 
-	// 获取插件
-	plug, err := p.pluginManager.GetPlugin(pluginID)
-	if err != nil {
+	// 检查插件是否存在
+	if _, err := p.pluginManager.GetPlugin(pluginID); err != nil {
 		return fmt.Errorf("failed to get plugin: %w", err)
 	}
 
-	// 检查插件类型
-	if plug.Type() != plugin.ServicePlugin {
-		return fmt.Errorf("plugin %s is not a service plugin", pluginID)
+	// 显示配置结构
+	if len(args) > 1 && args[1] == "--schema" {
+		schema, ok, err := p.pluginManager.GetConfigSchema(pluginID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintf(output, "Plugin %s does not publish a configuration schema\n", pluginID)
+			return nil
+		}
+
+		fmt.Fprintf(output, "Configuration schema for plugin %s:\n", pluginID)
+		w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FIELD\tTYPE\tREQUIRED\tDESCRIPTION")
+		for _, field := range schema.Fields {
+			desc := field.Description
+			if len(field.Enum) > 0 {
+				desc = strings.TrimSpace(desc + " (one of: " + strings.Join(field.Enum, ", ") + ")")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", field.Name, field.Type, field.Required, desc)
+		}
+		w.Flush()
+		if schema.AllowUnknown {
+			fmt.Fprintln(output, "Unknown fields are allowed")
+		}
+		return nil
 	}
 
 	// 如果没有指定配置文件，显示当前配置
@@ -252,13 +335,96 @@ func (p *PluginManagerPlugin) configService(ctx context.Context, args []string,
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// 保存新配置
-	destPath := filepath.Join(p.configDir, pluginID+".yml")
-	if err := os.WriteFile(destPath, configData, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	// 校验、保存并应用新配置
+	applied, err := p.pluginManager.ReconfigurePlugin(pluginID, configData)
+	if err != nil {
+		return err
 	}
 
 	fmt.Fprintf(output, "Configuration for plugin %s updated successfully\n", pluginID)
-	fmt.Fprintln(output, "Restart the plugin to apply the new configuration")
+	if applied {
+		fmt.Fprintln(output, "The new configuration has been applied")
+	} else {
+		fmt.Fprintln(output, "Restart the plugin to apply the new configuration")
+	}
 	return nil
 }
+
+// showMetrics 显示插件注册的指标
+func (p *PluginManagerPlugin) showMetrics(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE\tDESCRIPTION")
+	for _, m := range p.pluginManager.Metrics() {
+		if len(args) > 0 && m.Plugin != args[0] {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%g\t%s\n", m.Name, m.Value, m.Help)
+	}
+	return w.Flush()
+}
+
+// showHealth 显示插件的健康报告，--json输出完整的报告
+func (p *PluginManagerPlugin) showHealth(ctx context.Context, args []string, output io.Writer) error {
+	if p.pluginManager == nil {
+		return fmt.Errorf("plugin manager not initialized")
+	}
+
+	var pluginID string
+	asJSON := false
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+			continue
+		}
+		pluginID = arg
+	}
+
+	report := p.pluginManager.HealthReport()
+	if pluginID != "" {
+		var entries []plugin.PluginHealth
+		for _, entry := range report.Plugins {
+			if entry.ID == pluginID {
+				entries = append(entries, entry)
+				report.Status = entry.Status
+			}
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("plugin %s is not enabled", pluginID)
+		}
+		report.Plugins = entries
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal health report: %w", err)
+		}
+		fmt.Fprintf(output, "%s\n", data)
+		return nil
+	}
+
+	fmt.Fprintf(output, "Status: %s\n", report.Status)
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tHEALTH\tCHECKED\tMESSAGE")
+	for _, entry := range report.Plugins {
+		message := entry.Message
+		if message == "" {
+			message = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.State, entry.Status,
+			entry.CheckedAt.Format(time.RFC3339), message)
+		names := make([]string, 0, len(entry.Details))
+		for name := range entry.Details {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "\t\t\t\t  %s: %s\n", name, entry.Details[name])
+		}
+	}
+	return w.Flush()
+}
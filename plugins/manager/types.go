@@ -1,4 +1,4 @@
-package main
+package manager
 
 import (
 	"github.com/sorc/tcpserver/pkg/plugin"
@@ -10,10 +10,13 @@ type PluginManagerPlugin struct {
 	pluginManager plugin.PluginManager
 	pluginsDir    string
 	configDir     string
+	repository    string
 }
 
 // Config 插件配置
 type Config struct {
 	PluginsDir string `yaml:"plugins_dir"`
 	ConfigDir  string `yaml:"config_dir"`
+	// Repository 插件仓库目录或HTTP地址，也可以直接指向索引文件
+	Repository string `yaml:"repository"`
 }
@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxUploadSize 客户端上传的插件包大小上限
+const maxUploadSize = 512 << 20
+
+// upload 客户端随命令上传的插件包，内容通过命令输入传输
+type upload struct {
+	name   string
+	size   int64
+	sha256 string
+}
+
+// parseUpload 从参数中移除--upload、--size和--sha256，未指定--upload时返回nil
+func parseUpload(args []string) ([]string, *upload, error) {
+	var rest []string
+	var u upload
+	var hasUpload, hasSize bool
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--upload", "--size", "--sha256":
+		default:
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("%s requires a value", args[i])
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--upload":
+			u.name = value
+			hasUpload = true
+		case "--size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid size: %s", value)
+			}
+			u.size = size
+			hasSize = true
+		case "--sha256":
+			u.sha256 = strings.ToLower(value)
+		}
+		i++
+	}
+
+	if !hasUpload {
+		return rest, nil, nil
+	}
+	if u.name != filepath.Base(u.name) || !isPackage(u.name) {
+		return nil, nil, fmt.Errorf("only plugin packages (.tar.gz) can be uploaded: %s", u.name)
+	}
+	if !hasSize || u.size <= 0 || u.size > maxUploadSize {
+		return nil, nil, fmt.Errorf("upload requires --size between 1 and %d bytes", maxUploadSize)
+	}
+	if sum, err := hex.DecodeString(u.sha256); err != nil || len(sum) != sha256.Size {
+		return nil, nil, fmt.Errorf("upload requires a valid --sha256 checksum")
+	}
+	return rest, &u, nil
+}
+
+// receive 从命令输入接收插件包并写入目录dir，校验大小和SHA-256后返回文件路径，失败时删除已接收的部分
+func (u *upload) receive(input io.Reader, dir string) (string, error) {
+	if input == nil {
+		return "", fmt.Errorf("no package data received, the client must stream %s as command input", u.name)
+	}
+
+	file, err := os.CreateTemp(dir, ".upload-*-"+u.name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload file: %w", err)
+	}
+	path := file.Name()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(input, u.size+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failed to receive %s: %w", u.name, err)
+	case n != u.size:
+		err = fmt.Errorf("failed to receive %s: got %d bytes, expected %d", u.name, n, u.size)
+	case hex.EncodeToString(hash.Sum(nil)) != u.sha256:
+		err = fmt.Errorf("failed to receive %s: sha256 checksum mismatch", u.name)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sha256Hex 计算数据的SHA-256校验和
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseUpload(t *testing.T) {
+	sum := sha256Hex([]byte("package"))
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantRest []string
+		want     *upload
+		wantErr  bool
+	}{
+		{name: "no upload", args: []string{"file", "--force"}, wantRest: []string{"file", "--force"}},
+		{
+			name:     "upload",
+			args:     []string{"file", "--upload", "p.tar.gz", "--size", "7", "--sha256", strings.ToUpper(sum), "--health-check", "5"},
+			wantRest: []string{"file", "--health-check", "5"},
+			want:     &upload{name: "p.tar.gz", size: 7, sha256: sum},
+		},
+		{name: "tgz", args: []string{"--upload", "p.tgz", "--size", "7", "--sha256", sum}, want: &upload{name: "p.tgz", size: 7, sha256: sum}},
+		{name: "missing value", args: []string{"--upload", "p.tar.gz", "--size"}, wantErr: true},
+		{name: "not a package", args: []string{"--upload", "p.so", "--size", "7", "--sha256", sum}, wantErr: true},
+		{name: "path in name", args: []string{"--upload", "../p.tar.gz", "--size", "7", "--sha256", sum}, wantErr: true},
+		{name: "absolute name", args: []string{"--upload", "/tmp/p.tar.gz", "--size", "7", "--sha256", sum}, wantErr: true},
+		{name: "missing size", args: []string{"--upload", "p.tar.gz", "--sha256", sum}, wantErr: true},
+		{name: "invalid size", args: []string{"--upload", "p.tar.gz", "--size", "x", "--sha256", sum}, wantErr: true},
+		{name: "zero size", args: []string{"--upload", "p.tar.gz", "--size", "0", "--sha256", sum}, wantErr: true},
+		{name: "negative size", args: []string{"--upload", "p.tar.gz", "--size", "-1", "--sha256", sum}, wantErr: true},
+		{name: "size over limit", args: []string{"--upload", "p.tar.gz", "--size", fmt.Sprint(maxUploadSize + 1), "--sha256", sum}, wantErr: true},
+		{name: "missing sha256", args: []string{"--upload", "p.tar.gz", "--size", "7"}, wantErr: true},
+		{name: "short sha256", args: []string{"--upload", "p.tar.gz", "--size", "7", "--sha256", sum[:10]}, wantErr: true},
+		{name: "invalid sha256", args: []string{"--upload", "p.tar.gz", "--size", "7", "--sha256", strings.Repeat("z", 64)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, u, err := parseUpload(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseUpload(%v) = %+v, expected error", tt.args, u)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUpload(%v): %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("rest = %v, want %v", rest, tt.wantRest)
+			}
+			if !reflect.DeepEqual(u, tt.want) {
+				t.Errorf("upload = %+v, want %+v", u, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadReceive(t *testing.T) {
+	data := []byte("plugin package contents")
+
+	tests := []struct {
+		name    string
+		upload  upload
+		input   io.Reader
+		wantErr bool
+	}{
+		{name: "complete", upload: upload{name: "p.tar.gz", size: int64(len(data)), sha256: sha256Hex(data)}, input: bytes.NewReader(data)},
+		{name: "no input", upload: upload{name: "p.tar.gz", size: int64(len(data)), sha256: sha256Hex(data)}, wantErr: true},
+		{name: "truncated", upload: upload{name: "p.tar.gz", size: int64(len(data)), sha256: sha256Hex(data)}, input: bytes.NewReader(data[:5]), wantErr: true},
+		{name: "oversized", upload: upload{name: "p.tar.gz", size: 5, sha256: sha256Hex(data[:5])}, input: bytes.NewReader(data), wantErr: true},
+		{name: "checksum mismatch", upload: upload{name: "p.tar.gz", size: int64(len(data)), sha256: sha256Hex([]byte("other"))}, input: bytes.NewReader(data), wantErr: true},
+		{name: "read error", upload: upload{name: "p.tar.gz", size: int64(len(data)), sha256: sha256Hex(data)}, input: io.MultiReader(bytes.NewReader(data[:5]), errReader{}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path, err := tt.upload.receive(tt.input, dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("receive = %s, expected error", path)
+				}
+				// 失败时不保留已接收的部分
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("partial upload left behind: %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("receive: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("received %q, want %q", got, data)
+			}
+			if !strings.HasSuffix(path, tt.upload.name) {
+				t.Errorf("path %s does not keep the package name %s", path, tt.upload.name)
+			}
+		})
+	}
+}
+
+// errReader 读取时返回错误，模拟传输中断
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,28 @@
+// proxy插件的独立构建入口：
+//   - 动态插件：go build -buildmode=plugin -o plugins/proxy.so ./plugins/proxy/cmd
+//   - 进程插件：go build -o plugins/proxy ./plugins/proxy/cmd
+package main
+
+import (
+	"log"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+	"github.com/sorc/tcpserver/plugins/proxy"
+)
+
+// CreateServicePlugin 创建服务类插件实例
+func CreateServicePlugin() plugin.IServicePlugin {
+	return proxy.CreateServicePlugin()
+}
+
+// CreatePlugin 创建插件实例
+func CreatePlugin() plugin.Plugin {
+	return proxy.CreatePlugin()
+}
+
+// main 作为进程插件运行，编译为.so时不会执行
+func main() {
+	if err := plugin.Serve(CreatePlugin()); err != nil {
+		log.Fatal(err)
+	}
+}
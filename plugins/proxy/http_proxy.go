@@ -1,4 +1,4 @@
-package main
+package proxy
 
 import (
 	"context"
@@ -18,11 +18,13 @@ func (h *HTTPProxy) Start(ctx context.Context) error {
 	}
 
 	// 创建监听器
-	listener, err := net.Listen("tcp", h.addr)
+	ln, err := net.Listen("tcp", h.addr)
 	if err != nil {
 		return err
 	}
+	listener := &pausableListener{Listener: ln, paused: &h.paused}
 	h.listener = listener
+	h.err = nil
 
 	// 创建HTTP服务器
 	h.server = &http.Server{
@@ -30,17 +32,78 @@ func (h *HTTPProxy) Start(ctx context.Context) error {
 	}
 
 	// 启动HTTP服务器
-	go func() {
-		h.server.Serve(listener)
-	}()
+	go h.serve(h.server, listener)
 
 	return nil
 }
 
+// serve 运行HTTP服务器，监听器意外关闭时记录原因
+func (h *HTTPProxy) serve(server *http.Server, listener net.Listener) {
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		h.fail(listener, err)
+	}
+}
+
+// Rebind 切换监听地址。运行中时先在新地址上监听，成功后再关闭旧的监听器，失败时继续使用原地址
+func (h *HTTPProxy) Rebind(ctx context.Context, addr string) error {
+	h.mu.Lock()
+	if h.addr == addr || h.listener == nil {
+		h.addr = addr
+		h.mu.Unlock()
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	listener := &pausableListener{Listener: ln, paused: &h.paused}
+	oldServer, oldListener := h.server, h.listener
+	h.addr = addr
+	h.listener = listener
+	h.err = nil
+	h.server = &http.Server{
+		Handler: http.HandlerFunc(h.handleHTTP),
+	}
+	go h.serve(h.server, listener)
+	h.mu.Unlock()
+
+	// 在锁外关闭旧的服务器，等待正在处理的请求完成
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oldServer.Shutdown(shutdownCtx)
+	oldListener.Close()
+
+	return nil
+}
+
+// fail 记录监听器意外关闭的原因，代理转为停止状态
+func (h *HTTPProxy) fail(listener net.Listener, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.listener != listener {
+		return
+	}
+	h.err = err
+	h.listener.Close()
+	h.listener = nil
+	h.server = nil
+}
+
+// Failed 返回监听器意外关闭的原因
+func (h *HTTPProxy) Failed() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
 // Stop 停止HTTP代理
 func (h *HTTPProxy) Stop() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.paused.Store(false)
 
 	if h.server != nil {
 		// 设置关闭超时
@@ -65,6 +128,31 @@ func (h *HTTPProxy) IsRunning() bool {
 	return h.listener != nil
 }
 
+// ListenAddr 返回HTTP代理实际监听的地址，未运行时返回空字符串
+func (h *HTTPProxy) ListenAddr() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener == nil {
+		return ""
+	}
+	return h.listener.Addr().String()
+}
+
+// Pause 暂停HTTP代理，拒绝新连接，已建立的连接不受影响
+func (h *HTTPProxy) Pause() {
+	h.paused.Store(true)
+}
+
+// Resume 恢复接受新连接
+func (h *HTTPProxy) Resume() {
+	h.paused.Store(false)
+}
+
+// IsPaused 检查HTTP代理是否已暂停
+func (h *HTTPProxy) IsPaused() bool {
+	return h.paused.Load()
+}
+
 // handleHTTP 处理HTTP代理请求
 func (h *HTTPProxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodConnect {
@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// pausableListener 暂停时接受新连接后立即关闭，不影响已建立的连接，监听地址在暂停期间保持占用
+type pausableListener struct {
+	net.Listener
+	paused *atomic.Bool
+}
+
+// Accept 接受连接，暂停期间拒绝新连接
+func (l *pausableListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.paused.Load() {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
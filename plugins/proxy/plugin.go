@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	plugin.Register(plugin.PluginMetadata{
+		ID:          "proxy",
+		Name:        "Proxy Service",
+		Version:     "1.0.0",
+		Type:        plugin.ServicePlugin,
+		Description: "HTTP、SOCKS4和SOCKS5代理服务",
+	}, CreatePlugin)
+}
+
+// CreateServicePlugin 创建服务类插件实例
+func CreateServicePlugin() plugin.IServicePlugin {
+	return &ProxyPlugin{
+		BaseServicePlugin: plugin.NewBaseServicePlugin("proxy", "Proxy Service", "1.0.0"),
+	}
+}
+
+// CreatePlugin 创建插件实例
+func CreatePlugin() plugin.Plugin {
+	return CreateServicePlugin()
+}
+
+// Init 初始化插件
+func (p *ProxyPlugin) Init(ctx context.Context, configBytes []byte) error {
+	if err := p.BaseServicePlugin.Init(ctx, configBytes); err != nil {
+		return err
+	}
+
+	// 解析配置
+	config, err := parseConfig(configBytes)
+	if err != nil {
+		return err
+	}
+
+	p.config = config
+
+	// 创建代理服务
+	p.httpProxy = &HTTPProxy{
+		addr: config.HTTPAddr,
+	}
+	p.socksProxy = &SocksProxy{
+		addr: config.SocksAddr,
+	}
+
+	return nil
+}
+
+// ConfigSchema 返回插件配置的结构描述
+func (p *ProxyPlugin) ConfigSchema() plugin.ConfigSchema {
+	return plugin.ConfigSchema{
+		Fields: []plugin.ConfigField{
+			{Name: "http_addr", Type: plugin.FieldAddr, Description: "HTTP代理监听地址，默认:8080"},
+			{Name: "socks_addr", Type: plugin.FieldAddr, Description: "SOCKS代理监听地址，默认:1080"},
+		},
+	}
+}
+
+// Reconfigure 应用新配置，运行中的代理切换到新的监听地址，任一代理切换失败时恢复原地址
+func (p *ProxyPlugin) Reconfigure(ctx context.Context, configBytes []byte) error {
+	config, err := parseConfig(configBytes)
+	if err != nil {
+		return err
+	}
+
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+
+	if err := p.httpProxy.Rebind(ctx, config.HTTPAddr); err != nil {
+		return fmt.Errorf("failed to rebind HTTP proxy to %s: %w", config.HTTPAddr, err)
+	}
+	if err := p.socksProxy.Rebind(ctx, config.SocksAddr); err != nil {
+		if rerr := p.httpProxy.Rebind(ctx, p.config.HTTPAddr); rerr != nil {
+			return fmt.Errorf("failed to rebind SOCKS proxy to %s: %w (HTTP proxy not restored: %v)", config.SocksAddr, err, rerr)
+		}
+		return fmt.Errorf("failed to rebind SOCKS proxy to %s: %w", config.SocksAddr, err)
+	}
+
+	p.config = config
+	return nil
+}
+
+// parseConfig 解析配置并设置默认值
+func parseConfig(configBytes []byte) (Config, error) {
+	var config Config
+	if len(configBytes) > 0 {
+		if err := yaml.Unmarshal(configBytes, &config); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	// 设置默认值
+	if config.HTTPAddr == "" {
+		config.HTTPAddr = ":8080"
+	}
+	if config.SocksAddr == "" {
+		config.SocksAddr = ":1080"
+	}
+
+	return config, nil
+}
@@ -1,11 +1,14 @@
-package main
+package proxy
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"strings"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
 // GetCommands 获取支持的命令列表
@@ -17,6 +20,30 @@ func (p *ProxyPlugin) GetCommands() []string {
 	}
 }
 
+// DescribeCommands 返回命令的参数和输出描述
+func (p *ProxyPlugin) DescribeCommands() []plugin.CommandDescriptor {
+	proxyType := plugin.CommandArg{Name: "proxy_type", Type: plugin.FieldString, Required: true, Enum: []string{"http", "socks"}}
+	return []plugin.CommandDescriptor{
+		{
+			Name:        "status",
+			Description: "Show proxy listeners and their addresses",
+			Output:      plugin.CommandOutput{Format: plugin.OutputText},
+		},
+		{
+			Name:        "start",
+			Description: "Start a proxy listener",
+			Args:        []plugin.CommandArg{proxyType},
+			Output:      plugin.CommandOutput{Format: plugin.OutputText},
+		},
+		{
+			Name:        "stop",
+			Description: "Stop a proxy listener",
+			Args:        []plugin.CommandArg{proxyType},
+			Output:      plugin.CommandOutput{Format: plugin.OutputText},
+		},
+	}
+}
+
 // Execute 执行命令
 func (p *ProxyPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if len(args) == 0 {
@@ -50,6 +77,10 @@ func (p *ProxyPlugin) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to start SOCKS proxy: %w", err)
 	}
 
+	// 启动暂停的服务时恢复接受新连接
+	p.httpProxy.Resume()
+	p.socksProxy.Resume()
+
 	return p.BaseServicePlugin.Start(ctx)
 }
 
@@ -62,19 +93,128 @@ func (p *ProxyPlugin) Stop() error {
 	return p.BaseServicePlugin.Stop()
 }
 
+// Pause 暂停服务，代理拒绝新连接，已建立的连接和隧道继续转发
+func (p *ProxyPlugin) Pause() error {
+	p.httpProxy.Pause()
+	p.socksProxy.Pause()
+
+	if err := p.BaseServicePlugin.Pause(); err != nil {
+		// 服务未启动，撤销代理的暂停
+		p.httpProxy.Resume()
+		p.socksProxy.Resume()
+		return err
+	}
+	return nil
+}
+
+// Resume 恢复服务，代理重新接受新连接
+func (p *ProxyPlugin) Resume() error {
+	p.httpProxy.Resume()
+	p.socksProxy.Resume()
+
+	return p.BaseServicePlugin.Resume()
+}
+
+// Failed 返回代理监听器意外关闭的原因，供服务监管程序判断是否需要重启
+func (p *ProxyPlugin) Failed() error {
+	if err := p.httpProxy.Failed(); err != nil {
+		return fmt.Errorf("HTTP proxy: %w", err)
+	}
+	if err := p.socksProxy.Failed(); err != nil {
+		return fmt.Errorf("SOCKS proxy: %w", err)
+	}
+	return nil
+}
+
+// CheckHealth 检查代理监听器是否仍可连接。服务运行中但监听器已关闭或无法连接时，
+// 部分代理不可用为degraded，全部不可用为unhealthy
+func (p *ProxyPlugin) CheckHealth(ctx context.Context) plugin.Health {
+	switch p.State() {
+	case plugin.Running:
+	case plugin.Paused:
+		return plugin.Health{Status: plugin.HealthHealthy, Message: "service is paused"}
+	default:
+		return plugin.Health{Status: plugin.HealthHealthy, Message: "service is not running"}
+	}
+
+	proxies := []struct {
+		name   string
+		addr   string
+		failed error
+	}{
+		{"http", p.httpProxy.ListenAddr(), p.httpProxy.Failed()},
+		{"socks", p.socksProxy.ListenAddr(), p.socksProxy.Failed()},
+	}
+
+	health := plugin.Health{Details: make(map[string]string)}
+	down := 0
+	for _, proxy := range proxies {
+		if err := probe(ctx, proxy.addr, proxy.failed); err != nil {
+			health.Details[proxy.name] = err.Error()
+			down++
+			continue
+		}
+		health.Details[proxy.name] = "listening on " + proxy.addr
+	}
+
+	switch down {
+	case 0:
+		health.Status = plugin.HealthHealthy
+	case len(proxies):
+		health.Status = plugin.HealthUnhealthy
+		health.Message = "no proxy listener is reachable"
+	default:
+		health.Status = plugin.HealthDegraded
+		health.Message = "some proxy listeners are not reachable"
+	}
+	return health
+}
+
+// probe 连接代理监听器检查其是否可用，监听在未指定地址上时连接本机地址
+func probe(ctx context.Context, addr string, failed error) error {
+	if failed != nil {
+		return fmt.Errorf("listener closed: %v", failed)
+	}
+	if addr == "" {
+		return fmt.Errorf("not listening")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("not reachable: %v", err)
+	}
+	conn.Close()
+	return nil
+}
+
 // getStatus 获取代理状态
 func (p *ProxyPlugin) getStatus(ctx context.Context, output io.Writer) error {
+	p.configMu.Lock()
+	config := p.config
+	p.configMu.Unlock()
+
 	// 获取各代理服务状态
 	status := []ProxyStatus{
 		{
 			Type:    "HTTP",
-			Addr:    p.config.HTTPAddr,
+			Addr:    config.HTTPAddr,
 			Running: p.httpProxy.IsRunning(),
+			Paused:  p.httpProxy.IsPaused(),
 		},
 		{
 			Type:    "SOCKS",
-			Addr:    p.config.SocksAddr,
+			Addr:    config.SocksAddr,
 			Running: p.socksProxy.IsRunning(),
+			Paused:  p.socksProxy.IsPaused(),
 		},
 	}
 
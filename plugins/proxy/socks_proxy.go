@@ -1,4 +1,4 @@
-package main
+package proxy
 
 import (
 	"context"
@@ -18,17 +18,19 @@ func (s *SocksProxy) Start(ctx context.Context) error {
 	}
 
 	// 创建监听器
-	listener, err := net.Listen("tcp", s.addr)
+	ln, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return err
 	}
+	listener := &pausableListener{Listener: ln, paused: &s.paused}
 	s.listener = listener
+	s.err = nil
 
 	// 创建上下文
 	s.ctx, s.cancel = context.WithCancel(ctx)
 
 	// 启动代理服务
-	go s.serve()
+	go s.serve(s.ctx, listener)
 
 	return nil
 }
@@ -37,6 +39,7 @@ func (s *SocksProxy) Start(ctx context.Context) error {
 func (s *SocksProxy) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.paused.Store(false)
 
 	if s.cancel != nil {
 		s.cancel()
@@ -49,6 +52,37 @@ func (s *SocksProxy) Stop() {
 	}
 }
 
+// Rebind 切换监听地址。运行中时先在新地址上监听，成功后再关闭旧的监听器，失败时继续使用原地址
+func (s *SocksProxy) Rebind(ctx context.Context, addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.addr == addr || s.listener == nil {
+		s.addr = addr
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	listener := &pausableListener{Listener: ln, paused: &s.paused}
+
+	// 停止旧的监听器，已建立的连接不受影响
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.listener.Close()
+
+	s.addr = addr
+	s.listener = listener
+	s.err = nil
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	go s.serve(s.ctx, listener)
+
+	return nil
+}
+
 // IsRunning 检查SOCKS代理是否运行中
 func (s *SocksProxy) IsRunning() bool {
 	s.mu.Lock()
@@ -56,20 +90,57 @@ func (s *SocksProxy) IsRunning() bool {
 	return s.listener != nil
 }
 
+// ListenAddr 返回SOCKS代理实际监听的地址，未运行时返回空字符串
+func (s *SocksProxy) ListenAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Pause 暂停SOCKS代理，拒绝新连接，已建立的连接不受影响
+func (s *SocksProxy) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume 恢复接受新连接
+func (s *SocksProxy) Resume() {
+	s.paused.Store(false)
+}
+
+// IsPaused 检查SOCKS代理是否已暂停
+func (s *SocksProxy) IsPaused() bool {
+	return s.paused.Load()
+}
+
+// Failed 返回监听器意外关闭的原因
+func (s *SocksProxy) Failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
 // serve 运行SOCKS代理服务
-func (s *SocksProxy) serve() {
+func (s *SocksProxy) serve(ctx context.Context, listener net.Listener) {
 	for {
 		// 接受连接
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			select {
-			case <-s.ctx.Done():
+			case <-ctx.Done():
 				// 服务已停止
 				return
 			default:
-				// 继续接受连接
+			}
+
+			// 临时错误继续接受连接，其他错误说明监听器已不可用
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
 				continue
 			}
+			s.fail(listener, err)
+			return
 		}
 
 		// 处理连接
@@ -77,6 +148,23 @@ func (s *SocksProxy) serve() {
 	}
 }
 
+// fail 记录监听器意外关闭的原因，代理转为停止状态
+func (s *SocksProxy) fail(listener net.Listener, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != listener {
+		return
+	}
+	s.err = err
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.listener.Close()
+	s.listener = nil
+}
+
 // handleConnection 处理SOCKS连接
 func (s *SocksProxy) handleConnection(conn net.Conn) {
 	defer conn.Close()
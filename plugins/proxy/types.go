@@ -1,10 +1,11 @@
-package main
+package proxy
 
 import (
 	"context"
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/sorc/tcpserver/pkg/plugin"
 )
@@ -15,6 +16,7 @@ type ProxyPlugin struct {
 	httpProxy  *HTTPProxy
 	socksProxy *SocksProxy
 	config     Config
+	configMu   sync.Mutex // 保护config，同时保证配置变更依次执行
 }
 
 // HTTPProxy HTTP代理服务
@@ -22,6 +24,8 @@ type HTTPProxy struct {
 	server   *http.Server
 	addr     string
 	listener net.Listener
+	err      error // 监听器意外关闭的原因
+	paused   atomic.Bool
 	mu       sync.Mutex
 }
 
@@ -31,6 +35,8 @@ type SocksProxy struct {
 	listener net.Listener
 	ctx      context.Context
 	cancel   context.CancelFunc
+	err      error // 监听器意外关闭的原因
+	paused   atomic.Bool
 	mu       sync.Mutex
 }
 
@@ -45,4 +51,5 @@ type ProxyStatus struct {
 	Type    string `json:"type"`
 	Addr    string `json:"addr"`
 	Running bool   `json:"running"`
+	Paused  bool   `json:"paused,omitempty"`
 }
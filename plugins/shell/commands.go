@@ -1,9 +1,11 @@
-package main
+package shell
 
 import (
 	"context"
 	"fmt"
 	"io"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
 // GetCommands 获取支持的命令列表
@@ -14,6 +16,26 @@ func (p *ShellPlugin) GetCommands() []string {
 	}
 }
 
+// DescribeCommands 返回命令的参数和输出描述
+func (p *ShellPlugin) DescribeCommands() []plugin.CommandDescriptor {
+	return []plugin.CommandDescriptor{
+		{
+			Name:        "exec",
+			Description: "Execute a shell command and return its output",
+			Args: []plugin.CommandArg{
+				{Name: "command", Type: plugin.FieldString, Required: true, Variadic: true, Description: "Command line passed to the shell"},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputText, Description: "Combined stdout and stderr of the command"},
+		},
+		{
+			Name:        "interactive",
+			Description: "Start an interactive shell",
+			Output:      plugin.CommandOutput{Format: plugin.OutputText},
+			Interactive: true,
+		},
+	}
+}
+
 // Execute 执行命令
 func (p *ShellPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if len(args) == 0 {
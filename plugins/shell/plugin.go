@@ -1,4 +1,4 @@
-package main
+package shell
 
 import (
 	"context"
@@ -8,6 +8,16 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+func init() {
+	plugin.Register(plugin.PluginMetadata{
+		ID:          "shell",
+		Name:        "Shell Executor",
+		Version:     "1.0.0",
+		Type:        plugin.CommandPlugin,
+		Description: "执行服务器Shell命令",
+	}, CreatePlugin)
+}
+
 // CreateCommandPlugin 创建命令类插件实例
 func CreateCommandPlugin() plugin.ICommandPlugin {
 	return &ShellPlugin{
@@ -44,5 +54,3 @@ func (p *ShellPlugin) Init(ctx context.Context, configBytes []byte) error {
 
 	return nil
 }
-
-func main() {}
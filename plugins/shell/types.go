@@ -1,4 +1,4 @@
-package main
+package shell
 
 import (
 	"github.com/sorc/tcpserver/pkg/plugin"
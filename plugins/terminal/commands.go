@@ -1,9 +1,11 @@
-package main
+package terminal
 
 import (
 	"context"
 	"fmt"
 	"io"
+
+	"github.com/sorc/tcpserver/pkg/plugin"
 )
 
 // GetCommands 获取支持的命令列表
@@ -18,6 +20,63 @@ func (p *TerminalPlugin) GetCommands() []string {
 	}
 }
 
+// DescribeCommands 返回命令的参数和输出描述
+func (p *TerminalPlugin) DescribeCommands() []plugin.CommandDescriptor {
+	terminalID := plugin.CommandArg{Name: "terminal_id", Type: plugin.FieldString, Required: true}
+	return []plugin.CommandDescriptor{
+		{
+			Name:        "create",
+			Description: "Create a terminal running a command",
+			Args: []plugin.CommandArg{
+				{Name: "request_json", Type: plugin.FieldMap, Required: true, Description: `{"id": "...", "command": "...", "args": [...]}`},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+		{
+			Name:        "list",
+			Description: "List terminals",
+			Output:      plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+		{
+			Name:        "kill",
+			Description: "Kill a terminal",
+			Args:        []plugin.CommandArg{terminalID},
+			Output:      plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+		{
+			Name:        "resize",
+			Description: "Resize a terminal",
+			Args: []plugin.CommandArg{
+				terminalID,
+				{Name: "rows", Type: plugin.FieldInt, Required: true},
+				{Name: "cols", Type: plugin.FieldInt, Required: true},
+			},
+			Output: plugin.CommandOutput{Format: plugin.OutputJSON},
+		},
+		{
+			Name:        "write",
+			Description: "Write data to a terminal",
+			Args: []plugin.CommandArg{
+				{Name: "request_json", Type: plugin.FieldMap, Required: true, Description: `{"id": "...", "data": "..."}`},
+			},
+			Output: plugin.CommandOutput{
+				Format: plugin.OutputJSON,
+				Fields: []plugin.ConfigField{
+					{Name: "success", Type: plugin.FieldBool},
+					{Name: "id", Type: plugin.FieldString},
+					{Name: "bytes_written", Type: plugin.FieldInt},
+				},
+			},
+		},
+		{
+			Name:        "read",
+			Description: "Read buffered output of a terminal",
+			Args:        []plugin.CommandArg{terminalID},
+			Output:      plugin.CommandOutput{Format: plugin.OutputText},
+		},
+	}
+}
+
 // Execute 执行命令
 func (p *TerminalPlugin) Execute(ctx context.Context, args []string, input io.Reader, output io.Writer) error {
 	if len(args) == 0 {
@@ -1,4 +1,4 @@
-package main
+package terminal
 
 import (
 	"context"
@@ -8,6 +8,16 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+func init() {
+	plugin.Register(plugin.PluginMetadata{
+		ID:          "terminal",
+		Name:        "Terminal Manager",
+		Version:     "1.0.0",
+		Type:        plugin.CommandPlugin,
+		Description: "管理远程终端",
+	}, CreatePlugin)
+}
+
 // CreateCommandPlugin 创建命令类插件实例
 func CreateCommandPlugin() plugin.ICommandPlugin {
 	return &TerminalPlugin{
@@ -44,5 +54,3 @@ func (p *TerminalPlugin) Init(ctx context.Context, configBytes []byte) error {
 
 	return nil
 }
-
-func main() {}
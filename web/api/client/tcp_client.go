@@ -43,6 +43,10 @@ const (
 	DataRequest MessageType = 5
 	// ErrorResponse 错误响应
 	ErrorResponse MessageType = 6
+	// DescribeRequest 命令描述请求
+	DescribeRequest MessageType = 11
+	// DescribeResponse 命令描述响应
+	DescribeResponse MessageType = 12
 )
 
 // NewTCPClient 创建TCP客户端
@@ -213,6 +217,63 @@ func (c *TCPClient) ExecuteCommand(plugin, command string, args []string) (strin
 	}
 }
 
+// DescribeCommands 获取插件命令的描述，plugin为空时返回所有插件，结果为服务器返回的JSON
+func (c *TCPClient) DescribeCommands(plugin string) (json.RawMessage, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.connected {
+		return nil, errors.New("not connected to server")
+	}
+
+	// 生成请求ID
+	requestID := generateRequestID()
+
+	// 构建描述请求
+	req := map[string]interface{}{
+		"plugin": plugin,
+	}
+	reqJSON, err := encodeJSON(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode describe request: %w", err)
+	}
+
+	// 发送描述请求
+	if err := c.sendMessage(DescribeRequest, requestID, reqJSON); err != nil {
+		return nil, fmt.Errorf("failed to send describe request: %w", err)
+	}
+
+	// 接收描述响应
+	for {
+		msgType, respRequestID, payload, err := c.receiveMessage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive describe response: %w", err)
+		}
+
+		// 检查请求ID
+		if respRequestID != requestID {
+			continue
+		}
+
+		switch msgType {
+		case DescribeResponse:
+			var resp struct {
+				Plugins json.RawMessage `json:"plugins"`
+			}
+			if err := json.Unmarshal(payload, &resp); err != nil {
+				return nil, fmt.Errorf("failed to decode describe response: %w", err)
+			}
+			return resp.Plugins, nil
+
+		case ErrorResponse:
+			return nil, fmt.Errorf("describe failed: %s", string(payload))
+
+		default:
+			return nil, fmt.Errorf("unexpected response type: %d", msgType)
+		}
+	}
+}
+
 // sendMessage 发送消息
 func (c *TCPClient) sendMessage(msgType MessageType, requestID string, payload []byte) error {
 	// 设置写入超时
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -15,6 +16,7 @@ type TCPClient interface {
 	Disconnect() error
 	IsConnected() bool
 	ExecuteCommand(plugin, command string, args []string) (string, error)
+	DescribeCommands(plugin string) (json.RawMessage, error)
 }
 
 var tcpClient TCPClient
@@ -133,6 +135,40 @@ func GetPluginInfo(c *gin.Context) {
 	})
 }
 
+// GetPluginCommands 获取插件命令的参数、选项和输出描述，用于生成命令表单
+func GetPluginCommands(c *gin.Context) {
+	pluginID := c.Param("id")
+	if pluginID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Plugin ID is required",
+		})
+		return
+	}
+
+	if tcpClient == nil || !tcpClient.IsConnected() {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "TCP client not connected",
+		})
+		return
+	}
+
+	descriptions, err := tcpClient.DescribeCommands(pluginID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to describe plugin commands: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    descriptions,
+	})
+}
+
 // ExecuteCommand 执行命令
 func ExecuteCommand(c *gin.Context) {
 	var req models.CommandRequest
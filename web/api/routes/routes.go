@@ -26,6 +26,7 @@ func SetupRouter() *gin.Engine {
 		// 插件管理
 		api.GET("/plugins", handlers.ListPlugins)
 		api.GET("/plugins/:id", handlers.GetPluginInfo)
+		api.GET("/plugins/:id/commands", handlers.GetPluginCommands)
 		api.POST("/plugins/:id/start", handlers.StartPlugin)
 		api.POST("/plugins/:id/stop", handlers.StopPlugin)
 		api.POST("/command", handlers.ExecuteCommand)